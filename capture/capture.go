@@ -1,24 +1,30 @@
 package capture
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 	"yamdc/debugLogger"
-	"yamdc/envflag"
+	"yamdc/ffmpeg"
+	"yamdc/journal"
 	"yamdc/model"
+	"yamdc/naming"
 	"yamdc/nfo"
 	"yamdc/number_parser"
 	"yamdc/processor"
+	"yamdc/searcher"
 	"yamdc/store"
+	"yamdc/transfer"
 	"yamdc/utils"
 
 	"github.com/xxxsen/common/logutil"
-	"github.com/xxxsen/common/replacer"
 	"github.com/xxxsen/common/trace"
 	"go.uber.org/zap"
 )
@@ -28,13 +34,26 @@ const (
 	defaultExtraFanartDir = "extrafanart"
 )
 
+var errNoValidFiles = errors.New("no valid files found")
+
 var defaultMediaSuffix = []string{".mp4", ".wmv", ".flv", ".mpeg", ".m2ts", ".mts", ".mpe", ".mpg", ".m4v", ".avi", ".mkv", ".rmvb", ".ts", ".mov", ".rm"}
 
 type fcProcessFunc func(ctx context.Context, fc *model.FileContext) error
 
 type Capture struct {
-	c      *config
-	extMap map[string]struct{}
+	c             *config
+	extMap        map[string]struct{}
+	scanner       *scanner
+	failedScanner *scanner //重试隔离文件时使用
+	rerun         []*regexp.Regexp
+	namingTpl     *naming.Template
+	fileNamingTpl *naming.Template //为空时使用番号+后缀作为文件名
+	locker        *keyLocker
+	plan          *planRecorder //dry-run模式下记录执行计划
+	steps         []*pipelineStep
+	report        *reportRecorder //记录每个文件的处理结果, 运行结束后输出报告
+	overrides     *overrideSet    //集中式的覆盖配置, 每次运行时重新加载
+	runID         string          //当前运行的id, 用于关联操作日志
 }
 
 func New(opts ...Option) (*Capture, error) {
@@ -54,14 +73,69 @@ func New(opts ...Option) (*Capture, error) {
 	if len(c.Naming) == 0 {
 		c.Naming = defaultNamingRule
 	}
-	return &Capture{c: c, extMap: utils.StringListToSet(utils.StringListToLower(append(c.ExtraMediaExtList, defaultMediaSuffix...)))}, nil
+	if c.Concurrency <= 0 {
+		c.Concurrency = 1
+	}
+	if len(c.CollisionPolicy) == 0 {
+		c.CollisionPolicy = CollisionSkip
+	}
+	if !isValidCollisionPolicy(c.CollisionPolicy) {
+		return nil, fmt.Errorf("invalid collision policy:%s", c.CollisionPolicy)
+	}
+	//替换时已有影片需要移入重复目录, 不能直接删除
+	if (c.CollisionPolicy == CollisionMoveDuplicates || c.CollisionPolicy == CollisionReplaceIfBetter) && len(c.DuplicatesDir) == 0 {
+		return nil, fmt.Errorf("collision policy:%s requires duplicates dir", c.CollisionPolicy)
+	}
+	if c.Transfer == nil {
+		c.Transfer = transfer.MustCreate(string(transfer.ModeMove), "")
+	}
+	cp := &Capture{c: c, locker: newKeyLocker(), extMap: utils.StringListToSet(utils.StringListToLower(append(c.ExtraMediaExtList, defaultMediaSuffix...)))}
+	sc, err := newScanner(c, cp.isMediaFile)
+	if err != nil {
+		return nil, fmt.Errorf("init scanner failed, err:%w", err)
+	}
+	cp.scanner = sc
+	if cp.steps, err = cp.buildPipeline(c.Steps); err != nil {
+		return nil, fmt.Errorf("build pipeline failed, err:%w", err)
+	}
+	if cp.namingTpl, err = parseNamingRule(c.Naming); err != nil {
+		return nil, fmt.Errorf("parse naming rule failed, rule:%s, err:%w", c.Naming, err)
+	}
+	if len(c.FileNaming) > 0 {
+		if cp.fileNamingTpl, err = parseNamingRule(c.FileNaming); err != nil {
+			return nil, fmt.Errorf("parse file naming rule failed, rule:%s, err:%w", c.FileNaming, err)
+		}
+	}
+	for _, item := range c.RerunPatterns {
+		re, err := utils.CompileGlob(filepath.ToSlash(item))
+		if err != nil {
+			return nil, fmt.Errorf("compile rerun pattern failed, pattern:%s, err:%w", item, err)
+		}
+		cp.rerun = append(cp.rerun, re)
+	}
+	if c.RetryFailed && len(c.FailedDir) > 0 {
+		fc := *c
+		fc.ScanDir = c.FailedDir
+		fc.ScanMaxDepth = 1
+		fsc, err := newScanner(&fc, cp.isMediaFile)
+		if err != nil {
+			return nil, fmt.Errorf("init failed dir scanner failed, err:%w", err)
+		}
+		cp.failedScanner = fsc
+	}
+	return cp, nil
 }
 
 /* 通过路径文件名 识别电影 信息:电影名,分集数,内嵌中文字幕 等  */
-func (c *Capture) resolveFileInfo(fc *model.FileContext, file string) error {
+func (c *Capture) resolveFileInfo(ctx context.Context, fc *model.FileContext, file string) error {
 	fc.FileName = filepath.Base(file)
 	fc.FileExt = filepath.Ext(file)
 	fileNoExt := fc.FileName[:len(fc.FileName)-len(fc.FileExt)]
+	//存在覆盖配置且指定了番号时, 直接使用指定的番号
+	fc.Override = c.findOverride(ctx, file)
+	if fc.Override != nil && len(fc.Override.Number) > 0 {
+		fileNoExt = fc.Override.Number
+	}
 
 	// 通过文件名 识别 电影信息 过程
 	numberInfo, err := number_parser.Parse(fileNoExt)
@@ -82,65 +156,75 @@ func (c *Capture) isMediaFile(f string) bool {
 }
 
 /* 读取文件列表,含识别番号的过程，返回一个文件上下文列表。*/
-func (c *Capture) readFileList() ([]*model.FileContext, error) {
-
-	fm := utils.NewFileManager()
-	fcs := make([]*model.FileContext, 0, 20)
-	entries, err := fm.ReadDirSafely(c.c.ScanDir)
+func (c *Capture) readFileList(ctx context.Context) ([]*model.FileContext, error) {
+	files, err := c.scanner.Scan(ctx)
 	if err != nil {
 		return nil, err
 	}
-	for _, entry := range entries {
-		fullPath := filepath.Join(c.c.ScanDir, entry.Name())
-		// 处理文件名编码
-		normalizedPath := fm.NormalizePathForPlatform(fullPath)
-
-		// 检查是否目录
-		isDir, err := fm.IsDir(normalizedPath)
-		if err != nil {
-			return nil, err
+	scanned := len(files)
+	files = c.filterByState(ctx, files)
+	if c.failedScanner != nil {
+		failed, err := c.failedScanner.Scan(ctx)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("scan failed dir failed, err:%w", err)
 		}
-		if isDir {
-			continue
-		}
-		// 检查是否媒体文件
-		isExist, err := fm.IsExist(normalizedPath)
-		if err != nil {
-			return nil, err
-		}
-
-		if !c.isMediaFile(normalizedPath) {
-			continue
-		}
-		if !isExist {
-			// 1. 文件确实不存在了(曾经存在)
-			// 2. 文件存在,但是程序无法读取,测试出现过Mac NFS挂载的文件日文能看到,但是无法打开(识别),必须手动重命名后才能打开
-			debugLogger.Shared().Error("程序文件无法识别该文件,请检查文件是否存在,如存在请手动命名再试", zap.String("file", normalizedPath))
-			continue
-		}
-		fc := &model.FileContext{FullFilePath: normalizedPath}
+		logutil.GetLogger(ctx).Info("retry quarantined files", zap.Int("count", len(failed)))
+		files = append(files, failed...)
+	}
+	if c.overrides, err = loadOverrideSet(c.c.OverrideFile); err != nil {
+		return nil, fmt.Errorf("load overrides failed, file:%s, err:%w", c.c.OverrideFile, err)
+	}
+	fcs := make([]*model.FileContext, 0, len(files))
+	for _, file := range files {
+		fc := &model.FileContext{FullFilePath: file}
 		// 通过路径文件名 识别电影 信息
-		if err := c.resolveFileInfo(fc, normalizedPath); err != nil {
+		if err := c.resolveFileInfo(ctx, fc, file); err != nil {
 			return nil, err
 		}
 		fcs = append(fcs, fc)
 	}
+	if len(fcs) == 0 && scanned > 0 {
+		logutil.GetLogger(ctx).Info("all files are skipped by state, nothing to do", zap.Int("scanned", scanned))
+		return nil, nil
+	}
 	if len(fcs) == 0 {
-		return nil, fmt.Errorf("no valid files found in directory: %s", c.c.ScanDir)
+		return nil, fmt.Errorf("%w in directory: %s", errNoValidFiles, c.c.ScanDir)
 
 	}
-
-	return fcs, nil
+	//分集C可能表示中文字幕, 需要结合同目录下的其他文件再次确认
+	number_parser.ReorganizeAllNumbers(fcs)
+	//查找字幕等附属文件, 存在中文字幕时需要调整番号信息
+	c.discoverSidecars(ctx, fcs)
+	for _, fc := range fcs {
+		//覆盖配置中的分类及标记优先级最高
+		if fc.Override != nil {
+			fc.Override.ApplyToNumber(fc.Number)
+		}
+		fc.SaveFileBase = fc.Number.GenerateFileName()
+	}
+	//多分段影片只需要刮削一次
+	return groupMultiPartFiles(fcs), nil
 }
 
 // Run 执行捕获过程，主要负责读取文件列表、展示数字信息和处理文件列表。
 // 该函数接收一个 context.Context 类型的参数 ctx，用于控制操作的取消或超时。
 func (c *Capture) Run(ctx context.Context) error {
+	c.plan = &planRecorder{}
+	c.report = newReportRecorder()
 	// 读取文件列表，如果读取失败，则返回错误信息。
-	fcs, err := c.readFileList()
-	if err != nil {
+	fcs, err := c.readFileList(ctx)
+	if err != nil && !errors.Is(err, errNoValidFiles) {
 		return fmt.Errorf("read file list failed, err:%w", err)
 	}
+	if len(fcs) == 0 {
+		//没有需要处理的文件时同样输出报告并触发hook, 但不创建journal记录
+		c.runID = journal.NewRunID()
+		c.outputReport(ctx)
+		if err != nil {
+			return fmt.Errorf("read file list failed, err:%w", err)
+		}
+		return nil
+	}
 	debugLogger.Shared().Sugar().Debugf("start read local file!⬇️⬇️⬇️⬇️⬇️⬇️⬇️⬇️⬇️⬇️⬇️⬇️⬇️⬇️⬇️")
 	// 扫描本地文件路径视频以获取文件基础信息
 	c.displayNumberInfo(ctx, fcs)
@@ -148,7 +232,15 @@ func (c *Capture) Run(ctx context.Context) error {
 	// TODO 处理文件列表
 	debugLogger.Shared().Sugar().Debugf("start process file!⬇️⬇️⬇️⬇️⬇️⬇️⬇️⬇️⬇️⬇️⬇️⬇️⬇️⬇️⬇️")
 
-	if err := c.processFileList(ctx, fcs); err != nil {
+	searcher.ResetBackoff() //插件的退避状态仅在本次运行内有效
+	c.beginRun(ctx)
+	err = c.processFileList(ctx, fcs)
+	c.endRun(ctx)
+	if c.c.DryRun {
+		c.outputPlan(ctx)
+	}
+	c.outputReport(ctx)
+	if err != nil {
 		debugLogger.Shared().Sugar().Debugf("failed process file !⬆️⬆️⬆️⬆️⬆️⬆️⬆️⬆️⬆️⬆️⬆️⬆️⬆️⬆️⬆️⬆️⬆️⬆️⬆️")
 		return fmt.Errorf("proc file list failed, err:%w", err)
 	} else {
@@ -158,6 +250,59 @@ func (c *Capture) Run(ctx context.Context) error {
 	return nil
 }
 
+func (c *Capture) beginRun(ctx context.Context) {
+	c.runID = journal.NewRunID()
+	if c.c.Journal == nil || c.c.DryRun {
+		return
+	}
+	if err := c.c.Journal.CreateRun(ctx, c.runID); err != nil {
+		logutil.GetLogger(ctx).Error("create journal run failed", zap.String("run_id", c.runID), zap.Error(err))
+		return
+	}
+	logutil.GetLogger(ctx).Info("journal run created, use `undo --run` to revert this run", zap.String("run_id", c.runID))
+}
+
+func (c *Capture) endRun(ctx context.Context) {
+	if c.c.Journal == nil || c.c.DryRun {
+		return
+	}
+	if err := c.c.Journal.UpdateRunStatus(ctx, c.runID, journal.RunStatusFinished); err != nil {
+		logutil.GetLogger(ctx).Error("finish journal run failed", zap.String("run_id", c.runID), zap.Error(err))
+	}
+}
+
+func (c *Capture) outputPlan(ctx context.Context) {
+	p := c.plan.Build()
+	if err := WritePlanTable(os.Stdout, p); err != nil {
+		logutil.GetLogger(ctx).Error("print plan table failed", zap.Error(err))
+	}
+	if len(c.c.PlanDir) == 0 {
+		return
+	}
+	f, err := WritePlanFile(c.c.PlanDir, p)
+	if err != nil {
+		logutil.GetLogger(ctx).Error("write plan file failed", zap.Error(err))
+		return
+	}
+	logutil.GetLogger(ctx).Info("dry-run plan saved", zap.String("file", f), zap.Int("count", len(p.Items)))
+}
+
+func (c *Capture) outputReport(ctx context.Context) {
+	rp := c.report.Build(c.runID, c.c.DryRun)
+	c.fireRunHook(ctx, rp)
+	logutil.GetLogger(ctx).Info("run finished", zap.String("run_id", rp.RunID), zap.Int("total", rp.Total),
+		zap.Int("success", rp.Success), zap.Int("failed", rp.Failed), zap.Int("skipped", rp.Skipped))
+	if len(c.c.ReportDir) == 0 {
+		return
+	}
+	f, err := WriteReportFile(c.c.ReportDir, rp)
+	if err != nil {
+		logutil.GetLogger(ctx).Error("write report file failed", zap.Error(err))
+		return
+	}
+	logutil.GetLogger(ctx).Info("run report saved", zap.String("file", f))
+}
+
 // CondString 当 value 非空时返回 zap.String，否则返回 zap.Skip()
 func CondString(key, value string) zap.Field {
 	if value == "" {
@@ -189,62 +334,122 @@ func (c *Capture) displayNumberInfo(ctx context.Context, fcs []*model.FileContex
 			CondBool("leaked", item.Number.IsLeaked, "💧"),
 			CondString("cat", item.Number.Cat.String()),
 			zap.String("file", item.FileName),
+			zap.Int("parts", len(item.Parts)),
+			zap.Int("sidecars", len(item.Sidecars)),
 		)
 
 	}
 }
 
-// 刮削提取的文件信息
+// 刮削提取的文件信息, 使用固定数量的worker并发处理
 func (c *Capture) processFileList(ctx context.Context, fcs []*model.FileContext) error {
 	var outErr error
+	var mu sync.Mutex
+	ch := make(chan *model.FileContext)
+	wg := sync.WaitGroup{}
+	for i := 0; i < c.c.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range ch {
+				fctx, st := searcher.WithSearchTrace(ctx)
+				fctx, tm := withStepTimings(fctx)
+				start := time.Now()
+				err := c.processOneFile(fctx, item)
+				c.report.Add(buildReportItem(item, err, time.Since(start), tm.Steps(), st.Attempts()))
+				if c.c.DryRun {
+					c.plan.Add(buildPlanItem(item, err))
+				}
+				if err != nil {
+					mu.Lock()
+					outErr = err
+					mu.Unlock()
+					logutil.GetLogger(ctx).Error("process file failed", zap.Error(err), zap.String("file", item.FullFilePath))
+					qr := c.quarantineFile(ctx, item, err, st.Attempts())
+					//隔离完成后再触发hook, 以便hook拿到影片最终的位置
+					c.fireFileHook(ctx, item, err, qr)
+					c.saveFileState(ctx, item, err, qr.movedFiles())
+					continue
+				}
+				c.fireFileHook(ctx, item, nil, nil)
+				c.releaseQuarantine(ctx, item)
+				c.saveFileState(ctx, item, nil, nil)
+				logutil.GetLogger(ctx).Info("process file succ", zap.String("file", item.FullFilePath))
+			}
+		}()
+	}
+	isCanceled := false
 	for _, item := range fcs {
-		if err := c.processOneFile(ctx, item); err != nil {
-			outErr = err
-			logutil.GetLogger(ctx).Error("process file failed", zap.Error(err), zap.String("file", item.FullFilePath))
-			continue
+		select {
+		case ch <- item:
+		case <-ctx.Done():
+			isCanceled = true
+		}
+		if isCanceled {
+			break
 		}
-		logutil.GetLogger(ctx).Info("process file succ", zap.String("file", item.FullFilePath))
+	}
+	close(ch)
+	wg.Wait()
+	if isCanceled && outErr == nil {
+		outErr = ctx.Err()
 	}
 	return outErr
 }
 
 func (c *Capture) resolveSaveDir(fc *model.FileContext) error {
-	ts := time.UnixMilli(fc.Meta.ReleaseDate)
-	date := ts.Format(time.DateOnly)
-	year := fmt.Sprintf("%d", ts.Year())
-	month := fmt.Sprintf("%d", ts.Month())
-	actor := "佚名"
-	if len(fc.Meta.Actors) > 0 {
-		actor = utils.BuildAuthorsName(fc.Meta.Actors, 256)
-	}
-	m := map[string]interface{}{
-		NamingReleaseDate:  date,
-		NamingReleaseYear:  year,
-		NamingReleaseMonth: month,
-		NamingActor:        actor,
-		NamingNumber:       fc.Number.GetNumberID(),
-	}
-	naming := replacer.ReplaceByMap(c.c.Naming, m)
-	if len(naming) == 0 {
+	vars := buildNamingVars(fc)
+	if c.fileNamingTpl != nil {
+		base, err := c.fileNamingTpl.Render(vars)
+		if err != nil {
+			return fmt.Errorf("render file naming rule failed, err:%w", err)
+		}
+		fc.SaveFileBase = base
+	}
+	fc.SaveFileBase = utils.SanitizePathComponent(fc.SaveFileBase, maxFileBaseBytes)
+	if len(fc.SaveFileBase) == 0 {
+		return fmt.Errorf("invalid file naming")
+	}
+	dir, err := c.namingTpl.Render(vars)
+	if err != nil {
+		return fmt.Errorf("render naming rule failed, err:%w", err)
+	}
+	dir = utils.SanitizeRelPath(dir, maxDirComponentBytes)
+	if len(dir) == 0 {
 		return fmt.Errorf("invalid naming")
 	}
-	fc.SaveDir = filepath.Join(c.c.SaveDir, naming)
+	fc.SaveDir = filepath.Join(c.c.SaveDir, dir)
+	//清理后的路径理论上不会逃逸, 这里作为最后一道保障
+	if !utils.IsSubPath(c.c.SaveDir, fc.SaveDir) {
+		return fmt.Errorf("save dir:%s escapes from:%s", fc.SaveDir, c.c.SaveDir)
+	}
 	return nil
 }
 
 func (c *Capture) doSearch(ctx context.Context, fc *model.FileContext) error {
-	meta, ok, err := c.c.Searcher.Search(ctx, fc.Number)
+	//本地影片的时长用于校验搜索结果的匹配度, 匹配度不足的结果会在插件内被丢弃
+	sctx := searcher.WithLocalDuration(searchContextWithOverride(ctx, fc), readLocalDuration(ctx, fc))
+	meta, ok, err := c.c.Searcher.Search(sctx, fc.Number)
 	if err != nil {
 		return fmt.Errorf("search number failed, number:%s, err:%w", fc.Number.GetNumberID(), err)
 	}
 	if !ok {
-		return fmt.Errorf("search item not found")
-	}
-	if meta.Number != fc.Number.GetNumberID() {
-		logutil.GetLogger(ctx).Warn("number not match, may be re-generated, ignore", zap.String("search", meta.Number), zap.String("file", fc.Number.GetNumberID()))
+		return errSearchNotFound
 	}
 	fc.Meta = meta
-	return nil
+	return applyOverrideMeta(ctx, fc)
+}
+
+func readLocalDuration(ctx context.Context, fc *model.FileContext) int64 {
+	if !ffmpeg.IsFFProbeEnabled() || fc.Number.GetIsMultiCD() {
+		return 0
+	}
+	duration, err := ffmpeg.ReadDuration(ctx, fc.FullFilePath)
+	if err != nil {
+		logutil.GetLogger(ctx).Debug("read local duration failed, skip duration check", zap.Error(err), zap.String("file", fc.FullFilePath))
+		return 0
+	}
+	return int64(duration)
 }
 
 func (c *Capture) doProcess(ctx context.Context, fc *model.FileContext) error {
@@ -261,31 +466,54 @@ func (c *Capture) doNaming(ctx context.Context, fc *model.FileContext) error {
 	if err := c.resolveSaveDir(fc); err != nil {
 		return fmt.Errorf("resolve save dir failed, err:%w", err)
 	}
+	//数据重命名
+	if err := c.renameMetaField(fc); err != nil {
+		return fmt.Errorf("rename meta field failed, err:%w", err)
+	}
+	if c.c.DryRun { //dry-run模式下不创建任何目录
+		return nil
+	}
 	//创建必要的目录
-	if err := os.MkdirAll(fc.SaveDir, 0755); err != nil {
+	if err := c.mkdirAll(ctx, fc.SaveDir); err != nil {
 		return fmt.Errorf("make save dir failed, err:%w", err)
 	}
-	if err := os.MkdirAll(filepath.Join(fc.SaveDir, defaultExtraFanartDir), 0755); err != nil {
+	if err := c.mkdirAll(ctx, filepath.Join(fc.SaveDir, defaultExtraFanartDir)); err != nil {
 		return fmt.Errorf("make fanart dir failed, err:%w", err)
 	}
-	//数据重命名
-	if err := c.renameMetaField(fc); err != nil {
-		return fmt.Errorf("rename meta field failed, err:%w", err)
+	return nil
+}
+
+func (c *Capture) doSaveImages(ctx context.Context, fc *model.FileContext) error {
+	if c.c.DryRun {
+		return nil
+	}
+	//保存封面等图片
+	if err := c.saveImages(ctx, fc); err != nil {
+		return fmt.Errorf("save images failed, err:%w", err)
 	}
 	return nil
 }
 
-func (c *Capture) doSaveData(ctx context.Context, fc *model.FileContext) error {
-	//保存元数据并将影片移入指定目录
-	if err := c.saveMediaData(ctx, fc); err != nil {
-		return fmt.Errorf("save meta data failed, err:%w", err)
+func (c *Capture) doMove(ctx context.Context, fc *model.FileContext) error {
+	if c.c.DryRun {
+		return nil
+	}
+	//将影片及附属文件移入指定目录
+	for _, target := range resolveMovieTargets(fc) {
+		if err := c.moveMovie(ctx, fc, target.Src, target.Dst); err != nil {
+			return fmt.Errorf("move movie to dst dir failed, src:%s, err:%w", target.Src, err)
+		}
 	}
+	c.moveSidecars(ctx, fc)
 	return nil
 }
 
 func (c *Capture) doExport(ctx context.Context, fc *model.FileContext) error {
+	if c.c.DryRun {
+		return nil
+	}
 	// 导出jellyfin需要的nfo信息
-	if err := c.exportNFOData(fc); err != nil {
+	if err := c.exportNFOData(ctx, fc); err != nil {
 		return fmt.Errorf("export nfo data failed, err:%w", err)
 	}
 	return nil
@@ -310,24 +538,28 @@ func (c *Capture) doMetaVerify(ctx context.Context, fc *model.FileContext) error
 
 func (c *Capture) processOneFile(ctx context.Context, fc *model.FileContext) error {
 	ctx = trace.WithTraceId(ctx, "TID:N:"+fc.Number.GetNumberID())
-	steps := []struct {
-		name string
-		fn   fcProcessFunc
-	}{
-		{"search", c.doSearch},
-		{"process", c.doProcess},
-		{"metaverify", c.doMetaVerify},
-		{"naming", c.doNaming},
-		{"savedata", c.doSaveData},
-		{"nfo", c.doExport},
-	}
+	//相同番号的文件需要串行处理
+	unlockNumber := c.locker.Lock("number:" + fc.Number.GetNumberID())
+	defer unlockNumber()
 	logger := logutil.GetLogger(ctx).With(zap.String("file", fc.FileName))
-	for idx, step := range steps {
+	for idx, step := range c.steps {
 		log := logger.With(zap.Int("idx", idx), zap.String("name", step.name))
 		log.Debug("step start")
-		if err := step.fn(ctx, fc); err != nil {
+		start := time.Now()
+		err := runStep(ctx, step, fc)
+		recordStepTiming(ctx, step.name, time.Since(start))
+		if err != nil {
+			if errors.Is(err, errSkipRemainingSteps) {
+				log.Info("skip remaining steps")
+				return nil
+			}
 			log.Error("proc step failed", zap.Error(err))
-			return err
+			return &StepError{Step: step.name, Err: err}
+		}
+		if step.name == StepNaming {
+			//保存目录确定后, 写入同一目录的文件需要串行处理
+			unlockSaveDir := c.locker.Lock("savedir:" + fc.SaveDir)
+			defer unlockSaveDir()
 		}
 		log.Debug("step end")
 	}
@@ -358,7 +590,10 @@ func (c *Capture) renameMetaField(fc *model.FileContext) error {
 	return nil
 }
 
-func (c *Capture) saveMediaData(ctx context.Context, fc *model.FileContext) error {
+func collectImages(fc *model.FileContext) []*model.File {
+	if fc.Meta == nil {
+		return nil
+	}
 	images := make([]*model.File, 0, len(fc.Meta.SampleImages)+2)
 	if fc.Meta.Cover != nil {
 		images = append(images, fc.Meta.Cover)
@@ -367,6 +602,11 @@ func (c *Capture) saveMediaData(ctx context.Context, fc *model.FileContext) erro
 		images = append(images, fc.Meta.Poster)
 	}
 	images = append(images, fc.Meta.SampleImages...)
+	return images
+}
+
+func (c *Capture) saveImages(ctx context.Context, fc *model.FileContext) error {
+	images := collectImages(fc)
 	for _, image := range images {
 		target := filepath.Join(fc.SaveDir, image.Name)
 		logger := logutil.GetLogger(context.Background()).With(zap.String("image", image.Name), zap.String("key", image.Key), zap.String("target", target))
@@ -377,51 +617,75 @@ func (c *Capture) saveMediaData(ctx context.Context, fc *model.FileContext) erro
 			return err
 		}
 
-		if err := os.WriteFile(target, data, 0644); err != nil {
+		if err := c.writeFile(ctx, target, data); err != nil {
 			logger.Error("write image failed", zap.Error(err))
 			return err
 		}
 		logger.Debug("write image succ")
 	}
-	movie := filepath.Join(fc.SaveDir, fc.SaveFileBase+fc.FileExt)
-	if err := c.moveMovie(fc, fc.FullFilePath, movie); err != nil {
-		return fmt.Errorf("move movie to dst dir failed, err:%w", err)
-	}
 	return nil
 }
 
-func (c *Capture) moveMovie(fc *model.FileContext, src string, dst string) error {
-	// 暂时不移动, 打印 移动
-	//TODO: 暂时不移动, 打印 移动
-	// debugLogger.Shared().Debugw("🐛:move movie to dst dir", src, dst)
-	// return nil
-	if envflag.IsEnableLinkMode() {
-		return c.moveMovieByLink(fc, src, dst)
+func (c *Capture) moveSidecars(ctx context.Context, fc *model.FileContext) {
+	//附属文件不影响影片本身的刮削结果, 失败时仅记录日志
+	for _, target := range resolveSidecarTargets(fc) {
+		logger := logutil.GetLogger(ctx).With(zap.String("src", target.Src), zap.String("dst", target.Dst))
+		if _, err := os.Lstat(target.Dst); err == nil {
+			logger.Warn("sidecar target already exists, skip")
+			continue
+		}
+		if err := c.moveMovie(ctx, fc, target.Src, target.Dst); err != nil {
+			logger.Error("move sidecar failed", zap.Error(err))
+			continue
+		}
+		logger.Debug("move sidecar succ")
+	}
+}
+
+func (c *Capture) selectTransfer(fc *model.FileContext) transfer.ITransfer {
+	if t, ok := c.c.CategoryTransfer[fc.Number.GetCategory()]; ok {
+		return t
 	}
-	return c.moveMovieDirect(fc, src, dst)
+	return c.c.Transfer
 }
 
-func (c *Capture) moveMovieByLink(_ *model.FileContext, src, dst string) error {
-	err := os.Symlink(src, dst)
+func (c *Capture) moveMovie(ctx context.Context, fc *model.FileContext, src string, dst string) error {
+	t := c.selectTransfer(fc)
+	mode, err := t.Transfer(ctx, src, dst)
 	if err != nil {
-		if errors.Is(err, os.ErrExist) {
-			return nil
-		}
+		return fmt.Errorf("transfer movie by %s failed, err:%w", t.Name(), err)
+	}
+	if string(mode) != t.Name() {
+		logutil.GetLogger(ctx).Warn("transfer mode fallback", zap.String("mode", t.Name()), zap.String("actual", string(mode)), zap.String("dst", dst))
 	}
-	return err
+	c.recordFileAction(ctx, transferModeToAction(mode), src, dst)
+	return nil
 }
 
-func (c *Capture) moveMovieDirect(_ *model.FileContext, src, dst string) error {
-	return utils.NewFileManager().Move(src, dst)
+func transferModeToAction(mode transfer.Mode) journal.ActionType {
+	switch mode {
+	case transfer.ModeSymlink, transfer.ModeRelSymlink:
+		return journal.ActionSymlink
+	case transfer.ModeHardlink:
+		return journal.ActionHardlink
+	case transfer.ModeCopy, transfer.ModeReflink:
+		return journal.ActionCopy
+	default:
+		return journal.ActionMove
+	}
 }
 
-func (c *Capture) exportNFOData(fc *model.FileContext) error {
+func (c *Capture) exportNFOData(ctx context.Context, fc *model.FileContext) error {
 	mov, err := utils.ConvertMetaToMovieNFO(fc.Meta)
 	if err != nil {
 		return fmt.Errorf("convert meta to movie nfo failed, err:%w", err)
 	}
+	buf := bytes.NewBuffer(nil)
+	if err := nfo.WriteMovie(buf, mov); err != nil {
+		return fmt.Errorf("encode movie nfo failed, err:%w", err)
+	}
 	save := filepath.Join(fc.SaveDir, fc.SaveFileBase+".nfo")
-	if err := nfo.WriteMovieToFile(save, mov); err != nil {
+	if err := c.writeFile(ctx, save, buf.Bytes()); err != nil {
 		return fmt.Errorf("write movie nfo failed, err:%w", err)
 	}
 	return nil
@@ -0,0 +1,215 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"yamdc/ffmpeg"
+	"yamdc/journal"
+	"yamdc/model"
+	"yamdc/utils"
+
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+const (
+	CollisionSkip            = "skip"               //保留已有影片, 新文件留在扫描目录中
+	CollisionKeepBoth        = "keep_both"          //新文件使用-v2, -v3等后缀命名
+	CollisionReplaceIfBetter = "replace_if_better"  //新文件分辨率更高或者文件更大时替换已有影片
+	CollisionMoveDuplicates  = "move_to_duplicates" //保留已有影片, 新文件移入重复目录
+)
+
+const (
+	collisionActionSkip      = "skip"
+	collisionActionRename    = "rename"
+	collisionActionReplace   = "replace"
+	collisionActionDuplicate = "move_to_duplicates"
+	maxCollisionVersion      = 100
+)
+
+// errSkipRemainingSteps 不再执行后续步骤, 但不视为失败
+var errSkipRemainingSteps = errors.New("skip remaining steps")
+
+var collisionPolicies = map[string]struct{}{
+	CollisionSkip:            {},
+	CollisionKeepBoth:        {},
+	CollisionReplaceIfBetter: {},
+	CollisionMoveDuplicates:  {},
+}
+
+func isValidCollisionPolicy(p string) bool {
+	_, ok := collisionPolicies[p]
+	return ok
+}
+
+// findExistingTargets 返回全部已经被其他文件占用的影片目标(多分段影片的每个分段), 目标与源文件为同一文件(链接模式下重新刮削)时不视为冲突
+func findExistingTargets(fc *model.FileContext) []string {
+	rs := make([]string, 0, 1)
+	for _, target := range resolveMovieTargets(fc) {
+		dstInfo, err := os.Stat(target.Dst)
+		if err != nil {
+			if _, lerr := os.Lstat(target.Dst); lerr == nil { //失效的软链同样视为冲突
+				rs = append(rs, target.Dst)
+			}
+			continue
+		}
+		srcInfo, err := os.Stat(target.Src)
+		if err == nil && os.SameFile(srcInfo, dstInfo) {
+			continue
+		}
+		rs = append(rs, target.Dst)
+	}
+	return rs
+}
+
+type videoQuality struct {
+	pixels int
+	size   int64
+}
+
+func readVideoQuality(ctx context.Context, file string) videoQuality {
+	q := videoQuality{}
+	if fi, err := os.Stat(file); err == nil {
+		q.size = fi.Size()
+	}
+	if !ffmpeg.IsFFProbeEnabled() {
+		return q
+	}
+	w, h, err := ffmpeg.ReadResolution(ctx, file)
+	if err != nil {
+		logutil.GetLogger(ctx).Debug("read video resolution failed, use file size only", zap.String("file", file), zap.Error(err))
+		return q
+	}
+	q.pixels = w * h
+	return q
+}
+
+// isBetterQuality 分辨率优先, 分辨率相同或者无法读取时比较文件大小
+func isBetterQuality(n, o videoQuality) bool {
+	if n.pixels > 0 && o.pixels > 0 && n.pixels != o.pixels {
+		return n.pixels > o.pixels
+	}
+	return n.size > o.size
+}
+
+func (c *Capture) doDedup(ctx context.Context, fc *model.FileContext) error {
+	existings := findExistingTargets(fc)
+	if len(existings) == 0 {
+		return nil
+	}
+	existing := existings[0]
+	col := &model.Collision{Policy: c.c.CollisionPolicy, Existing: existing}
+	fc.Collision = col
+	logger := logutil.GetLogger(ctx).With(zap.String("file", fc.FullFilePath), zap.String("existing", existing), zap.String("policy", col.Policy))
+	switch c.c.CollisionPolicy {
+	case CollisionKeepBoth:
+		return c.keepBoth(ctx, fc, col)
+	case CollisionReplaceIfBetter:
+		nq := readVideoQuality(ctx, fc.FullFilePath)
+		oq := readVideoQuality(ctx, existing)
+		col.Reason = fmt.Sprintf("new:%dpx/%dB, existing:%dpx/%dB", nq.pixels, nq.size, oq.pixels, oq.size)
+		if isBetterQuality(nq, oq) {
+			col.Action = collisionActionReplace
+			logger.Info("target exists, replace it with better one", zap.String("reason", col.Reason))
+			return c.replaceExisting(ctx, col, existings)
+		}
+		logger.Info("target exists and is better, move new file to duplicates dir", zap.String("reason", col.Reason))
+		return c.moveNewToDuplicates(ctx, fc, col)
+	case CollisionMoveDuplicates:
+		logger.Info("target exists, move new file to duplicates dir")
+		return c.moveNewToDuplicates(ctx, fc, col)
+	default:
+		col.Action = collisionActionSkip
+		logger.Info("target exists, skip")
+		return errSkipRemainingSteps
+	}
+}
+
+func (c *Capture) keepBoth(ctx context.Context, fc *model.FileContext, col *model.Collision) error {
+	base := fc.SaveFileBase
+	for i := 2; i < maxCollisionVersion; i++ {
+		fc.SaveFileBase = utils.TruncateUTF8(base, maxFileBaseBytes-len(fmt.Sprintf("-v%d", i))) + fmt.Sprintf("-v%d", i)
+		if len(findExistingTargets(fc)) > 0 {
+			continue
+		}
+		col.Action = collisionActionRename
+		col.Reason = "rename to " + fc.SaveFileBase
+		logutil.GetLogger(ctx).Info("target exists, keep both", zap.String("file", fc.FullFilePath), zap.String("base", fc.SaveFileBase))
+		//图片及nfo需要使用新的文件名
+		return c.renameMetaField(fc)
+	}
+	fc.SaveFileBase = base
+	return fmt.Errorf("too many versions for %s", base)
+}
+
+// replaceExisting 将已有影片的全部分段移入重复目录, 移动操作会记录到journal中, 可以通过undo恢复
+func (c *Capture) replaceExisting(ctx context.Context, col *model.Collision, existings []string) error {
+	if c.c.DryRun {
+		return nil
+	}
+	for _, existing := range existings {
+		dst, err := c.moveToDuplicates(ctx, existing)
+		if err != nil {
+			return fmt.Errorf("move existing movie to duplicates dir failed, file:%s, err:%w", existing, err)
+		}
+		if len(col.Moved) == 0 {
+			col.Moved = dst
+		}
+	}
+	return nil
+}
+
+func (c *Capture) moveNewToDuplicates(ctx context.Context, fc *model.FileContext, col *model.Collision) error {
+	col.Action = collisionActionDuplicate
+	if len(c.c.DuplicatesDir) == 0 {
+		return fmt.Errorf("no duplicates dir configured")
+	}
+	if c.c.DryRun {
+		return errSkipRemainingSteps
+	}
+	for _, target := range resolveMovieTargets(fc) {
+		dst, err := c.moveToDuplicates(ctx, target.Src)
+		if err != nil {
+			return fmt.Errorf("move new movie to duplicates dir failed, err:%w", err)
+		}
+		if len(col.Moved) == 0 {
+			col.Moved = dst
+		}
+	}
+	return errSkipRemainingSteps
+}
+
+func (c *Capture) moveToDuplicates(ctx context.Context, src string) (string, error) {
+	if err := c.mkdirAll(ctx, c.c.DuplicatesDir); err != nil {
+		return "", err
+	}
+	dst, err := uniqueTargetIn(c.c.DuplicatesDir, filepath.Base(src))
+	if err != nil {
+		return "", err
+	}
+	if err := utils.NewFileManager().Move(src, dst); err != nil {
+		return "", err
+	}
+	c.recordFileAction(ctx, journal.ActionMove, src, dst)
+	return dst, nil
+}
+
+// uniqueTargetIn 在目录中为文件找到一个未被占用的名字, 被占用时添加.1, .2等后缀
+func uniqueTargetIn(dir string, name string) (string, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 0; i < maxCollisionVersion; i++ {
+		dst := filepath.Join(dir, name)
+		if i > 0 {
+			dst = filepath.Join(dir, fmt.Sprintf("%s.%d%s", base, i, ext))
+		}
+		if _, err := os.Lstat(dst); os.IsNotExist(err) {
+			return dst, nil
+		}
+	}
+	return "", fmt.Errorf("too many files with same name in dir:%s", dir)
+}
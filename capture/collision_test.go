@@ -0,0 +1,104 @@
+package capture
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"yamdc/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func prepareCollision(t *testing.T, policy string, newData string, oldData string) (*Capture, *model.FileContext, string) {
+	scanDir := t.TempDir()
+	saveDir := filepath.Join(t.TempDir(), "ABC-123")
+	assert.NoError(t, os.MkdirAll(saveDir, 0755))
+	src := filepath.Join(scanDir, "ABC-123.mp4")
+	assert.NoError(t, os.WriteFile(src, []byte(newData), 0644))
+	existing := filepath.Join(saveDir, "ABC-123.mp4")
+	assert.NoError(t, os.WriteFile(existing, []byte(oldData), 0644))
+	c := &Capture{c: &config{CollisionPolicy: policy, DuplicatesDir: filepath.Join(t.TempDir(), "dup")}}
+	fc := newMultiPartFc(src, "ABC-123", "")
+	fc.SaveDir = saveDir
+	fc.Meta = &model.AvMeta{Cover: &model.File{}, Poster: &model.File{}}
+	return c, fc, existing
+}
+
+func TestCollisionSkip(t *testing.T) {
+	c, fc, existing := prepareCollision(t, CollisionSkip, "new", "old")
+	err := c.doDedup(context.Background(), fc)
+	assert.ErrorIs(t, err, errSkipRemainingSteps)
+	assert.Equal(t, collisionActionSkip, fc.Collision.Action)
+	assert.Equal(t, existing, fc.Collision.Existing)
+	_, err = os.Stat(fc.FullFilePath)
+	assert.NoError(t, err)
+}
+
+func TestCollisionKeepBoth(t *testing.T) {
+	c, fc, _ := prepareCollision(t, CollisionKeepBoth, "new", "old")
+	assert.NoError(t, os.WriteFile(filepath.Join(fc.SaveDir, "ABC-123-v2.mp4"), []byte("v2"), 0644))
+	assert.NoError(t, c.doDedup(context.Background(), fc))
+	assert.Equal(t, collisionActionRename, fc.Collision.Action)
+	assert.Equal(t, "ABC-123-v3", fc.SaveFileBase)
+	assert.Equal(t, "ABC-123-v3-poster.jpg", fc.Meta.Poster.Name)
+}
+
+func TestCollisionReplaceIfBetter(t *testing.T) {
+	c, fc, existing := prepareCollision(t, CollisionReplaceIfBetter, "larger file", "old")
+	assert.NoError(t, c.doDedup(context.Background(), fc))
+	assert.Equal(t, collisionActionReplace, fc.Collision.Action)
+	assert.Equal(t, filepath.Join(c.c.DuplicatesDir, "ABC-123.mp4"), fc.Collision.Moved)
+	_, err := os.Stat(existing)
+	assert.True(t, os.IsNotExist(err))
+
+	c, fc, existing = prepareCollision(t, CollisionReplaceIfBetter, "new", "larger file")
+	err = c.doDedup(context.Background(), fc)
+	assert.ErrorIs(t, err, errSkipRemainingSteps)
+	assert.Equal(t, collisionActionDuplicate, fc.Collision.Action)
+	_, err = os.Stat(existing)
+	assert.NoError(t, err)
+	_, err = os.Stat(fc.FullFilePath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCollisionReplaceMultiPart(t *testing.T) {
+	scanDir := t.TempDir()
+	saveDir := filepath.Join(t.TempDir(), "ABC-123")
+	assert.NoError(t, os.MkdirAll(saveDir, 0755))
+	fcs := make([]*model.FileContext, 0, 2)
+	for _, ep := range []string{"1", "2"} {
+		src := filepath.Join(scanDir, "ABC-123-cd"+ep+".mp4")
+		assert.NoError(t, os.WriteFile(src, []byte("larger file"), 0644))
+		assert.NoError(t, os.WriteFile(filepath.Join(saveDir, "ABC-123-cd"+ep+".mp4"), []byte("old"), 0644))
+		fcs = append(fcs, newMultiPartFc(src, "ABC-123", ep))
+	}
+	fc := groupMultiPartFiles(fcs)[0]
+	fc.SaveDir = saveDir
+	c := &Capture{c: &config{CollisionPolicy: CollisionReplaceIfBetter, DuplicatesDir: filepath.Join(t.TempDir(), "dup")}}
+	assert.NoError(t, c.doDedup(context.Background(), fc))
+	assert.Equal(t, collisionActionReplace, fc.Collision.Action)
+	//全部分段都需要移走, 否则后续的分段依旧会冲突
+	assert.Equal(t, 0, len(findExistingTargets(fc)))
+	for _, ep := range []string{"1", "2"} {
+		_, err := os.Stat(filepath.Join(c.c.DuplicatesDir, "ABC-123-cd"+ep+".mp4"))
+		assert.NoError(t, err)
+	}
+}
+
+func TestCollisionMoveDuplicates(t *testing.T) {
+	c, fc, _ := prepareCollision(t, CollisionMoveDuplicates, "new", "old")
+	assert.NoError(t, os.MkdirAll(c.c.DuplicatesDir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(c.c.DuplicatesDir, "ABC-123.mp4"), []byte("x"), 0644))
+	err := c.doDedup(context.Background(), fc)
+	assert.ErrorIs(t, err, errSkipRemainingSteps)
+	assert.Equal(t, filepath.Join(c.c.DuplicatesDir, "ABC-123.1.mp4"), fc.Collision.Moved)
+}
+
+func TestCollisionSameFile(t *testing.T) {
+	c, fc, existing := prepareCollision(t, CollisionSkip, "new", "old")
+	assert.NoError(t, os.Remove(existing))
+	assert.NoError(t, os.Symlink(fc.FullFilePath, existing))
+	assert.NoError(t, c.doDedup(context.Background(), fc))
+	assert.Nil(t, fc.Collision)
+}
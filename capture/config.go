@@ -1,20 +1,43 @@
 package capture
 
 import (
+	"time"
+	"yamdc/hook"
+	"yamdc/journal"
+	"yamdc/model"
 	"yamdc/processor"
 	"yamdc/searcher"
+	"yamdc/store"
+	"yamdc/transfer"
 )
 
 const (
-	NamingReleaseDate  = "DATE"
-	NamingReleaseYear  = "YEAR"
-	NamingReleaseMonth = "MONTH"
-	NamingActor        = "ACTOR"
-	NamingNumber       = "NUMBER"
+	NamingReleaseDate     = "DATE"
+	NamingReleaseYear     = "YEAR"
+	NamingReleaseMonth    = "MONTH"
+	NamingReleaseDay      = "DAY"
+	NamingActor           = "ACTOR"
+	NamingActors          = "ACTORS"
+	NamingFirstActor      = "FIRST_ACTOR"
+	NamingNumber          = "NUMBER"
+	NamingFileName        = "FILENAME" //默认的文件名, 即番号+4K/中文字幕/流出等后缀
+	NamingStudio          = "STUDIO"
+	NamingLabel           = "LABEL"
+	NamingSeries          = "SERIES"
+	NamingTitle           = "TITLE"
+	NamingTitleTranslated = "TITLE_TRANSLATED"
+	NamingDirector        = "DIRECTOR"
+	NamingCategory        = "CATEGORY"
+	NamingGenres          = "GENRES"
+	NamingIs4K            = "4K"
+	NamingIsCnSub         = "CNSUB"
+	NamingIsUncensored    = "UNCENSORED"
+	NamingIsLeaked        = "LEAKED"
+	NamingIsCracked       = "CRACKED"
 )
 
 const (
-	defaultNamingRule = NamingReleaseYear + "/" + NamingActor + "/" + NamingNumber
+	defaultNamingRule = "{" + NamingReleaseYear + "}/{" + NamingActor + "}/{" + NamingNumber + "}"
 )
 
 type config struct {
@@ -23,7 +46,31 @@ type config struct {
 	Processor         processor.IProcessor
 	SaveDir           string
 	Naming            string
+	FileNaming        string
 	ExtraMediaExtList []string
+
+	ScanMaxDepth        int
+	ScanExcludePatterns []string
+	ScanExcludeRegexes  []string
+	ScanMinFileSize     int64
+	ScanFollowSymlink   bool
+	ScanMinFileAge      time.Duration
+	FailedDir           string
+	CollisionPolicy     string
+	DuplicatesDir       string
+	OverrideFile        string
+	ReportDir           string
+	Steps               []StepConfig
+	Hooks               *hook.Runner
+	StateStore          store.IFileStateStore
+	RerunPatterns       []string
+	RetryFailed         bool
+	Concurrency         int
+	DryRun              bool
+	PlanDir             string
+	Journal             journal.IJournal
+	Transfer            transfer.ITransfer
+	CategoryTransfer    map[model.Category]transfer.ITransfer
 }
 
 type Option func(c *config)
@@ -63,3 +110,163 @@ func WithExtraMediaExtList(lst []string) Option {
 		c.ExtraMediaExtList = lst
 	}
 }
+
+// WithScanMaxDepth 最大扫描深度, 0为不限制, 1为仅扫描顶层目录
+func WithScanMaxDepth(depth int) Option {
+	return func(c *config) {
+		c.ScanMaxDepth = depth
+	}
+}
+
+// WithScanExcludePatterns glob格式的排除规则, 例如: `**/sample/**`, `@eaDir`
+func WithScanExcludePatterns(lst []string) Option {
+	return func(c *config) {
+		c.ScanExcludePatterns = lst
+	}
+}
+
+// WithScanExcludeRegexes 正则格式的排除规则, 匹配相对于扫描目录的路径
+func WithScanExcludeRegexes(lst []string) Option {
+	return func(c *config) {
+		c.ScanExcludeRegexes = lst
+	}
+}
+
+// WithScanMinFileSize 最小文件大小, 单位为字节, 小于该值的文件会被忽略
+func WithScanMinFileSize(sz int64) Option {
+	return func(c *config) {
+		c.ScanMinFileSize = sz
+	}
+}
+
+func WithScanFollowSymlink(v bool) Option {
+	return func(c *config) {
+		c.ScanFollowSymlink = v
+	}
+}
+
+// WithConcurrency 同时处理的文件数
+func WithConcurrency(n int) Option {
+	return func(c *config) {
+		c.Concurrency = n
+	}
+}
+
+// WithDryRun 开启后会执行完整的刮削流程, 但不会对保存目录做任何修改, 仅输出执行计划
+func WithDryRun(v bool) Option {
+	return func(c *config) {
+		c.DryRun = v
+	}
+}
+
+// WithScanMinFileAge 跳过最近仍在修改的文件, 用于常驻模式下避免处理尚未下载完成的文件
+func WithScanMinFileAge(d time.Duration) Option {
+	return func(c *config) {
+		c.ScanMinFileAge = d
+	}
+}
+
+// WithFailedDir 处理失败的影片会被移入该目录, 并写入失败原因, 为空则保留在扫描目录中
+func WithFailedDir(dir string) Option {
+	return func(c *config) {
+		c.FailedDir = dir
+	}
+}
+
+// WithRetryFailed 重新处理隔离目录中的影片
+func WithRetryFailed(v bool) Option {
+	return func(c *config) {
+		c.RetryFailed = v
+	}
+}
+
+// WithStateStore 记录文件的处理状态, 后续运行时跳过已经处理过的文件
+func WithStateStore(s store.IFileStateStore) Option {
+	return func(c *config) {
+		c.StateStore = s
+	}
+}
+
+// WithRerunPatterns 忽略处理状态, 强制重新处理匹配的文件, 规则为glob格式, 匹配文件名或者相对scan_dir的路径
+func WithRerunPatterns(ps []string) Option {
+	return func(c *config) {
+		c.RerunPatterns = ps
+	}
+}
+
+// WithFileNamingRule 影片文件名(不含扩展名)的命名规则, 语法与目录命名规则一致, 为空时使用番号+后缀
+func WithFileNamingRule(r string) Option {
+	return func(c *config) {
+		c.FileNaming = r
+	}
+}
+
+// WithCollisionPolicy 保存目标已经存在其他影片时的处理方式, 默认为skip
+func WithCollisionPolicy(p string) Option {
+	return func(c *config) {
+		c.CollisionPolicy = p
+	}
+}
+
+// WithDuplicatesDir 重复影片的保存目录
+func WithDuplicatesDir(dir string) Option {
+	return func(c *config) {
+		c.DuplicatesDir = dir
+	}
+}
+
+// WithOverrideFile 集中式覆盖配置文件, 文件不存在时忽略
+func WithOverrideFile(f string) Option {
+	return func(c *config) {
+		c.OverrideFile = f
+	}
+}
+
+// WithHooks 文件处理完成及运行结束后执行的hook
+func WithHooks(r *hook.Runner) Option {
+	return func(c *config) {
+		c.Hooks = r
+	}
+}
+
+// WithSteps 自定义处理流程, 为空则使用默认流程
+func WithSteps(steps []StepConfig) Option {
+	return func(c *config) {
+		c.Steps = steps
+	}
+}
+
+// WithReportDir 运行报告的保存目录, 为空则不生成报告文件
+func WithReportDir(dir string) Option {
+	return func(c *config) {
+		c.ReportDir = dir
+	}
+}
+
+// WithPlanDir dry-run模式下执行计划的保存目录
+func WithPlanDir(dir string) Option {
+	return func(c *config) {
+		c.PlanDir = dir
+	}
+}
+
+// WithJournal 记录每次运行中对文件系统的修改, 用于后续撤销
+func WithJournal(j journal.IJournal) Option {
+	return func(c *config) {
+		c.Journal = j
+	}
+}
+
+// WithTransfer 影片转移到保存目录的方式, 默认为移动
+func WithTransfer(t transfer.ITransfer) Option {
+	return func(c *config) {
+		c.Transfer = t
+	}
+}
+
+// WithCategoryTransfer 为特定分类的影片指定转移方式, 未指定的分类使用默认方式
+func WithCategoryTransfer(m map[model.Category]transfer.ITransfer) Option {
+	return func(c *config) {
+		c.CategoryTransfer = m
+	}
+}
@@ -0,0 +1,199 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+	"yamdc/model"
+	"yamdc/searcher/plugin/api"
+	"yamdc/store"
+
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultNotFoundRetryBase  = time.Hour
+	defaultNotFoundRetryMax   = 7 * 24 * time.Hour
+	defaultTransientRetryBase = 10 * time.Minute
+	defaultTransientRetryMax  = 24 * time.Hour
+)
+
+var errSearchNotFound = errors.New("search item not found")
+
+func retryDelay(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// notFoundRetryDelay 未搜索到番号时, 按照1h, 2h, 4h...的间隔重试, 最长间隔7天
+func notFoundRetryDelay(attempts int) time.Duration {
+	return retryDelay(attempts, defaultNotFoundRetryBase, defaultNotFoundRetryMax)
+}
+
+// transientRetryDelay 临时错误按照10m, 20m, 40m...的间隔重试, 最长间隔1天
+func transientRetryDelay(attempts int) time.Duration {
+	return retryDelay(attempts, defaultTransientRetryBase, defaultTransientRetryMax)
+}
+
+// isTransientError 网络错误, 被站点拦截或者限流(包括插件熔断)均为临时错误, 稍后重试通常可以恢复
+func isTransientError(err error) bool {
+	switch api.ErrorKind(err) {
+	case api.ErrKindNetwork, api.ErrKindBlocked, api.ErrKindRateLimited:
+		return true
+	}
+	return false
+}
+
+// checkFileState 根据上次的处理状态判断本次是否需要跳过, 文件发生变化时总是重新处理,
+// 非临时错误导致失败的文件需要通过--rerun重新处理
+func checkFileState(st *store.FileState, size int64, modTime int64, now time.Time) (bool, string) {
+	if st == nil || st.Size != size || st.ModTime != modTime {
+		return false, ""
+	}
+	switch st.Status {
+	case store.FileStatusSuccess:
+		return true, "already processed"
+	case store.FileStatusNotFound, store.FileStatusRetry:
+		if now.UnixMilli() < st.NextRetryAt {
+			return true, "wait for next retry"
+		}
+	case store.FileStatusFailed:
+		return true, "failed before, use --rerun to retry"
+	}
+	return false, ""
+}
+
+func buildFileState(prev *store.FileState, path string, fi os.FileInfo, number string, err error, now time.Time) *store.FileState {
+	st := &store.FileState{
+		Path:     path,
+		Size:     fi.Size(),
+		ModTime:  fi.ModTime().UnixNano(),
+		Status:   store.FileStatusSuccess,
+		Number:   number,
+		UpdateAt: now.UnixMilli(),
+	}
+	if err == nil {
+		return st
+	}
+	st.Attempts = 1
+	if prev != nil && prev.Size == st.Size && prev.ModTime == st.ModTime {
+		st.Attempts = prev.Attempts + 1
+	}
+	st.LastError = err.Error()
+	switch {
+	case errors.Is(err, errSearchNotFound):
+		st.Status = store.FileStatusNotFound
+		st.NextRetryAt = now.Add(notFoundRetryDelay(st.Attempts)).UnixMilli()
+	case isTransientError(err):
+		st.Status = store.FileStatusRetry
+		st.NextRetryAt = now.Add(transientRetryDelay(st.Attempts)).UnixMilli()
+	default:
+		st.Status = store.FileStatusFailed
+	}
+	return st
+}
+
+func (c *Capture) isForceRerun(file string) bool {
+	rel, err := filepath.Rel(c.c.ScanDir, file)
+	if err != nil {
+		rel = file
+	}
+	for _, re := range c.rerun {
+		if matchAny(re, filepath.Base(file), filepath.ToSlash(rel), file) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(re *regexp.Regexp, cands ...string) bool {
+	for _, item := range cands {
+		if re.MatchString(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterByState 跳过已经处理成功, 尚未到重试时间或者之前因非临时错误失败的文件
+func (c *Capture) filterByState(ctx context.Context, files []string) []string {
+	if c.c.StateStore == nil {
+		return files
+	}
+	logger := logutil.GetLogger(ctx)
+	now := time.Now()
+	rs := make([]string, 0, len(files))
+	for _, file := range files {
+		if c.isForceRerun(file) {
+			logger.Info("force rerun file", zap.String("file", file))
+			rs = append(rs, file)
+			continue
+		}
+		fi, err := os.Stat(file)
+		if err != nil {
+			rs = append(rs, file)
+			continue
+		}
+		st, ok, err := c.c.StateStore.GetFileState(ctx, file)
+		if err != nil {
+			logger.Error("read file state failed", zap.String("file", file), zap.Error(err))
+			rs = append(rs, file)
+			continue
+		}
+		if !ok {
+			rs = append(rs, file)
+			continue
+		}
+		if skip, reason := checkFileState(st, fi.Size(), fi.ModTime().UnixNano(), now); skip {
+			logger.Info("skip file by state", zap.String("file", file), zap.String("reason", reason), zap.String("status", st.Status),
+				zap.Int("attempts", st.Attempts), zap.String("next_retry", time.UnixMilli(st.NextRetryAt).Format(time.DateTime)))
+			continue
+		}
+		rs = append(rs, file)
+	}
+	return rs
+}
+
+// saveFileState 记录本次处理结果, 成功移走的文件同样需要记录, 以便使用copy/link方式时跳过源文件;
+// quarantined为被移入隔离目录的文件, 状态记录到隔离后的路径下, 重试时沿用之前的尝试次数及重试时间
+func (c *Capture) saveFileState(ctx context.Context, fc *model.FileContext, err error, quarantined map[string]string) {
+	if c.c.StateStore == nil || c.c.DryRun || ctx.Err() != nil {
+		return
+	}
+	now := time.Now()
+	logger := logutil.GetLogger(ctx)
+	for _, target := range resolveMovieTargets(fc) {
+		prev, _, gerr := c.c.StateStore.GetFileState(ctx, target.Src)
+		if gerr != nil {
+			logger.Error("read file state failed", zap.String("file", target.Src), zap.Error(gerr))
+		}
+		file := target.Src
+		if dst, ok := quarantined[target.Src]; ok && dst != target.Src {
+			if derr := c.c.StateStore.DeleteFileState(ctx, target.Src); derr != nil {
+				logger.Error("delete file state failed", zap.String("file", target.Src), zap.Error(derr))
+			}
+			file = dst
+		}
+		fi, serr := os.Stat(file)
+		if serr != nil { //文件已经被移走
+			if derr := c.c.StateStore.DeleteFileState(ctx, file); derr != nil {
+				logger.Error("delete file state failed", zap.String("file", file), zap.Error(derr))
+			}
+			continue
+		}
+		st := buildFileState(prev, file, fi, fc.Number.GetNumberID(), err, now)
+		if perr := c.c.StateStore.PutFileState(ctx, st); perr != nil {
+			logger.Error("save file state failed", zap.String("file", file), zap.Error(perr))
+		}
+	}
+}
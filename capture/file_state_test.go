@@ -0,0 +1,146 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+	"yamdc/searcher/plugin/api"
+	"yamdc/store"
+	"yamdc/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotFoundRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Hour, notFoundRetryDelay(1))
+	assert.Equal(t, 2*time.Hour, notFoundRetryDelay(2))
+	assert.Equal(t, 8*time.Hour, notFoundRetryDelay(4))
+	assert.Equal(t, defaultNotFoundRetryMax, notFoundRetryDelay(100))
+}
+
+func TestFileState(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ABC-123.mp4")
+	assert.NoError(t, os.WriteFile(file, []byte("movie"), 0644))
+	fi, err := os.Stat(file)
+	assert.NoError(t, err)
+	now := time.Now()
+	size, mtime := fi.Size(), fi.ModTime().UnixNano()
+
+	//首次未找到
+	st := buildFileState(nil, file, fi, "ABC-123", fmt.Errorf("wrap:%w", errSearchNotFound), now)
+	assert.Equal(t, store.FileStatusNotFound, st.Status)
+	assert.Equal(t, 1, st.Attempts)
+	assert.Equal(t, now.Add(time.Hour).UnixMilli(), st.NextRetryAt)
+	skip, _ := checkFileState(st, size, mtime, now)
+	assert.True(t, skip)
+	skip, _ = checkFileState(st, size, mtime, now.Add(2*time.Hour))
+	assert.False(t, skip)
+	//文件发生变化, 需要重新处理
+	skip, _ = checkFileState(st, size+1, mtime, now)
+	assert.False(t, skip)
+
+	//再次未找到, 重试间隔翻倍
+	st = buildFileState(st, file, fi, "ABC-123", errSearchNotFound, now)
+	assert.Equal(t, 2, st.Attempts)
+	assert.Equal(t, now.Add(2*time.Hour).UnixMilli(), st.NextRetryAt)
+
+	//非临时错误需要通过--rerun重试
+	st = buildFileState(st, file, fi, "ABC-123", api.WrapError(api.ErrDecode, errors.New("invalid cover")), now)
+	assert.Equal(t, store.FileStatusFailed, st.Status)
+	assert.Equal(t, 3, st.Attempts)
+	skip, _ = checkFileState(st, size, mtime, now.Add(365*24*time.Hour))
+	assert.True(t, skip)
+	skip, _ = checkFileState(st, size+1, mtime, now)
+	assert.False(t, skip)
+
+	st = buildFileState(st, file, fi, "ABC-123", nil, now)
+	assert.Equal(t, store.FileStatusSuccess, st.Status)
+	assert.Equal(t, 0, st.Attempts)
+	skip, _ = checkFileState(st, size, mtime, now)
+	assert.True(t, skip)
+}
+
+func TestFileStateTransient(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ABC-123.mp4")
+	assert.NoError(t, os.WriteFile(file, []byte("movie"), 0644))
+	fi, err := os.Stat(file)
+	assert.NoError(t, err)
+	now := time.Now()
+	size, mtime := fi.Size(), fi.ModTime().UnixNano()
+
+	//网络错误按照退避间隔重试
+	st := buildFileState(nil, file, fi, "ABC-123", fmt.Errorf("search number failed, err:%w", api.WrapError(api.ErrNetwork, errors.New("timeout"))), now)
+	assert.Equal(t, store.FileStatusRetry, st.Status)
+	assert.Equal(t, now.Add(10*time.Minute).UnixMilli(), st.NextRetryAt)
+	skip, _ := checkFileState(st, size, mtime, now)
+	assert.True(t, skip)
+	skip, _ = checkFileState(st, size, mtime, now.Add(11*time.Minute))
+	assert.False(t, skip)
+
+	//插件熔断同样视为临时错误, 重试间隔翻倍
+	st = buildFileState(st, file, fi, "ABC-123", fmt.Errorf("plugin disabled by circuit breaker, err:%w", api.WrapError(api.ErrBlocked, errors.New("403"))), now)
+	assert.Equal(t, store.FileStatusRetry, st.Status)
+	assert.Equal(t, now.Add(20*time.Minute).UnixMilli(), st.NextRetryAt)
+	st = buildFileState(st, file, fi, "ABC-123", api.WrapError(api.ErrRateLimited, errors.New("429")), now)
+	assert.Equal(t, store.FileStatusRetry, st.Status)
+	assert.Equal(t, defaultTransientRetryMax, transientRetryDelay(100))
+
+	//未分类的错误不会自动重试
+	st = buildFileState(st, file, fi, "ABC-123", errors.New("write nfo failed"), now)
+	assert.Equal(t, store.FileStatusFailed, st.Status)
+	assert.Equal(t, int64(0), st.NextRetryAt)
+	skip, _ = checkFileState(st, size, mtime, now.Add(365*24*time.Hour))
+	assert.True(t, skip)
+}
+
+func TestForceRerun(t *testing.T) {
+	c := &Capture{c: &config{ScanDir: "/scan"}}
+	for _, item := range []string{"ABC-123*", "sub/**"} {
+		re, err := utils.CompileGlob(item)
+		assert.NoError(t, err)
+		c.rerun = append(c.rerun, re)
+	}
+	assert.True(t, c.isForceRerun("/scan/a/ABC-123.mp4"))
+	assert.True(t, c.isForceRerun("/scan/sub/x/DEF-456.mp4"))
+	assert.False(t, c.isForceRerun("/scan/a/DEF-456.mp4"))
+	c.rerun = []*regexp.Regexp{}
+	assert.False(t, c.isForceRerun("/scan/a/ABC-123.mp4"))
+}
+
+func TestSaveFileStateAfterQuarantine(t *testing.T) {
+	scanDir := t.TempDir()
+	failedDir := filepath.Join(t.TempDir(), "failed")
+	movie := filepath.Join(scanDir, "ABC-123.mp4")
+	assert.NoError(t, os.WriteFile(movie, []byte("movie"), 0644))
+	ss, ok := store.MustNewSqliteStorage(filepath.Join(t.TempDir(), "cache.db")).(store.IFileStateStore)
+	assert.True(t, ok)
+	c := &Capture{c: &config{ScanDir: scanDir, FailedDir: failedDir, StateStore: ss}}
+	ctx := context.Background()
+	fc := newMultiPartFc(movie, "ABC-123", "")
+	err := &StepError{Step: StepSearch, Err: errSearchNotFound}
+
+	qr := c.quarantineFile(ctx, fc, err, nil)
+	c.saveFileState(ctx, fc, err, qr.movedFiles())
+	_, exist, gerr := ss.GetFileState(ctx, movie)
+	assert.NoError(t, gerr)
+	assert.False(t, exist)
+	dst := filepath.Join(failedDir, "ABC-123.mp4")
+	st, exist, gerr := ss.GetFileState(ctx, dst)
+	assert.NoError(t, gerr)
+	assert.True(t, exist)
+	assert.Equal(t, store.FileStatusNotFound, st.Status)
+	assert.Equal(t, 1, st.Attempts)
+
+	//在隔离目录中重试时沿用之前的尝试次数
+	fc.FullFilePath = dst
+	qr = c.quarantineFile(ctx, fc, err, nil)
+	c.saveFileState(ctx, fc, err, qr.movedFiles())
+	st, _, gerr = ss.GetFileState(ctx, dst)
+	assert.NoError(t, gerr)
+	assert.Equal(t, 2, st.Attempts)
+}
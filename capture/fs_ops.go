@@ -0,0 +1,91 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"yamdc/hasher"
+	"yamdc/journal"
+
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+// 所有对保存目录的修改都需要经过这里, 以便记录到操作日志中, 支持后续撤销
+
+func (c *Capture) recordAction(ctx context.Context, act *journal.Action) {
+	if c.c.Journal == nil {
+		return
+	}
+	if err := c.c.Journal.AddAction(ctx, act); err != nil {
+		//记录失败不影响主流程, 但是该操作将无法被撤销
+		logutil.GetLogger(ctx).Error("record journal action failed", zap.String("action", string(act.Type)),
+			zap.String("src", act.Src), zap.String("dst", act.Dst), zap.Error(err))
+	}
+}
+
+func (c *Capture) recordFileAction(ctx context.Context, typ journal.ActionType, src string, dst string) {
+	if c.c.Journal == nil {
+		return
+	}
+	act, err := journal.NewAction(c.runID, typ, src, dst)
+	if err != nil {
+		logutil.GetLogger(ctx).Error("build journal action failed", zap.String("action", string(typ)), zap.String("dst", dst), zap.Error(err))
+		return
+	}
+	c.recordAction(ctx, act)
+}
+
+// mkdirAll 创建目录, 并记录实际新建的每一级目录
+func (c *Capture) mkdirAll(ctx context.Context, dir string) error {
+	missing := make([]string, 0, 4)
+	for cur := filepath.Clean(dir); ; cur = filepath.Dir(cur) {
+		if _, err := os.Stat(cur); err == nil {
+			break
+		}
+		missing = append(missing, cur)
+		if filepath.Dir(cur) == cur {
+			break
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	//从上往下记录, 撤销时倒序处理即可先删除子目录
+	for i := len(missing) - 1; i >= 0; i-- {
+		c.recordAction(ctx, &journal.Action{RunID: c.runID, Type: journal.ActionMkdir, Dst: missing[i]})
+	}
+	return nil
+}
+
+// writeFile 写入文件, 并记录文件的checksum; 覆盖已有文件前先进行备份, 备份失败时不会覆盖
+func (c *Capture) writeFile(ctx context.Context, target string, data []byte) error {
+	_, statErr := os.Stat(target)
+	var backup string
+	if statErr == nil && c.c.Journal != nil {
+		var err error
+		if backup, err = c.c.Journal.BackupFile(ctx, c.runID, target); err != nil {
+			return fmt.Errorf("backup file before overwrite failed, file:%s, err:%w", target, err)
+		}
+	}
+	if err := os.WriteFile(target, data, 0644); err != nil {
+		if len(backup) > 0 {
+			_ = os.Remove(backup)
+		}
+		return err
+	}
+	if c.c.Journal == nil {
+		return nil
+	}
+	act, err := journal.NewAction(c.runID, journal.ActionWrite, "", target)
+	if err != nil {
+		logutil.GetLogger(ctx).Error("build journal action failed", zap.String("dst", target), zap.Error(err))
+		return nil
+	}
+	act.Checksum = hasher.ToSha1Bytes(data)
+	act.Overwritten = statErr == nil
+	act.Backup = backup
+	c.recordAction(ctx, act)
+	return nil
+}
@@ -0,0 +1,59 @@
+package capture
+
+import (
+	"context"
+	"yamdc/hook"
+	"yamdc/model"
+
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+func buildHookFileInfo(fc *model.FileContext, qr *quarantineResult) *hook.FileInfo {
+	info := &hook.FileInfo{
+		Source:       fc.FullFilePath,
+		FileName:     fc.FileName,
+		Number:       fc.Number,
+		SaveDir:      fc.SaveDir,
+		SaveFileBase: fc.SaveFileBase,
+		Collision:    fc.Collision,
+		Meta:         fc.Meta,
+	}
+	if qr != nil {
+		info.Quarantined = qr.moved[fc.FullFilePath]
+		info.QuarantineRecord = qr.record
+	}
+	if len(fc.SaveDir) == 0 {
+		return info
+	}
+	for _, target := range resolveMovieTargets(fc) {
+		info.Targets = append(info.Targets, target.Dst)
+	}
+	return info
+}
+
+// fireFileHook 单个文件处理完成后触发hook, dry-run模式下不会触发; qr为失败后的隔离结果
+func (c *Capture) fireFileHook(ctx context.Context, fc *model.FileContext, err error, qr *quarantineResult) {
+	if c.c.Hooks == nil || c.c.DryRun {
+		return
+	}
+	p := &hook.Payload{Event: hook.EventFileSuccess, RunID: c.runID, File: buildHookFileInfo(fc, qr)}
+	if err != nil {
+		p.Event = hook.EventFileFailed
+		p.Error = err.Error()
+	}
+	if err := c.c.Hooks.Fire(ctx, p); err != nil {
+		logutil.GetLogger(ctx).Error("fire file hook failed", zap.String("file", fc.FullFilePath), zap.Error(err))
+	}
+}
+
+// fireRunHook 单次运行结束后触发hook, 附带完整的运行报告
+func (c *Capture) fireRunHook(ctx context.Context, rp *Report) {
+	if c.c.Hooks == nil || c.c.DryRun {
+		return
+	}
+	p := &hook.Payload{Event: hook.EventRunFinished, RunID: c.runID, Report: rp}
+	if err := c.c.Hooks.Fire(ctx, p); err != nil {
+		logutil.GetLogger(ctx).Error("fire run hook failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,41 @@
+package capture
+
+import "sync"
+
+type keyLockItem struct {
+	mu  sync.Mutex
+	ref int
+}
+
+// keyLocker 按key加锁, 相同key的调用方会被串行化, 不同key之间互不影响
+type keyLocker struct {
+	mu sync.Mutex
+	m  map[string]*keyLockItem
+}
+
+func newKeyLocker() *keyLocker {
+	return &keyLocker{m: make(map[string]*keyLockItem)}
+}
+
+// Lock 对key加锁, 返回对应的解锁函数
+func (l *keyLocker) Lock(key string) func() {
+	l.mu.Lock()
+	item, ok := l.m[key]
+	if !ok {
+		item = &keyLockItem{}
+		l.m[key] = item
+	}
+	item.ref++
+	l.mu.Unlock()
+
+	item.mu.Lock()
+	return func() {
+		item.mu.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		item.ref--
+		if item.ref == 0 {
+			delete(l.m, key)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package capture
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyLocker(t *testing.T) {
+	l := newKeyLocker()
+	var running int32
+	var maxRunning int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := l.Lock("abc")
+			defer unlock()
+			cur := atomic.AddInt32(&running, 1)
+			if cur > atomic.LoadInt32(&maxRunning) {
+				atomic.StoreInt32(&maxRunning, cur)
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), maxRunning)
+	assert.Equal(t, 0, len(l.m))
+
+	//不同的key之间不互斥
+	u1 := l.Lock("a")
+	u2 := l.Lock("b")
+	u1()
+	u2()
+}
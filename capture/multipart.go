@@ -0,0 +1,85 @@
+package capture
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"yamdc/model"
+)
+
+// movieTarget 影片文件的源路径及目标路径
+type movieTarget struct {
+	Src string
+	Dst string
+}
+
+// groupMultiPartFiles 将同一目录下相同番号的多个分段合并为一组,
+// 返回需要处理的文件列表, 多分段影片只保留第一个分段, 其余分段挂在其Parts上
+func groupMultiPartFiles(fcs []*model.FileContext) []*model.FileContext {
+	groups := make(map[string][]*model.FileContext)
+	for _, fc := range fcs {
+		if !fc.Number.GetIsMultiCD() {
+			continue
+		}
+		key := fc.Number.GetNumberID() + "|" + fc.Dir(0)
+		groups[key] = append(groups[key], fc)
+	}
+	rs := make([]*model.FileContext, 0, len(fcs))
+	for _, fc := range fcs {
+		if !fc.Number.GetIsMultiCD() {
+			rs = append(rs, fc)
+			continue
+		}
+		key := fc.Number.GetNumberID() + "|" + fc.Dir(0)
+		parts, ok := groups[key]
+		if !ok { //已经作为其他分段的一部分处理
+			continue
+		}
+		delete(groups, key)
+		if len(parts) == 1 { //只有一个分段的情况下, 当作普通影片处理
+			rs = append(rs, fc)
+			continue
+		}
+		sortParts(parts)
+		leader := parts[0]
+		for idx, part := range parts {
+			part.PartIndex = idx + 1
+			//任意分段带有中文字幕时, 整部影片都视为中文字幕
+			if part.Number.GetIsChineseSubtitle() && !leader.Number.GetIsChineseSubtitle() {
+				leader.Number.SetIsChineseSubtitle(true)
+				leader.SaveFileBase = leader.Number.GenerateFileName()
+			}
+		}
+		leader.Parts = parts
+		rs = append(rs, leader)
+	}
+	return rs
+}
+
+func sortParts(parts []*model.FileContext) {
+	sort.SliceStable(parts, func(i, j int) bool {
+		a, b := parts[i].Number.Episode, parts[j].Number.Episode
+		ai, aerr := strconv.Atoi(a)
+		bi, berr := strconv.Atoi(b)
+		if aerr == nil && berr == nil && ai != bi {
+			return ai < bi
+		}
+		if a != b {
+			return a < b
+		}
+		return parts[i].FileName < parts[j].FileName
+	})
+}
+
+// resolveMovieTargets 计算影片文件最终的保存位置, 多分段影片会使用-cdN的形式命名
+func resolveMovieTargets(fc *model.FileContext) []*movieTarget {
+	if !fc.IsMultiPart() {
+		return []*movieTarget{{Src: fc.FullFilePath, Dst: filepath.Join(fc.SaveDir, fc.SaveFileBase+fc.FileExt)}}
+	}
+	rs := make([]*movieTarget, 0, len(fc.Parts))
+	for _, part := range fc.Parts {
+		name := fc.Number.GenerateMultiCDSuffix(fc.SaveFileBase, part.PartIndex) + part.FileExt
+		rs = append(rs, &movieTarget{Src: part.FullFilePath, Dst: filepath.Join(fc.SaveDir, name)})
+	}
+	return rs
+}
@@ -0,0 +1,54 @@
+package capture
+
+import (
+	"path/filepath"
+	"testing"
+	"yamdc/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMultiPartFc(file string, number string, ep string) *model.FileContext {
+	return &model.FileContext{
+		FullFilePath: file,
+		FileName:     filepath.Base(file),
+		FileExt:      filepath.Ext(file),
+		SaveFileBase: number,
+		Number:       &model.Number{NumberId: number, Episode: ep},
+	}
+}
+
+func TestGroupMultiPartFiles(t *testing.T) {
+	fcs := []*model.FileContext{
+		newMultiPartFc("/scan/a/ABC-123-cd2.mp4", "ABC-123", "2"),
+		newMultiPartFc("/scan/a/ABC-123-cd1.mkv", "ABC-123", "1"),
+		newMultiPartFc("/scan/a/ABC-456.mp4", "ABC-456", ""),
+		newMultiPartFc("/scan/b/ABC-123-cd3.mp4", "ABC-123", "3"),
+		newMultiPartFc("/scan/a/ABC-789-B.mp4", "ABC-789", "B"),
+		newMultiPartFc("/scan/a/ABC-789-A.mp4", "ABC-789", "A"),
+	}
+	rs := groupMultiPartFiles(fcs)
+	assert.Equal(t, 4, len(rs))
+
+	leader := rs[0]
+	assert.Equal(t, "/scan/a/ABC-123-cd1.mkv", leader.FullFilePath)
+	assert.True(t, leader.IsMultiPart())
+	assert.Equal(t, 2, len(leader.Parts))
+	assert.Equal(t, 1, leader.Parts[0].PartIndex)
+	assert.Equal(t, 2, leader.Parts[1].PartIndex)
+
+	assert.Equal(t, "ABC-456", rs[1].Number.GetNumberID())
+	assert.False(t, rs[1].IsMultiPart())
+	//不同目录下的单个分段按普通影片处理
+	assert.Equal(t, "/scan/b/ABC-123-cd3.mp4", rs[2].FullFilePath)
+	assert.False(t, rs[2].IsMultiPart())
+	assert.Equal(t, "/scan/a/ABC-789-A.mp4", rs[3].FullFilePath)
+	assert.Equal(t, 2, len(rs[3].Parts))
+
+	leader.SaveDir = "/save/ABC-123"
+	targets := resolveMovieTargets(leader)
+	assert.Equal(t, []*movieTarget{
+		{Src: "/scan/a/ABC-123-cd1.mkv", Dst: "/save/ABC-123/ABC-123-cd1.mkv"},
+		{Src: "/scan/a/ABC-123-cd2.mp4", Dst: "/save/ABC-123/ABC-123-cd2.mp4"},
+	}, targets)
+}
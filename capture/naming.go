@@ -0,0 +1,88 @@
+package capture
+
+import (
+	"fmt"
+	"time"
+	"yamdc/model"
+	"yamdc/naming"
+	"yamdc/utils"
+)
+
+const (
+	defaultActorName = "佚名"
+	//单个目录名的最大字节数, 大多数文件系统的限制为255字节
+	maxDirComponentBytes = 240
+	//影片文件名的最大字节数, 需要给-cdN, -fanart.jpg, 字幕语言标记等后缀预留空间
+	maxFileBaseBytes = 200
+)
+
+// buildNamingVars 构建命名规则中可以使用的全部字段
+func buildNamingVars(fc *model.FileContext) map[string]interface{} {
+	meta := fc.Meta
+	if meta == nil {
+		meta = &model.AvMeta{}
+	}
+	ts := time.UnixMilli(meta.ReleaseDate)
+	actor := defaultActorName
+	firstActor := defaultActorName
+	if len(meta.Actors) > 0 {
+		actor = utils.BuildAuthorsName(meta.Actors, 256)
+		firstActor = meta.Actors[0]
+	}
+	cat := fc.Number.GetCategory().String()
+	if fc.Number.GetCategory() == model.CatDefault {
+		cat = ""
+	}
+	title := meta.Title
+	if meta.ExtInfo.TranslateInfo.Title.Enable && len(meta.ExtInfo.TranslateInfo.Title.TranslatedText) > 0 {
+		title = meta.ExtInfo.TranslateInfo.Title.TranslatedText
+	}
+	vars := map[string]interface{}{
+		NamingReleaseDate:     ts.Format(time.DateOnly),
+		NamingReleaseYear:     fmt.Sprintf("%d", ts.Year()),
+		NamingReleaseMonth:    fmt.Sprintf("%d", ts.Month()),
+		NamingReleaseDay:      fmt.Sprintf("%d", ts.Day()),
+		NamingActor:           actor,
+		NamingActors:          append([]string(nil), meta.Actors...),
+		NamingFirstActor:      firstActor,
+		NamingNumber:          fc.Number.GetNumberID(),
+		NamingFileName:        fc.Number.GenerateFileName(),
+		NamingStudio:          meta.Studio,
+		NamingLabel:           meta.Label,
+		NamingSeries:          meta.Series,
+		NamingTitle:           meta.Title,
+		NamingTitleTranslated: title,
+		NamingDirector:        meta.Director,
+		NamingCategory:        cat,
+		NamingGenres:          append([]string(nil), meta.Genres...),
+		NamingIs4K:            fc.Number.GetIs4K(),
+		NamingIsCnSub:         fc.Number.GetIsChineseSubtitle(),
+		NamingIsUncensored:    fc.Number.GetIsUncensorMovie(),
+		NamingIsLeaked:        fc.Number.GetIsLeak(),
+		NamingIsCracked:       fc.Number.IsCracked,
+	}
+	//字段中的`/`等字符不能被当作路径分隔符, 命名规则中的`/`才是
+	for k, v := range vars {
+		switch t := v.(type) {
+		case string:
+			vars[k] = utils.ReplaceForbiddenPathChars(t)
+		case []string:
+			for idx, item := range t {
+				t[idx] = utils.ReplaceForbiddenPathChars(item)
+			}
+		}
+	}
+	return vars
+}
+
+// parseNamingRule 解析命名规则, 并使用空数据试渲染一次, 提前暴露字段名错误
+func parseNamingRule(rule string) (*naming.Template, error) {
+	tpl, err := naming.Parse(rule)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tpl.Render(buildNamingVars(&model.FileContext{Number: &model.Number{}})); err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
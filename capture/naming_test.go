@@ -0,0 +1,77 @@
+package capture
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+	"yamdc/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveSaveDir(t *testing.T) {
+	fc := &model.FileContext{
+		SaveFileBase: "ABC-123-C",
+		Number:       &model.Number{NumberId: "ABC-123", IsCnSub: true, Cat: "FC2"},
+		Meta: &model.AvMeta{
+			Title:       "title",
+			Actors:      []string{"a", "b", "c"},
+			Studio:      "studio",
+			ReleaseDate: time.Date(2024, 3, 5, 0, 0, 0, 0, time.Local).UnixMilli(),
+		},
+	}
+	c := &Capture{c: &config{SaveDir: "/save"}}
+	var err error
+	c.namingTpl, err = parseNamingRule(defaultNamingRule)
+	assert.NoError(t, err)
+	assert.NoError(t, c.resolveSaveDir(fc))
+	assert.Equal(t, "/save/2024/a,b,c/ABC-123", fc.SaveDir)
+
+	c.namingTpl, err = parseNamingRule("{CATEGORY}/{STUDIO|upper}/{ACTORS|first:2|join:&}/{DATE}")
+	assert.NoError(t, err)
+	c.fileNamingTpl, err = parseNamingRule("{NUMBER}{if CNSUB}-C{end}{if 4K}-4K{end} {LABEL|default:nolabel}")
+	assert.NoError(t, err)
+	assert.NoError(t, c.resolveSaveDir(fc))
+	assert.Equal(t, "/save/FC2/STUDIO/a&b/2024-03-05", fc.SaveDir)
+	assert.Equal(t, "ABC-123-C nolabel", fc.SaveFileBase)
+
+	_, err = parseNamingRule("{YEAR}/{UNKNOWN}")
+	assert.Error(t, err)
+}
+
+func TestResolveSaveDirSanitize(t *testing.T) {
+	fc := &model.FileContext{
+		Number: &model.Number{NumberId: "ABC-123"},
+		Meta: &model.AvMeta{
+			Title:  strings.Repeat("标题", 100) + "...",
+			Actors: []string{"../../etc", "a/b"},
+			Studio: "CON",
+			Series: "..",
+		},
+	}
+	c := &Capture{c: &config{SaveDir: "/save"}}
+	var err error
+	c.namingTpl, err = parseNamingRule("{STUDIO}/{SERIES}/{ACTORS|join:/}/{TITLE}")
+	assert.NoError(t, err)
+	c.fileNamingTpl, err = parseNamingRule("{NUMBER}: {TITLE}")
+	assert.NoError(t, err)
+	assert.NoError(t, c.resolveSaveDir(fc))
+	assert.True(t, strings.HasPrefix(fc.SaveDir, "/save/_CON/..／..／etc/a／b/标题"), fc.SaveDir)
+	for _, item := range strings.Split(fc.SaveDir, "/") {
+		assert.True(t, len(item) <= maxDirComponentBytes)
+	}
+	assert.True(t, strings.HasPrefix(fc.SaveFileBase, "ABC-123： 标题"))
+	assert.True(t, len(fc.SaveFileBase) <= maxFileBaseBytes)
+	assert.True(t, utf8.ValidString(fc.SaveFileBase))
+
+	//模板本身包含`..`也不能逃逸出保存目录
+	c.namingTpl, err = parseNamingRule("../../{NUMBER}")
+	assert.NoError(t, err)
+	c.fileNamingTpl = nil
+	assert.NoError(t, c.resolveSaveDir(fc))
+	assert.Equal(t, "/save/ABC-123", fc.SaveDir)
+	c.namingTpl, err = parseNamingRule("{SERIES}")
+	assert.NoError(t, err)
+	assert.Error(t, c.resolveSaveDir(fc))
+}
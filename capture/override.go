@@ -0,0 +1,165 @@
+package capture
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"yamdc/model"
+	"yamdc/searcher"
+
+	"github.com/tailscale/hujson"
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+const (
+	overrideSidecarSuffix = ".yamdc.json"
+	overrideHashPrefix    = "sha1:"
+)
+
+// overrideSet 集中式覆盖配置, key可以是相对扫描目录的路径, 文件名或者sha1:<文件哈希>
+type overrideSet struct {
+	items   map[string]*model.Override
+	hasHash bool
+}
+
+func readOverrideFile(f string) (*model.Override, error) {
+	raw, err := os.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+	return readOverrideFileData(raw)
+}
+
+func readOverrideFileData(raw []byte) (*model.Override, error) {
+	raw, err := hujson.Standardize(raw)
+	if err != nil {
+		return nil, fmt.Errorf("standardize override file failed, err:%w", err)
+	}
+	o := &model.Override{}
+	if err := json.Unmarshal(raw, o); err != nil {
+		return nil, fmt.Errorf("decode override file failed, err:%w", err)
+	}
+	return o, nil
+}
+
+func loadOverrideSet(f string) (*overrideSet, error) {
+	rs := &overrideSet{items: make(map[string]*model.Override)}
+	if len(f) == 0 {
+		return rs, nil
+	}
+	raw, err := os.ReadFile(f)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return rs, nil
+		}
+		return nil, err
+	}
+	if raw, err = hujson.Standardize(raw); err != nil {
+		return nil, fmt.Errorf("standardize overrides failed, err:%w", err)
+	}
+	items := make(map[string]*model.Override)
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("decode overrides failed, err:%w", err)
+	}
+	for k, v := range items {
+		key := filepath.ToSlash(strings.TrimSpace(k))
+		if strings.HasPrefix(strings.ToLower(key), overrideHashPrefix) {
+			key = strings.ToLower(key)
+			rs.hasHash = true
+		}
+		rs.items[key] = v
+	}
+	return rs, nil
+}
+
+func fileSha1(f string) (string, error) {
+	file, err := os.Open(f)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha1.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func overrideSidecarFiles(file string) []string {
+	ext := filepath.Ext(file)
+	return []string{
+		strings.TrimSuffix(file, ext) + overrideSidecarSuffix,
+		file + overrideSidecarSuffix,
+	}
+}
+
+// findOverride 查找文件的覆盖配置, 同名的<file>.yamdc.json优先于集中式的覆盖配置
+func (c *Capture) findOverride(ctx context.Context, file string) *model.Override {
+	logger := logutil.GetLogger(ctx).With(zap.String("file", file))
+	for _, f := range overrideSidecarFiles(file) {
+		o, err := readOverrideFile(f)
+		if err == nil {
+			logger.Info("use override sidecar", zap.String("override", f))
+			return o
+		}
+		if !os.IsNotExist(err) {
+			logger.Error("read override sidecar failed, ignore", zap.String("override", f), zap.Error(err))
+		}
+	}
+	if c.overrides == nil || len(c.overrides.items) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, 3)
+	if rel, err := filepath.Rel(c.c.ScanDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		keys = append(keys, filepath.ToSlash(rel))
+	}
+	keys = append(keys, filepath.Base(file))
+	for _, k := range keys {
+		if o, ok := c.overrides.items[k]; ok {
+			logger.Info("use override from overrides file", zap.String("key", k))
+			return o
+		}
+	}
+	if !c.overrides.hasHash {
+		return nil
+	}
+	sum, err := fileSha1(file)
+	if err != nil {
+		logger.Error("calc file sha1 for override failed", zap.Error(err))
+		return nil
+	}
+	if o, ok := c.overrides.items[overrideHashPrefix+sum]; ok {
+		logger.Info("use override from overrides file", zap.String("key", overrideHashPrefix+sum))
+		return o
+	}
+	return nil
+}
+
+// applyOverrideMeta 刮削完成后使用覆盖配置修正元数据
+func applyOverrideMeta(ctx context.Context, fc *model.FileContext) error {
+	if fc.Override == nil {
+		return nil
+	}
+	if err := fc.Override.ApplyToMeta(fc.Meta); err != nil {
+		return fmt.Errorf("apply override meta failed, err:%w", err)
+	}
+	if len(fc.Override.Meta) > 0 {
+		logutil.GetLogger(ctx).Debug("meta overridden", zap.String("file", fc.FileName))
+	}
+	return nil
+}
+
+func searchContextWithOverride(ctx context.Context, fc *model.FileContext) context.Context {
+	if fc.Override == nil {
+		return ctx
+	}
+	ctx = searcher.WithPluginChain(ctx, fc.Override.Plugins)
+	return searcher.WithDetailURLs(ctx, fc.Override.DetailURLs)
+}
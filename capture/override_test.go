@@ -0,0 +1,82 @@
+package capture
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"yamdc/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindOverride(t *testing.T) {
+	scanDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(scanDir, "sub"), 0755))
+	files := map[string]string{
+		"sub/a.mp4": "a",
+		"b.mp4":     "b",
+		"c.mp4":     "c",
+		"d.mp4":     "d",
+	}
+	for name, data := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(scanDir, name), []byte(data), 0644))
+	}
+	//同名覆盖文件优先
+	assert.NoError(t, os.WriteFile(filepath.Join(scanDir, "b.yamdc.json"), []byte(`{"number": "SIDECAR-001"}`), 0644))
+	sum, err := fileSha1(filepath.Join(scanDir, "c.mp4"))
+	assert.NoError(t, err)
+	overrideFile := filepath.Join(t.TempDir(), "overrides.json")
+	assert.NoError(t, os.WriteFile(overrideFile, []byte(`{
+		//支持注释
+		"sub/a.mp4": {"number": "REL-001"},
+		"b.mp4": {"number": "NAME-001"},
+		"SHA1:`+sum+`": {"number": "HASH-001"},
+	}`), 0644))
+	set, err := loadOverrideSet(overrideFile)
+	assert.NoError(t, err)
+	assert.True(t, set.hasHash)
+	c := &Capture{c: &config{ScanDir: scanDir}, overrides: set}
+	ctx := context.Background()
+	tests := map[string]string{
+		"sub/a.mp4": "REL-001",
+		"b.mp4":     "SIDECAR-001",
+		"c.mp4":     "HASH-001",
+	}
+	for name, number := range tests {
+		o := c.findOverride(ctx, filepath.Join(scanDir, name))
+		if assert.NotNil(t, o, name) {
+			assert.Equal(t, number, o.Number)
+		}
+	}
+	assert.Nil(t, c.findOverride(ctx, filepath.Join(scanDir, "d.mp4")))
+}
+
+func TestLoadOverrideSetNotExist(t *testing.T) {
+	set, err := loadOverrideSet(filepath.Join(t.TempDir(), "overrides.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(set.items))
+}
+
+func TestApplyOverride(t *testing.T) {
+	raw := []byte(`{
+		"category": "fc2",
+		"flags": {"cnsub": true, "4k": false},
+		"meta": {"title": "fixed title", "actors": ["a", "b"]}
+	}`)
+	o, err := readOverrideFileData(raw)
+	assert.NoError(t, err)
+	n := &model.Number{NumberId: "ABC-123", Is4k: true, IsLeaked: true}
+	o.ApplyToNumber(n)
+	assert.Equal(t, model.CatFC2, n.GetCategory())
+	assert.True(t, n.GetIsChineseSubtitle())
+	assert.False(t, n.GetIs4K())
+	assert.True(t, n.GetIsLeak())
+
+	fc := &model.FileContext{Override: o, Meta: &model.AvMeta{Number: "ABC-123", Title: "old", Studio: "s"}}
+	assert.NoError(t, applyOverrideMeta(context.Background(), fc))
+	assert.Equal(t, "fixed title", fc.Meta.Title)
+	assert.Equal(t, []string{"a", "b"}, fc.Meta.Actors)
+	assert.Equal(t, "ABC-123", fc.Meta.Number)
+	assert.Equal(t, "s", fc.Meta.Studio)
+}
@@ -0,0 +1,147 @@
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"yamdc/model"
+)
+
+// PlanItem dry-run模式下, 单个文件预期会执行的动作
+type PlanItem struct {
+	Source       string           `json:"source"`
+	Number       *model.Number    `json:"number"`
+	ScrapeSource string           `json:"scrape_source"`
+	SaveDir      string           `json:"save_dir"`
+	Movie        string           `json:"movie"`
+	Parts        []*PlanPart      `json:"parts,omitempty"`
+	Sidecars     []*PlanPart      `json:"sidecars,omitempty"`
+	Images       []string         `json:"images"`
+	NFO          string           `json:"nfo"`
+	Collision    *model.Collision `json:"collision,omitempty"`
+	Error        string           `json:"error,omitempty"`
+}
+
+// PlanPart 多分段影片中单个分段或者附属文件的移动计划
+type PlanPart struct {
+	Source string `json:"source"`
+	Movie  string `json:"movie"`
+}
+
+// Plan dry-run模式下输出的完整计划
+type Plan struct {
+	CreateAt int64       `json:"create_at"`
+	Items    []*PlanItem `json:"items"`
+}
+
+type planRecorder struct {
+	mu    sync.Mutex
+	items []*PlanItem
+}
+
+func (r *planRecorder) Add(item *PlanItem) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items = append(r.items, item)
+}
+
+func (r *planRecorder) Build() *Plan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	items := make([]*PlanItem, len(r.items))
+	copy(items, r.items)
+	//并发处理下, 结果的顺序不固定, 这里按源文件重新排序
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Source < items[j].Source
+	})
+	return &Plan{CreateAt: time.Now().UnixMilli(), Items: items}
+}
+
+func buildPlanItem(fc *model.FileContext, err error) *PlanItem {
+	item := &PlanItem{
+		Source:    fc.FullFilePath,
+		Number:    fc.Number,
+		Collision: fc.Collision,
+	}
+	if err != nil {
+		item.Error = err.Error()
+	}
+	if fc.Meta != nil {
+		item.ScrapeSource = fc.Meta.ExtInfo.ScrapeInfo.Source
+	}
+	if len(fc.SaveDir) == 0 {
+		return item
+	}
+	item.SaveDir = fc.SaveDir
+	targets := resolveMovieTargets(fc)
+	item.Movie = targets[0].Dst
+	if fc.IsMultiPart() {
+		for _, target := range targets {
+			item.Parts = append(item.Parts, &PlanPart{Source: target.Src, Movie: target.Dst})
+		}
+	}
+	for _, target := range resolveSidecarTargets(fc) {
+		item.Sidecars = append(item.Sidecars, &PlanPart{Source: target.Src, Movie: target.Dst})
+	}
+	item.NFO = filepath.Join(fc.SaveDir, fc.SaveFileBase+".nfo")
+	for _, image := range collectImages(fc) {
+		item.Images = append(item.Images, filepath.Join(fc.SaveDir, image.Name))
+	}
+	return item
+}
+
+// WritePlanTable 以表格的形式输出计划, 便于人工检查
+func WritePlanTable(w io.Writer, p *Plan) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tNUMBER\tSCRAPE_SOURCE\tSAVE_DIR\tMOVIE\tIMAGES\tSTATUS")
+	for _, item := range p.Items {
+		number := ""
+		if item.Number != nil {
+			number = item.Number.GetNumberID()
+		}
+		status := "ok"
+		if item.Collision != nil {
+			status = "collision: " + item.Collision.Action
+		}
+		if len(item.Error) > 0 {
+			status = "failed: " + strings.ReplaceAll(item.Error, "\t", " ")
+		}
+		movie := filepath.Base(item.Movie)
+		if len(item.Parts) > 1 {
+			movie += fmt.Sprintf(" (+%d parts)", len(item.Parts)-1)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", item.Source, number, item.ScrapeSource, item.SaveDir, movie, len(item.Images), status)
+	}
+	return tw.Flush()
+}
+
+// WritePlanFile 将计划写入指定目录, 同时生成json及表格两种格式
+func WritePlanFile(dir string, p *Plan) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("make plan dir failed, err:%w", err)
+	}
+	base := filepath.Join(dir, "plan-"+time.UnixMilli(p.CreateAt).Format("20060102-150405"))
+	raw, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode plan failed, err:%w", err)
+	}
+	if err := os.WriteFile(base+".json", raw, 0644); err != nil {
+		return "", fmt.Errorf("write plan json failed, err:%w", err)
+	}
+	f, err := os.OpenFile(base+".txt", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", fmt.Errorf("open plan table file failed, err:%w", err)
+	}
+	defer f.Close()
+	if err := WritePlanTable(f, p); err != nil {
+		return "", fmt.Errorf("write plan table failed, err:%w", err)
+	}
+	return base + ".json", nil
+}
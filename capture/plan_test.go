@@ -0,0 +1,66 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"yamdc/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlan(t *testing.T) {
+	r := &planRecorder{}
+	r.Add(buildPlanItem(&model.FileContext{
+		FullFilePath: "/scan/b/ABC-123.mp4",
+		FileExt:      ".mp4",
+		SaveFileBase: "ABC-123",
+		SaveDir:      "/save/2024/ABC-123",
+		Number:       &model.Number{NumberId: "ABC-123"},
+		Meta: &model.AvMeta{
+			Cover:        &model.File{Name: "ABC-123-fanart.jpg"},
+			Poster:       &model.File{Name: "ABC-123-poster.jpg"},
+			SampleImages: []*model.File{{Name: "extrafanart/ABC-123-sample-0.jpg"}},
+			ExtInfo:      model.ExtInfo{ScrapeInfo: model.ScrapeInfo{Source: "javbus"}},
+		},
+	}, nil))
+	r.Add(buildPlanItem(&model.FileContext{
+		FullFilePath: "/scan/a/ABC-456.mp4",
+		Number:       &model.Number{NumberId: "ABC-456"},
+	}, errors.New("search item not found")))
+	p := r.Build()
+	assert.Equal(t, 2, len(p.Items))
+	assert.Equal(t, "/scan/a/ABC-456.mp4", p.Items[0].Source)
+	assert.Equal(t, "search item not found", p.Items[0].Error)
+	assert.Equal(t, 0, len(p.Items[0].Images))
+	item := p.Items[1]
+	assert.Equal(t, "javbus", item.ScrapeSource)
+	assert.Equal(t, "/save/2024/ABC-123/ABC-123.mp4", item.Movie)
+	assert.Equal(t, "/save/2024/ABC-123/ABC-123.nfo", item.NFO)
+	assert.Equal(t, []string{
+		"/save/2024/ABC-123/ABC-123-fanart.jpg",
+		"/save/2024/ABC-123/ABC-123-poster.jpg",
+		"/save/2024/ABC-123/extrafanart/ABC-123-sample-0.jpg",
+	}, item.Images)
+
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, WritePlanTable(buf, p))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 3, len(lines))
+	assert.Contains(t, lines[1], "failed: search item not found")
+
+	dir := t.TempDir()
+	f, err := WritePlanFile(dir, p)
+	assert.NoError(t, err)
+	raw, err := os.ReadFile(f)
+	assert.NoError(t, err)
+	decoded := &Plan{}
+	assert.NoError(t, json.Unmarshal(raw, decoded))
+	assert.Equal(t, 2, len(decoded.Items))
+	_, err = os.Stat(strings.TrimSuffix(f, filepath.Ext(f)) + ".txt")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,172 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"yamdc/journal"
+	"yamdc/model"
+	"yamdc/searcher"
+	"yamdc/utils"
+
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+const defaultQuarantineSuffix = ".yamdc-error.json"
+
+// 在这些步骤失败时, 影片仍然位于扫描目录中, 可以安全地移入隔离目录
+var quarantineSteps = map[string]struct{}{
+	StepSearch:     {},
+	StepMetaVerify: {},
+	StepNaming:     {},
+	StepImages:     {},
+	StepMove:       {},
+}
+
+// StepError 处理流程中某个步骤的错误
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step:%s failed, err:%v", e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// QuarantineRecord 隔离文件的失败原因, 与影片一同保存在隔离目录中
+type QuarantineRecord struct {
+	Source   string                    `json:"source"`
+	Files    []string                  `json:"files"`
+	Step     string                    `json:"step"`
+	Errors   []string                  `json:"errors"`
+	Number   *model.Number             `json:"number"`
+	Plugins  []*searcher.SearchAttempt `json:"plugins"`
+	RunID    string                    `json:"run_id"`
+	CreateAt int64                     `json:"create_at"`
+}
+
+// errorChain 将错误链展开, 便于排查具体是哪一层出错
+func errorChain(err error) []string {
+	rs := make([]string, 0, 4)
+	for ; err != nil; err = errors.Unwrap(err) {
+		if _, ok := err.(*StepError); ok {
+			continue
+		}
+		rs = append(rs, err.Error())
+	}
+	return rs
+}
+
+func isQuarantineFile(f string) bool {
+	return strings.HasSuffix(f, defaultQuarantineSuffix)
+}
+
+// quarantineResult 隔离的结果
+type quarantineResult struct {
+	moved  map[string]string //源文件到隔离位置的映射
+	record string            //失败记录的路径, 写入失败时为空
+}
+
+func (r *quarantineResult) movedFiles() map[string]string {
+	if r == nil {
+		return nil
+	}
+	return r.moved
+}
+
+// quarantineFile 将处理失败的影片(包括其他分段及附属文件)移入隔离目录, 并写入失败原因, 未隔离时返回nil
+func (c *Capture) quarantineFile(ctx context.Context, fc *model.FileContext, err error, attempts []*searcher.SearchAttempt) *quarantineResult {
+	if len(c.c.FailedDir) == 0 || c.c.DryRun || ctx.Err() != nil {
+		return nil
+	}
+	var se *StepError
+	if !errors.As(err, &se) {
+		return nil
+	}
+	if _, ok := quarantineSteps[se.Step]; !ok {
+		return nil
+	}
+	logger := logutil.GetLogger(ctx).With(zap.String("file", fc.FullFilePath), zap.String("step", se.Step))
+	rec := &QuarantineRecord{
+		Source:   fc.FullFilePath,
+		Step:     se.Step,
+		Errors:   errorChain(err),
+		Number:   fc.Number,
+		Plugins:  attempts,
+		RunID:    c.runID,
+		CreateAt: time.Now().UnixMilli(),
+	}
+	if err := c.mkdirAll(ctx, c.c.FailedDir); err != nil {
+		logger.Error("make failed dir failed", zap.Error(err))
+		return nil
+	}
+	srcs := make([]string, 0, len(fc.Parts)+len(fc.Sidecars))
+	for _, target := range resolveMovieTargets(fc) {
+		srcs = append(srcs, target.Src)
+	}
+	for _, target := range resolveSidecarTargets(fc) {
+		srcs = append(srcs, target.Src)
+	}
+	fm := utils.NewFileManager()
+	rs := &quarantineResult{moved: make(map[string]string, len(srcs))}
+	for _, src := range srcs {
+		dst, err := c.quarantineTarget(src)
+		if err != nil {
+			logger.Error("resolve quarantine target failed", zap.String("src", src), zap.Error(err))
+			continue
+		}
+		if dst != src {
+			if err := fm.Move(src, dst); err != nil {
+				logger.Error("move file to failed dir failed", zap.String("src", src), zap.Error(err))
+				continue
+			}
+			c.recordFileAction(ctx, journal.ActionMove, src, dst)
+		}
+		rs.moved[src] = dst
+		rec.Files = append(rec.Files, dst)
+	}
+	if len(rec.Files) == 0 {
+		return nil
+	}
+	raw, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		logger.Error("encode quarantine record failed", zap.Error(err))
+		return rs
+	}
+	if err := c.writeFile(ctx, rec.Files[0]+defaultQuarantineSuffix, raw); err != nil {
+		logger.Error("write quarantine record failed", zap.Error(err))
+		return rs
+	}
+	rs.record = rec.Files[0] + defaultQuarantineSuffix
+	logger.Info("file moved to failed dir", zap.String("dst", rec.Files[0]))
+	return rs
+}
+
+// quarantineTarget 计算文件在隔离目录中的位置, 已经位于隔离目录中的文件(重试失败)保持不动
+func (c *Capture) quarantineTarget(src string) (string, error) {
+	if filepath.Dir(src) == filepath.Clean(c.c.FailedDir) {
+		return src, nil
+	}
+	return uniqueTargetIn(c.c.FailedDir, filepath.Base(src))
+}
+
+// releaseQuarantine 重试成功后, 删除隔离目录中残留的失败记录
+func (c *Capture) releaseQuarantine(ctx context.Context, fc *model.FileContext) {
+	if len(c.c.FailedDir) == 0 || c.c.DryRun || filepath.Dir(fc.FullFilePath) != filepath.Clean(c.c.FailedDir) {
+		return
+	}
+	rec := fc.FullFilePath + defaultQuarantineSuffix
+	if err := os.Remove(rec); err != nil && !os.IsNotExist(err) {
+		logutil.GetLogger(ctx).Error("remove quarantine record failed", zap.String("file", rec), zap.Error(err))
+	}
+}
@@ -0,0 +1,60 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"yamdc/model"
+	"yamdc/searcher"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuarantineFile(t *testing.T) {
+	scanDir := t.TempDir()
+	failedDir := filepath.Join(t.TempDir(), "failed")
+	movie := filepath.Join(scanDir, "ABC-123.mp4")
+	assert.NoError(t, os.WriteFile(movie, []byte("movie"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(scanDir, "ABC-123.srt"), []byte("sub"), 0644))
+
+	c := &Capture{c: &config{ScanDir: scanDir, FailedDir: failedDir}, extMap: map[string]struct{}{".mp4": {}}}
+	fc := newMultiPartFc(movie, "ABC-123", "")
+	c.discoverSidecars(context.Background(), []*model.FileContext{fc})
+	err := &StepError{Step: "search", Err: fmt.Errorf("search number failed, err:%w", errors.New("timeout"))}
+	attempts := []*searcher.SearchAttempt{{Plugin: "javbus", Error: "timeout"}, {Plugin: "javdb"}}
+	qr := c.quarantineFile(context.Background(), fc, err, attempts)
+
+	_, statErr := os.Stat(movie)
+	assert.True(t, os.IsNotExist(statErr))
+	//hook中需要带上隔离后的位置
+	info := buildHookFileInfo(fc, qr)
+	assert.Equal(t, movie, info.Source)
+	assert.Equal(t, filepath.Join(failedDir, "ABC-123.mp4"), info.Quarantined)
+	assert.Equal(t, filepath.Join(failedDir, "ABC-123.mp4"+defaultQuarantineSuffix), info.QuarantineRecord)
+	raw, rerr := os.ReadFile(filepath.Join(failedDir, "ABC-123.mp4"+defaultQuarantineSuffix))
+	assert.NoError(t, rerr)
+	rec := &QuarantineRecord{}
+	assert.NoError(t, json.Unmarshal(raw, rec))
+	assert.Equal(t, "search", rec.Step)
+	assert.Equal(t, []string{"search number failed, err:timeout", "timeout"}, rec.Errors)
+	assert.Equal(t, "ABC-123", rec.Number.GetNumberID())
+	assert.Equal(t, 2, len(rec.Plugins))
+	assert.Equal(t, []string{filepath.Join(failedDir, "ABC-123.mp4"), filepath.Join(failedDir, "ABC-123.srt")}, rec.Files)
+
+	//重试成功后需要清理失败记录
+	fc.FullFilePath = filepath.Join(failedDir, "ABC-123.mp4")
+	c.releaseQuarantine(context.Background(), fc)
+	_, statErr = os.Stat(fc.FullFilePath + defaultQuarantineSuffix)
+	assert.True(t, os.IsNotExist(statErr))
+
+	//nfo等步骤失败时, 影片已经被移走, 不进行隔离
+	fc2 := newMultiPartFc(filepath.Join(scanDir, "ABC-456.mp4"), "ABC-456", "")
+	assert.NoError(t, os.WriteFile(fc2.FullFilePath, []byte("movie"), 0644))
+	assert.Nil(t, c.quarantineFile(context.Background(), fc2, &StepError{Step: "nfo", Err: errors.New("x")}, nil))
+	_, statErr = os.Stat(fc2.FullFilePath)
+	assert.NoError(t, statErr)
+}
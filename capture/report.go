@@ -0,0 +1,345 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"yamdc/model"
+	"yamdc/searcher"
+	"yamdc/searcher/plugin/api"
+)
+
+const (
+	ReportStatusSuccess = "success"
+	ReportStatusFailed  = "failed"
+	ReportStatusSkipped = "skipped" //保存目标已存在, 按冲突策略跳过
+)
+
+// StepTiming 单个处理步骤的耗时
+type StepTiming struct {
+	Name string `json:"name"`
+	Cost int64  `json:"cost"` //单位为毫秒
+}
+
+// ReportItem 单个文件的处理结果
+type ReportItem struct {
+	Source     string                    `json:"source"`
+	Number     string                    `json:"number"`
+	Status     string                    `json:"status"`
+	FailedStep string                    `json:"failed_step,omitempty"`
+	Error      string                    `json:"error,omitempty"`
+	Plugin     string                    `json:"plugin,omitempty"` //刮削成功的插件
+	Attempts   []*searcher.SearchAttempt `json:"attempts,omitempty"`
+	Steps      []*StepTiming             `json:"steps"`
+	Cost       int64                     `json:"cost"` //单位为毫秒
+	SaveDir    string                    `json:"save_dir,omitempty"`
+	Targets    []*PlanPart               `json:"targets,omitempty"`
+	Collision  *model.Collision          `json:"collision,omitempty"`
+}
+
+// Report 单次运行的完整报告
+type Report struct {
+	RunID   string        `json:"run_id"`
+	DryRun  bool          `json:"dry_run"`
+	StartAt int64         `json:"start_at"`
+	EndAt   int64         `json:"end_at"`
+	Total   int           `json:"total"`
+	Success int           `json:"success"`
+	Failed  int           `json:"failed"`
+	Skipped int           `json:"skipped"`
+	Broken  []string      `json:"broken_plugins,omitempty"` //页面解析失败的插件, 通常意味着站点改版, 插件需要更新
+	Items   []*ReportItem `json:"items"`
+}
+
+type reportRecorder struct {
+	mu      sync.Mutex
+	startAt time.Time
+	items   []*ReportItem
+}
+
+func newReportRecorder() *reportRecorder {
+	return &reportRecorder{startAt: time.Now()}
+}
+
+func (r *reportRecorder) Add(item *ReportItem) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items = append(r.items, item)
+}
+
+func (r *reportRecorder) Build(runID string, dryRun bool) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	items := make([]*ReportItem, len(r.items))
+	copy(items, r.items)
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Source < items[j].Source
+	})
+	rp := &Report{
+		RunID:   runID,
+		DryRun:  dryRun,
+		StartAt: r.startAt.UnixMilli(),
+		EndAt:   time.Now().UnixMilli(),
+		Total:   len(items),
+		Items:   items,
+	}
+	broken := make(map[string]struct{})
+	for _, item := range items {
+		for _, attempt := range item.Attempts {
+			if attempt.Kind == api.ErrKindDecode {
+				broken[attempt.Plugin] = struct{}{}
+			}
+		}
+		switch item.Status {
+		case ReportStatusSuccess:
+			rp.Success++
+		case ReportStatusFailed:
+			rp.Failed++
+		case ReportStatusSkipped:
+			rp.Skipped++
+		}
+	}
+	for name := range broken {
+		rp.Broken = append(rp.Broken, name)
+	}
+	sort.Strings(rp.Broken)
+	return rp
+}
+
+type stepTimingKeyType struct{}
+
+var defaultStepTimingKey = stepTimingKeyType{}
+
+type stepTimings struct {
+	mu    sync.Mutex
+	steps []*StepTiming
+}
+
+func (t *stepTimings) add(name string, cost time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.steps = append(t.steps, &StepTiming{Name: name, Cost: cost.Milliseconds()})
+}
+
+func (t *stepTimings) Steps() []*StepTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rs := make([]*StepTiming, len(t.steps))
+	copy(rs, t.steps)
+	return rs
+}
+
+// withStepTimings 在ctx中挂载步骤耗时记录
+func withStepTimings(ctx context.Context) (context.Context, *stepTimings) {
+	st := &stepTimings{}
+	return context.WithValue(ctx, defaultStepTimingKey, st), st
+}
+
+func recordStepTiming(ctx context.Context, name string, cost time.Duration) {
+	st, ok := ctx.Value(defaultStepTimingKey).(*stepTimings)
+	if !ok {
+		return
+	}
+	st.add(name, cost)
+}
+
+func buildReportItem(fc *model.FileContext, err error, cost time.Duration, steps []*StepTiming, attempts []*searcher.SearchAttempt) *ReportItem {
+	item := &ReportItem{
+		Source:    fc.FullFilePath,
+		Status:    ReportStatusSuccess,
+		Attempts:  attempts,
+		Steps:     steps,
+		Cost:      cost.Milliseconds(),
+		SaveDir:   fc.SaveDir,
+		Collision: fc.Collision,
+	}
+	if fc.Number != nil {
+		item.Number = fc.Number.GetNumberID()
+	}
+	if fc.Meta != nil {
+		item.Plugin = fc.Meta.ExtInfo.ScrapeInfo.Source
+	}
+	if err != nil {
+		item.Status = ReportStatusFailed
+		item.Error = err.Error()
+		var se *StepError
+		if errors.As(err, &se) {
+			item.FailedStep = se.Step
+			item.Error = se.Err.Error()
+		}
+		return item
+	}
+	if fc.Collision != nil && fc.Collision.Action == collisionActionSkip {
+		item.Status = ReportStatusSkipped
+		return item
+	}
+	if len(fc.SaveDir) == 0 {
+		return item
+	}
+	for _, target := range resolveMovieTargets(fc) {
+		item.Targets = append(item.Targets, &PlanPart{Source: target.Src, Movie: target.Dst})
+	}
+	for _, target := range resolveSidecarTargets(fc) {
+		item.Targets = append(item.Targets, &PlanPart{Source: target.Src, Movie: target.Dst})
+	}
+	return item
+}
+
+func formatCost(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).String()
+}
+
+func escapeMarkdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	s = strings.ReplaceAll(s, "\r", "")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+func reportItemTarget(item *ReportItem) string {
+	if len(item.Targets) == 0 {
+		return item.SaveDir
+	}
+	return item.Targets[0].Movie
+}
+
+func reportItemSteps(item *ReportItem) string {
+	rs := make([]string, 0, len(item.Steps))
+	for _, step := range item.Steps {
+		rs = append(rs, step.Name+":"+formatCost(step.Cost))
+	}
+	return strings.Join(rs, ", ")
+}
+
+// WriteReportMarkdown 以markdown表格的形式输出报告
+func WriteReportMarkdown(w io.Writer, rp *Report) error {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "# yamdc run report\n\n")
+	fmt.Fprintf(sb, "- run id: %s\n", rp.RunID)
+	fmt.Fprintf(sb, "- dry run: %t\n", rp.DryRun)
+	fmt.Fprintf(sb, "- start: %s\n", time.UnixMilli(rp.StartAt).Format(time.DateTime))
+	fmt.Fprintf(sb, "- cost: %s\n", formatCost(rp.Cost()))
+	fmt.Fprintf(sb, "- total: %d, success: %d, failed: %d, skipped: %d\n", rp.Total, rp.Success, rp.Failed, rp.Skipped)
+	if len(rp.Broken) > 0 {
+		fmt.Fprintf(sb, "- plugins probably broken: %s\n", strings.Join(rp.Broken, ", "))
+	}
+	fmt.Fprintf(sb, "\n")
+	fmt.Fprintf(sb, "|source|number|status|failed step|error|plugin|target|steps|\n")
+	fmt.Fprintf(sb, "|---|---|---|---|---|---|---|---|\n")
+	for _, item := range rp.Items {
+		fmt.Fprintf(sb, "|%s|%s|%s|%s|%s|%s|%s|%s|\n",
+			escapeMarkdownCell(item.Source),
+			escapeMarkdownCell(item.Number),
+			item.Status,
+			item.FailedStep,
+			escapeMarkdownCell(item.Error),
+			item.Plugin,
+			escapeMarkdownCell(reportItemTarget(item)),
+			reportItemSteps(item),
+		)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+var reportHTMLTpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"cost":   formatCost,
+	"target": reportItemTarget,
+	"steps":  reportItemSteps,
+	"join":   strings.Join,
+	"date": func(ts int64) string {
+		return time.UnixMilli(ts).Format(time.DateTime)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>yamdc run report {{.RunID}}</title>
+<style>
+body { font-family: sans-serif; margin: 20px; }
+table { border-collapse: collapse; width: 100%; font-size: 13px; }
+th, td { border: 1px solid #ccc; padding: 4px 6px; text-align: left; vertical-align: top; word-break: break-all; }
+th { background: #f0f0f0; }
+tr.failed { background: #fde8e8; }
+tr.skipped { background: #fdf6e3; }
+</style>
+</head>
+<body>
+<h1>yamdc run report</h1>
+<ul>
+<li>run id: {{.RunID}}</li>
+<li>dry run: {{.DryRun}}</li>
+<li>start: {{date .StartAt}}</li>
+<li>cost: {{cost .Cost}}</li>
+<li>total: {{.Total}}, success: {{.Success}}, failed: {{.Failed}}, skipped: {{.Skipped}}</li>
+{{- if .Broken}}
+<li>plugins probably broken: {{join .Broken ", "}}</li>
+{{- end}}
+</ul>
+<table>
+<tr><th>source</th><th>number</th><th>status</th><th>failed step</th><th>error</th><th>plugin</th><th>target</th><th>steps</th></tr>
+{{- range .Items}}
+<tr class="{{.Status}}"><td>{{.Source}}</td><td>{{.Number}}</td><td>{{.Status}}</td><td>{{.FailedStep}}</td><td>{{.Error}}</td><td>{{.Plugin}}</td><td>{{target .}}</td><td>{{steps .}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// Cost 运行耗时, 单位为毫秒
+func (r *Report) Cost() int64 {
+	return r.EndAt - r.StartAt
+}
+
+// WriteReportHTML 以html页面的形式输出报告
+func WriteReportHTML(w io.Writer, rp *Report) error {
+	return reportHTMLTpl.Execute(w, rp)
+}
+
+// WriteReportFile 将报告写入指定目录, 同时生成json, markdown及html三种格式
+func WriteReportFile(dir string, rp *Report) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("make report dir failed, err:%w", err)
+	}
+	base := filepath.Join(dir, "report-"+time.UnixMilli(rp.StartAt).Format("20060102-150405"))
+	raw, err := json.MarshalIndent(rp, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode report failed, err:%w", err)
+	}
+	if err := os.WriteFile(base+".json", raw, 0644); err != nil {
+		return "", fmt.Errorf("write report json failed, err:%w", err)
+	}
+	writers := []struct {
+		ext string
+		fn  func(io.Writer, *Report) error
+	}{
+		{".md", WriteReportMarkdown},
+		{".html", WriteReportHTML},
+	}
+	for _, item := range writers {
+		if err := writeReportWith(base+item.ext, rp, item.fn); err != nil {
+			return "", err
+		}
+	}
+	return base + ".json", nil
+}
+
+func writeReportWith(f string, rp *Report, fn func(io.Writer, *Report) error) error {
+	file, err := os.OpenFile(f, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open report file:%s failed, err:%w", f, err)
+	}
+	defer file.Close()
+	if err := fn(file, rp); err != nil {
+		return fmt.Errorf("write report file:%s failed, err:%w", f, err)
+	}
+	return nil
+}
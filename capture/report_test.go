@@ -0,0 +1,90 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"yamdc/model"
+	"yamdc/searcher"
+	"yamdc/searcher/plugin/api"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReport(t *testing.T) {
+	r := newReportRecorder()
+	r.Add(buildReportItem(&model.FileContext{
+		FullFilePath: "/scan/b/ABC-123.mp4",
+		FileExt:      ".mp4",
+		SaveFileBase: "ABC-123",
+		SaveDir:      "/save/ABC-123",
+		Number:       &model.Number{NumberId: "ABC-123"},
+		Meta:         &model.AvMeta{ExtInfo: model.ExtInfo{ScrapeInfo: model.ScrapeInfo{Source: "javbus"}}},
+	}, nil, 3*time.Second, []*StepTiming{{Name: "search", Cost: 2000}, {Name: "savedata", Cost: 1000}}, []*searcher.SearchAttempt{{Plugin: "javbus", Found: true}}))
+	r.Add(buildReportItem(&model.FileContext{
+		FullFilePath: "/scan/a/ABC-456.mp4",
+		Number:       &model.Number{NumberId: "ABC-456"},
+	}, &StepError{Step: "search", Err: errors.New("search | not found")}, time.Second, nil, []*searcher.SearchAttempt{{Plugin: "javbus", Error: "timeout"}, {Plugin: "javdb", Error: "decode failure", Kind: api.ErrKindDecode}}))
+	r.Add(buildReportItem(&model.FileContext{
+		FullFilePath: "/scan/c/ABC-789.mp4",
+		Number:       &model.Number{NumberId: "ABC-789"},
+		SaveDir:      "/save/ABC-789",
+		Collision:    &model.Collision{Policy: CollisionSkip, Action: collisionActionSkip, Existing: "/save/ABC-789/ABC-789.mp4"},
+	}, nil, time.Second, nil, nil))
+	rp := r.Build("run-1", false)
+	assert.Equal(t, 3, rp.Total)
+	assert.Equal(t, 1, rp.Success)
+	assert.Equal(t, 1, rp.Failed)
+	assert.Equal(t, 1, rp.Skipped)
+	assert.Equal(t, []string{"javdb"}, rp.Broken)
+
+	failed := rp.Items[0]
+	assert.Equal(t, ReportStatusFailed, failed.Status)
+	assert.Equal(t, "search", failed.FailedStep)
+	assert.Equal(t, "search | not found", failed.Error)
+	succ := rp.Items[1]
+	assert.Equal(t, ReportStatusSuccess, succ.Status)
+	assert.Equal(t, "javbus", succ.Plugin)
+	assert.Equal(t, int64(3000), succ.Cost)
+	assert.Equal(t, "/save/ABC-123/ABC-123.mp4", succ.Targets[0].Movie)
+	assert.Equal(t, ReportStatusSkipped, rp.Items[2].Status)
+	assert.Equal(t, 0, len(rp.Items[2].Targets))
+
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, WriteReportMarkdown(buf, rp))
+	assert.True(t, strings.Contains(buf.String(), "search \\| not found"))
+	assert.True(t, strings.Contains(buf.String(), "search:2s, savedata:1s"))
+	assert.True(t, strings.Contains(buf.String(), "plugins probably broken: javdb"))
+
+	dir := t.TempDir()
+	f, err := WriteReportFile(dir, rp)
+	assert.NoError(t, err)
+	base := strings.TrimSuffix(f, ".json")
+	html, err := os.ReadFile(base + ".html")
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(html), `<tr class="failed">`))
+	_, err = os.Stat(base + ".md")
+	assert.NoError(t, err)
+	assert.Equal(t, dir, filepath.Dir(f))
+}
+
+func TestRunWithoutFiles(t *testing.T) {
+	reportDir := filepath.Join(t.TempDir(), "report")
+	c, err := New(
+		WithScanDir(t.TempDir()),
+		WithSaveDir(t.TempDir()),
+		WithSeacher(searcher.NewGroup(nil)),
+		WithReportDir(reportDir),
+	)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, c.Run(context.Background()), errNoValidFiles)
+	//没有需要处理的文件时同样需要输出报告
+	files, err := filepath.Glob(filepath.Join(reportDir, "*.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(files))
+}
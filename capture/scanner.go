@@ -0,0 +1,201 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"yamdc/utils"
+
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+type scanner struct {
+	root          string
+	maxDepth      int
+	minFileSize   int64
+	followSymlink bool
+	minFileAge    time.Duration
+	globs         []*regexp.Regexp //不含`/`的规则, 匹配单个路径组件
+	pathGlobs     []*regexp.Regexp //含`/`的规则, 匹配相对scan_dir的完整路径
+	regexes       []*regexp.Regexp
+	skipDirs      map[string]struct{}
+	isMediaFile   func(f string) bool
+	fm            *utils.FileManager
+}
+
+func newScanner(c *config, isMediaFile func(f string) bool) (*scanner, error) {
+	s := &scanner{
+		root:          c.ScanDir,
+		maxDepth:      c.ScanMaxDepth,
+		minFileSize:   c.ScanMinFileSize,
+		followSymlink: c.ScanFollowSymlink,
+		minFileAge:    c.ScanMinFileAge,
+		skipDirs:      make(map[string]struct{}),
+		isMediaFile:   isMediaFile,
+		fm:            utils.NewFileManager(),
+	}
+	for _, item := range c.ScanExcludePatterns {
+		pattern := strings.Trim(filepath.ToSlash(item), "/")
+		if len(pattern) == 0 {
+			continue
+		}
+		re, err := utils.CompileGlob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("compile exclude pattern failed, pattern:%s, err:%w", item, err)
+		}
+		if strings.Contains(pattern, "/") {
+			s.pathGlobs = append(s.pathGlobs, re)
+			continue
+		}
+		s.globs = append(s.globs, re)
+	}
+	for _, item := range c.ScanExcludeRegexes {
+		re, err := regexp.Compile(item)
+		if err != nil {
+			return nil, fmt.Errorf("compile exclude regex failed, regex:%s, err:%w", item, err)
+		}
+		s.regexes = append(s.regexes, re)
+	}
+	//保存目录, 隔离目录及重复目录位于扫描目录下时, 避免把已经处理过的文件重新扫描出来
+	for _, dir := range []string{c.SaveDir, c.FailedDir, c.DuplicatesDir} {
+		if len(dir) == 0 {
+			continue
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			s.skipDirs[abs] = struct{}{}
+		}
+	}
+	return s, nil
+}
+
+func (s *scanner) isExcluded(rel string, isDir bool) bool {
+	for _, re := range s.globs {
+		if re.MatchString(path.Base(rel)) {
+			return true
+		}
+	}
+	candidates := []string{rel}
+	if isDir {
+		//目录需要额外补充`/`, 使得`**/sample/**`这类规则能够直接命中目录本身
+		candidates = append(candidates, rel+"/")
+	}
+	for _, cand := range candidates {
+		for _, re := range s.pathGlobs {
+			if re.MatchString(cand) {
+				return true
+			}
+		}
+		for _, re := range s.regexes {
+			if re.MatchString(cand) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Scan 递归扫描目录, 返回满足条件的媒体文件列表
+func (s *scanner) Scan(ctx context.Context) ([]string, error) {
+	rs := make([]string, 0, 20)
+	visited := make(map[string]struct{})
+	if real, err := filepath.EvalSymlinks(s.root); err == nil {
+		visited[real] = struct{}{}
+	}
+	if err := s.walk(ctx, s.root, "", 1, visited, &rs); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+func (s *scanner) walk(ctx context.Context, dir string, rel string, depth int, visited map[string]struct{}, rs *[]string) error {
+	entries, err := s.fm.ReadDirSafely(dir)
+	if err != nil {
+		return err
+	}
+	logger := logutil.GetLogger(ctx)
+	for _, entry := range entries {
+		fullPath := filepath.Join(dir, entry.Name())
+		// 处理文件名编码
+		normalizedPath := s.fm.NormalizePathForPlatform(fullPath)
+		relPath := path.Join(rel, entry.Name())
+
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			fi, err := os.Stat(normalizedPath)
+			if err != nil {
+				logger.Warn("read symlink target failed, skip", zap.String("file", normalizedPath), zap.Error(err))
+				continue
+			}
+			isDir = fi.IsDir()
+			//指向文件的软链始终处理, follow_symlink仅控制是否进入软链目录
+			if isDir && !s.followSymlink {
+				logger.Debug("skip symlink dir", zap.String("dir", normalizedPath))
+				continue
+			}
+		}
+		if s.isExcluded(relPath, isDir) {
+			logger.Debug("file excluded by rule", zap.String("file", normalizedPath), zap.Bool("dir", isDir))
+			continue
+		}
+		if isDir {
+			if s.maxDepth > 0 && depth+1 > s.maxDepth {
+				continue
+			}
+			if abs, err := filepath.Abs(normalizedPath); err == nil {
+				if _, ok := s.skipDirs[abs]; ok {
+					continue
+				}
+			}
+			//软链可能产生环, 这里通过真实路径去重
+			real, err := filepath.EvalSymlinks(normalizedPath)
+			if err != nil {
+				logger.Warn("resolve dir real path failed, skip", zap.String("dir", normalizedPath), zap.Error(err))
+				continue
+			}
+			if _, ok := visited[real]; ok {
+				continue
+			}
+			visited[real] = struct{}{}
+			if err := s.walk(ctx, normalizedPath, relPath, depth+1, visited, rs); err != nil {
+				//子目录读取失败不影响其他目录的扫描
+				logger.Error("read sub dir failed, skip", zap.String("dir", normalizedPath), zap.Error(err))
+			}
+			continue
+		}
+		if !s.isMediaFile(normalizedPath) {
+			continue
+		}
+		isExist, err := s.fm.IsExist(normalizedPath)
+		if err != nil {
+			return err
+		}
+		if !isExist {
+			// 1. 文件确实不存在了(曾经存在)
+			// 2. 文件存在,但是程序无法读取,测试出现过Mac NFS挂载的文件日文能看到,但是无法打开(识别),必须手动重命名后才能打开
+			logger.Error("程序文件无法识别该文件,请检查文件是否存在,如存在请手动命名再试", zap.String("file", normalizedPath))
+			continue
+		}
+		if s.minFileSize > 0 || s.minFileAge > 0 {
+			fi, err := os.Stat(normalizedPath)
+			if err != nil {
+				return err
+			}
+			if fi.Size() < s.minFileSize {
+				logger.Debug("file size too small, skip", zap.String("file", normalizedPath), zap.Int64("size", fi.Size()))
+				continue
+			}
+			if time.Since(fi.ModTime()) < s.minFileAge {
+				logger.Info("file is still being written, skip", zap.String("file", normalizedPath))
+				continue
+			}
+		}
+		*rs = append(*rs, normalizedPath)
+	}
+	return nil
+}
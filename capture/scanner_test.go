@@ -0,0 +1,91 @@
+package capture
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestFile(t *testing.T, f string, sz int) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(f), 0755))
+	assert.NoError(t, os.WriteFile(f, make([]byte, sz), 0644))
+}
+
+func scanTestDir(t *testing.T, c *config) []string {
+	cp := &Capture{extMap: map[string]struct{}{".mp4": {}}}
+	sc, err := newScanner(c, cp.isMediaFile)
+	assert.NoError(t, err)
+	files, err := sc.Scan(context.Background())
+	assert.NoError(t, err)
+	rs := make([]string, 0, len(files))
+	for _, f := range files {
+		rel, err := filepath.Rel(c.ScanDir, f)
+		assert.NoError(t, err)
+		rs = append(rs, filepath.ToSlash(rel))
+	}
+	sort.Strings(rs)
+	return rs
+}
+
+func TestScanRecursive(t *testing.T) {
+	root := t.TempDir()
+	scanDir := filepath.Join(root, "scan")
+	writeTestFile(t, filepath.Join(scanDir, "ABC-001.mp4"), 100)
+	writeTestFile(t, filepath.Join(scanDir, "ABC-001.txt"), 100)
+	writeTestFile(t, filepath.Join(scanDir, "Some.Release.Name", "ABC-123.mp4"), 100)
+	writeTestFile(t, filepath.Join(scanDir, "Some.Release.Name", "sample", "ABC-123-sample.mp4"), 100)
+	writeTestFile(t, filepath.Join(scanDir, "a", "b", "c", "ABC-456.mp4"), 100)
+	writeTestFile(t, filepath.Join(scanDir, "@eaDir", "ABC-789.mp4"), 100)
+	writeTestFile(t, filepath.Join(scanDir, "small", "ABC-999.mp4"), 10)
+	writeTestFile(t, filepath.Join(scanDir, "save", "ABC-000.mp4"), 100)
+
+	c := &config{
+		ScanDir: scanDir,
+		SaveDir: filepath.Join(scanDir, "save"),
+	}
+	assert.Equal(t, []string{
+		"@eaDir/ABC-789.mp4",
+		"ABC-001.mp4",
+		"Some.Release.Name/ABC-123.mp4",
+		"Some.Release.Name/sample/ABC-123-sample.mp4",
+		"a/b/c/ABC-456.mp4",
+		"small/ABC-999.mp4",
+	}, scanTestDir(t, c))
+
+	c.ScanExcludePatterns = []string{"**/sample/**", "@eaDir"}
+	c.ScanMinFileSize = 50
+	assert.Equal(t, []string{
+		"ABC-001.mp4",
+		"Some.Release.Name/ABC-123.mp4",
+		"a/b/c/ABC-456.mp4",
+	}, scanTestDir(t, c))
+
+	c.ScanMaxDepth = 2
+	c.ScanExcludeRegexes = []string{`(?i)release`}
+	assert.Equal(t, []string{
+		"ABC-001.mp4",
+	}, scanTestDir(t, c))
+}
+
+func TestScanSymlink(t *testing.T) {
+	root := t.TempDir()
+	scanDir := filepath.Join(root, "scan")
+	outDir := filepath.Join(root, "out")
+	writeTestFile(t, filepath.Join(scanDir, "ABC-001.mp4"), 100)
+	writeTestFile(t, filepath.Join(outDir, "ABC-002.mp4"), 100)
+	assert.NoError(t, os.Symlink(outDir, filepath.Join(scanDir, "link")))
+	//指向文件的软链不受follow_symlink影响
+	writeTestFile(t, filepath.Join(outDir, "ABC-003.mp4"), 100)
+	assert.NoError(t, os.Symlink(filepath.Join(outDir, "ABC-003.mp4"), filepath.Join(scanDir, "ABC-003.mp4")))
+	//环状软链
+	assert.NoError(t, os.Symlink(scanDir, filepath.Join(scanDir, "loop")))
+
+	c := &config{ScanDir: scanDir, SaveDir: filepath.Join(root, "save")}
+	assert.Equal(t, []string{"ABC-001.mp4", "ABC-003.mp4"}, scanTestDir(t, c))
+	c.ScanFollowSymlink = true
+	assert.Equal(t, []string{"ABC-001.mp4", "ABC-003.mp4", "link/ABC-002.mp4", "link/ABC-003.mp4"}, scanTestDir(t, c))
+}
@@ -0,0 +1,128 @@
+package capture
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"yamdc/model"
+
+	"github.com/samber/lo"
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+var defaultSubtitleSuffix = []string{".srt", ".ass", ".ssa", ".sub", ".idx", ".vtt", ".smi", ".sup"}
+var defaultArtworkSuffix = []string{".jpg", ".jpeg", ".png", ".webp"}
+
+// 已有图片与刮削生成的图片(如-poster.jpg, -fanart.jpg)同名时, 在扩展名前追加该标记, 避免互相覆盖
+const sidecarArtworkConflictTag = ".original"
+
+// 字幕文件语言标记中, 表示中文的部分
+var chineseSubtitleTags = []string{"zh", "zho", "chi", "chs", "cht", "sc", "tc", "cn", "gb", "big5", "chinese", "简体", "繁体", "简中", "繁中", "中文"}
+
+// discoverSidecars 查找与影片同目录且文件名以影片名开头的附属文件,
+// 同一附属文件匹配多个影片时(例如多CD), 归属于文件名最长的影片
+func (c *Capture) discoverSidecars(ctx context.Context, fcs []*model.FileContext) {
+	byDir := make(map[string][]*model.FileContext)
+	for _, fc := range fcs {
+		byDir[fc.Dir(0)] = append(byDir[fc.Dir(0)], fc)
+	}
+	for dir, movies := range byDir {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			logutil.GetLogger(ctx).Error("read dir for sidecar failed, skip", zap.String("dir", dir), zap.Error(err))
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || c.isMediaFile(entry.Name()) {
+				continue
+			}
+			sc, fc := matchSidecar(entry.Name(), movies)
+			if sc == nil {
+				continue
+			}
+			sc.FullFilePath = filepath.Join(dir, entry.Name())
+			fc.Sidecars = append(fc.Sidecars, sc)
+			if sc.IsChinese && !fc.Number.GetIsChineseSubtitle() {
+				logutil.GetLogger(ctx).Debug("chinese subtitle found, mark movie as cnsub", zap.String("file", fc.FileName), zap.String("subtitle", entry.Name()))
+				fc.Number.SetIsChineseSubtitle(true)
+			}
+		}
+	}
+}
+
+func matchSidecar(name string, movies []*model.FileContext) (*model.SidecarFile, *model.FileContext) {
+	ext := strings.ToLower(filepath.Ext(name))
+	isSubtitle := lo.Contains(defaultSubtitleSuffix, ext)
+	if !isSubtitle && !lo.Contains(defaultArtworkSuffix, ext) {
+		return nil, nil
+	}
+	noExt := name[:len(name)-len(ext)]
+	var matched *model.FileContext
+	var matchedBase string
+	for _, fc := range movies {
+		base := strings.TrimSuffix(fc.FileName, fc.FileExt)
+		if len(base) <= len(matchedBase) || !strings.HasPrefix(strings.ToLower(noExt), strings.ToLower(base)) {
+			continue
+		}
+		tag := noExt[len(base):]
+		if len(tag) > 0 && !strings.ContainsAny(tag[:1], ".-_ ") {
+			//ABC-1234.srt不能被识别为ABC-123的附属文件
+			continue
+		}
+		matched = fc
+		matchedBase = base
+	}
+	if matched == nil {
+		return nil, nil
+	}
+	sc := &model.SidecarFile{
+		Tag:        noExt[len(matchedBase):],
+		Ext:        filepath.Ext(name),
+		IsSubtitle: isSubtitle,
+	}
+	if isSubtitle {
+		sc.IsChinese = isChineseSubtitleTag(sc.Tag)
+	}
+	return sc, matched
+}
+
+func isChineseSubtitleTag(tag string) bool {
+	items := strings.FieldsFunc(strings.ToLower(tag), func(r rune) bool {
+		return strings.ContainsRune(".-_ ", r)
+	})
+	for _, item := range items {
+		if lo.Contains(chineseSubtitleTags, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSidecarTargets 计算附属文件最终的保存位置, 文件名与影片保持一致, 并保留原有的语言标记
+func resolveSidecarTargets(fc *model.FileContext) []*movieTarget {
+	parts := fc.Parts
+	if !fc.IsMultiPart() {
+		parts = []*model.FileContext{fc}
+	}
+	generated := make(map[string]struct{})
+	for _, image := range collectImages(fc) {
+		generated[strings.ToLower(filepath.Join(fc.SaveDir, image.Name))] = struct{}{}
+	}
+	rs := make([]*movieTarget, 0, len(fc.Sidecars))
+	for _, part := range parts {
+		base := fc.SaveFileBase
+		if fc.IsMultiPart() {
+			base = fc.Number.GenerateMultiCDSuffix(fc.SaveFileBase, part.PartIndex)
+		}
+		for _, sc := range part.Sidecars {
+			dst := filepath.Join(fc.SaveDir, base+sc.Tag+sc.Ext)
+			if _, ok := generated[strings.ToLower(dst)]; ok && !sc.IsSubtitle {
+				dst = filepath.Join(fc.SaveDir, base+sc.Tag+sidecarArtworkConflictTag+sc.Ext)
+			}
+			rs = append(rs, &movieTarget{Src: sc.FullFilePath, Dst: dst})
+		}
+	}
+	return rs
+}
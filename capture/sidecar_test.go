@@ -0,0 +1,55 @@
+package capture
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"yamdc/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscoverSidecars(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"ABC-123.mp4", "ABC-123.srt", "ABC-123.chs.ass", "ABC-123.idx", "ABC-123.sub", "ABC-123-poster.jpg",
+		"ABC-1234.mp4", "ABC-1234.en.srt", "ABC-123.txt",
+	} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644))
+	}
+	c := &Capture{extMap: map[string]struct{}{".mp4": {}}}
+	fc1 := newMultiPartFc(filepath.Join(dir, "ABC-123.mp4"), "ABC-123", "")
+	fc2 := newMultiPartFc(filepath.Join(dir, "ABC-1234.mp4"), "ABC-1234", "")
+	c.discoverSidecars(context.Background(), []*model.FileContext{fc1, fc2})
+
+	assert.Equal(t, 5, len(fc1.Sidecars))
+	assert.True(t, fc1.Number.GetIsChineseSubtitle())
+	assert.Equal(t, 1, len(fc2.Sidecars))
+	assert.Equal(t, ".en", fc2.Sidecars[0].Tag)
+	assert.False(t, fc2.Number.GetIsChineseSubtitle())
+
+	fc1.SaveFileBase = fc1.Number.GenerateFileName()
+	fc1.SaveDir = "/save/ABC-123"
+	fc1.Meta = &model.AvMeta{Cover: &model.File{}, Poster: &model.File{}}
+	assert.NoError(t, c.renameMetaField(fc1))
+	dsts := make([]string, 0, len(fc1.Sidecars))
+	for _, target := range resolveSidecarTargets(fc1) {
+		dsts = append(dsts, target.Dst)
+	}
+	assert.ElementsMatch(t, []string{
+		"/save/ABC-123/ABC-123-C.srt",
+		"/save/ABC-123/ABC-123-C.chs.ass",
+		"/save/ABC-123/ABC-123-C.idx",
+		"/save/ABC-123/ABC-123-C.sub",
+		"/save/ABC-123/ABC-123-C-poster.original.jpg", //与生成的海报同名
+	}, dsts)
+}
+
+func TestIsChineseSubtitleTag(t *testing.T) {
+	assert.True(t, isChineseSubtitleTag(".chs"))
+	assert.True(t, isChineseSubtitleTag(".zh-CN"))
+	assert.True(t, isChineseSubtitleTag("_简体"))
+	assert.False(t, isChineseSubtitleTag(".en"))
+	assert.False(t, isChineseSubtitleTag(""))
+}
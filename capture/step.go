@@ -0,0 +1,254 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"yamdc/model"
+
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+const (
+	StepSearch     = "search"
+	StepProcess    = "process"
+	StepMetaVerify = "metaverify"
+	StepNaming     = "naming"
+	StepDedup      = "dedup"
+	StepImages     = "images" //写入封面, 海报及样品图
+	StepMove       = "move"   //转移影片及附属文件
+	StepNFO        = "nfo"
+	StepSaveData   = "savedata" //旧版本的步骤名, 等同于images+move
+)
+
+const (
+	StepPolicyFail  = "fail"  //步骤失败时终止处理, 默认策略
+	StepPolicyWarn  = "warn"  //步骤失败时仅输出警告, 继续执行后续步骤
+	StepPolicyRetry = "retry" //步骤失败时重试, 重试次数耗尽后终止处理
+)
+
+var defaultStepRetryInterval = time.Second
+
+var defaultSteps = []string{StepSearch, StepProcess, StepMetaVerify, StepNaming, StepDedup, StepImages, StepMove, StepNFO}
+
+// 这些步骤是后续步骤的前提, 不允许禁用或者忽略错误
+var mandatorySteps = map[string]struct{}{
+	StepSearch: {},
+	StepNaming: {},
+}
+
+// 步骤的别名, 配置中的别名会被展开为对应的步骤, 并沿用别名的配置
+var stepAliases = map[string][]string{
+	StepSaveData: {StepImages, StepMove},
+}
+
+// 这些步骤失败时文件可能已经部分转移, 只允许使用fail策略
+var failOnlySteps = map[string]struct{}{
+	StepMove: {},
+}
+
+// 这些步骤依赖naming步骤生成的保存目录
+var saveDirSteps = map[string]struct{}{
+	StepDedup:  {},
+	StepImages: {},
+	StepMove:   {},
+	StepNFO:    {},
+}
+
+// StepConfig 处理流程中单个步骤的配置
+type StepConfig struct {
+	Name    string
+	Disable bool
+	Policy  string
+	Retry   int //policy为retry时的重试次数
+	Args    interface{}
+}
+
+// StepFunc 自定义步骤的处理函数
+type StepFunc func(ctx context.Context, fc *model.FileContext) error
+
+// StepCreatorFunc 自定义步骤的创建函数, args为配置中的步骤参数
+type StepCreatorFunc func(args interface{}) (StepFunc, error)
+
+var customSteps = make(map[string]StepCreatorFunc)
+
+// RegisterStep 注册自定义步骤, 注册后可以在pipeline配置中使用
+func RegisterStep(name string, fn StepCreatorFunc) {
+	customSteps[name] = fn
+}
+
+// Steps 返回全部可用的步骤名
+func Steps() []string {
+	rs := make([]string, 0, len(defaultSteps)+len(customSteps))
+	rs = append(rs, defaultSteps...)
+	for k := range customSteps {
+		rs = append(rs, k)
+	}
+	sort.Strings(rs[len(defaultSteps):])
+	return rs
+}
+
+type pipelineStep struct {
+	name   string
+	fn     fcProcessFunc
+	policy string
+	retry  int
+}
+
+func (c *Capture) builtinSteps() map[string]fcProcessFunc {
+	return map[string]fcProcessFunc{
+		StepSearch:     c.doSearch,
+		StepProcess:    c.doProcess,
+		StepMetaVerify: c.doMetaVerify,
+		StepNaming:     c.doNaming,
+		StepDedup:      c.doDedup,
+		StepImages:     c.doSaveImages,
+		StepMove:       c.doMove,
+		StepNFO:        c.doExport,
+	}
+}
+
+func isValidStepPolicy(p string) bool {
+	switch p {
+	case StepPolicyFail, StepPolicyWarn, StepPolicyRetry:
+		return true
+	}
+	return false
+}
+
+func expandStepAliases(cfgs []StepConfig) []StepConfig {
+	rs := make([]StepConfig, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		names, ok := stepAliases[cfg.Name]
+		if !ok {
+			rs = append(rs, cfg)
+			continue
+		}
+		for _, name := range names {
+			item := cfg
+			item.Name = name
+			rs = append(rs, item)
+		}
+	}
+	return rs
+}
+
+// buildPipeline 根据配置构建处理流程, 配置为空时使用默认流程
+func (c *Capture) buildPipeline(cfgs []StepConfig) ([]*pipelineStep, error) {
+	if len(cfgs) == 0 {
+		for _, name := range defaultSteps {
+			cfgs = append(cfgs, StepConfig{Name: name})
+		}
+	}
+	cfgs = expandStepAliases(cfgs)
+	builtin := c.builtinSteps()
+	rs := make([]*pipelineStep, 0, len(cfgs))
+	exist := make(map[string]struct{}, len(cfgs))
+	for _, cfg := range cfgs {
+		if _, ok := exist[cfg.Name]; ok {
+			return nil, fmt.Errorf("duplicate step:%s", cfg.Name)
+		}
+		exist[cfg.Name] = struct{}{}
+		_, isMandatory := mandatorySteps[cfg.Name]
+		if cfg.Disable {
+			if isMandatory {
+				return nil, fmt.Errorf("step:%s can not be disabled", cfg.Name)
+			}
+			continue
+		}
+		policy := cfg.Policy
+		if len(policy) == 0 {
+			policy = StepPolicyFail
+		}
+		if !isValidStepPolicy(policy) {
+			return nil, fmt.Errorf("invalid policy:%s for step:%s", policy, cfg.Name)
+		}
+		if isMandatory && policy == StepPolicyWarn {
+			return nil, fmt.Errorf("step:%s can not use policy:%s", cfg.Name, policy)
+		}
+		if _, ok := failOnlySteps[cfg.Name]; ok && policy != StepPolicyFail {
+			return nil, fmt.Errorf("step:%s only supports policy:%s", cfg.Name, StepPolicyFail)
+		}
+		if policy == StepPolicyRetry && cfg.Retry <= 0 {
+			return nil, fmt.Errorf("step:%s requires retry count > 0", cfg.Name)
+		}
+		fn, ok := builtin[cfg.Name]
+		if !ok {
+			cr, ok := customSteps[cfg.Name]
+			if !ok {
+				return nil, fmt.Errorf("step:%s not found", cfg.Name)
+			}
+			sfn, err := cr(cfg.Args)
+			if err != nil {
+				return nil, fmt.Errorf("create step:%s failed, err:%w", cfg.Name, err)
+			}
+			fn = fcProcessFunc(sfn)
+		}
+		rs = append(rs, &pipelineStep{name: cfg.Name, fn: fn, policy: policy, retry: cfg.Retry})
+	}
+	if err := verifyPipeline(rs); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+func verifyPipeline(steps []*pipelineStep) error {
+	for name := range mandatorySteps {
+		found := false
+		for _, step := range steps {
+			if step.name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("step:%s is required", name)
+		}
+	}
+	if steps[0].name != StepSearch {
+		return fmt.Errorf("step:%s must be the first step", StepSearch)
+	}
+	namingDone := false
+	for _, step := range steps {
+		if step.name == StepNaming {
+			namingDone = true
+			continue
+		}
+		if _, ok := saveDirSteps[step.name]; ok && !namingDone {
+			return fmt.Errorf("step:%s must be after step:%s", step.name, StepNaming)
+		}
+	}
+	return nil
+}
+
+// runStep 按照步骤的策略执行, 返回的错误需要终止后续处理
+func runStep(ctx context.Context, step *pipelineStep, fc *model.FileContext) error {
+	logger := logutil.GetLogger(ctx).With(zap.String("name", step.name))
+	var err error
+	for i := 0; i <= step.retry; i++ {
+		if i > 0 {
+			logger.Warn("step failed, retry", zap.Int("retry", i), zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(defaultStepRetryInterval):
+			}
+		}
+		err = step.fn(ctx, fc)
+		if err == nil || errors.Is(err, errSkipRemainingSteps) || step.policy != StepPolicyRetry {
+			break
+		}
+		//未找到是确定的结果, 重试也只会得到相同的结果
+		if errors.Is(err, errSearchNotFound) {
+			break
+		}
+	}
+	if err != nil && step.policy == StepPolicyWarn && !errors.Is(err, errSkipRemainingSteps) {
+		logger.Warn("step failed, ignore by policy", zap.Error(err))
+		return nil
+	}
+	return err
+}
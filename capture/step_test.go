@@ -0,0 +1,128 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"yamdc/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func stepNames(steps []*pipelineStep) []string {
+	rs := make([]string, 0, len(steps))
+	for _, step := range steps {
+		rs = append(rs, step.name)
+	}
+	return rs
+}
+
+func TestBuildPipeline(t *testing.T) {
+	c := &Capture{c: &config{}}
+	steps, err := c.buildPipeline(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultSteps, stepNames(steps))
+
+	//仅生成nfo, 不移动影片
+	steps, err = c.buildPipeline([]StepConfig{
+		{Name: StepSearch, Policy: StepPolicyRetry, Retry: 2},
+		{Name: StepNaming},
+		{Name: StepImages, Disable: true},
+		{Name: StepNFO, Policy: StepPolicyWarn},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{StepSearch, StepNaming, StepNFO}, stepNames(steps))
+	assert.Equal(t, 2, steps[0].retry)
+	assert.Equal(t, StepPolicyWarn, steps[2].policy)
+
+	//兼容旧版本的savedata步骤
+	steps, err = c.buildPipeline([]StepConfig{
+		{Name: StepSearch},
+		{Name: StepNaming},
+		{Name: StepSaveData},
+		{Name: StepNFO},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{StepSearch, StepNaming, StepImages, StepMove, StepNFO}, stepNames(steps))
+
+	invalids := [][]StepConfig{
+		{{Name: StepSearch, Disable: true}, {Name: StepNaming}},
+		{{Name: StepSearch, Policy: StepPolicyWarn}, {Name: StepNaming}},
+		{{Name: StepSearch}, {Name: StepNaming, Policy: "unknown"}},
+		{{Name: StepSearch}, {Name: StepNaming, Policy: StepPolicyRetry}},
+		{{Name: StepSearch}, {Name: StepMove}, {Name: StepNaming}},
+		{{Name: StepNaming}, {Name: StepSearch}},
+		{{Name: StepSearch}},
+		{{Name: StepSearch}, {Name: StepNaming}, {Name: StepNaming}},
+		{{Name: StepSearch}, {Name: StepNaming}, {Name: "not_exist"}},
+		{{Name: StepSearch}, {Name: StepNaming}, {Name: StepMove, Policy: StepPolicyWarn}},
+		{{Name: StepSearch}, {Name: StepNaming}, {Name: StepMove, Policy: StepPolicyRetry, Retry: 1}},
+		{{Name: StepSearch}, {Name: StepNaming}, {Name: StepSaveData, Policy: StepPolicyWarn}},
+		{{Name: StepSearch}, {Name: StepNaming}, {Name: StepSaveData}, {Name: StepMove}},
+	}
+	for _, cfgs := range invalids {
+		_, err := c.buildPipeline(cfgs)
+		assert.Error(t, err, "%+v", cfgs)
+	}
+}
+
+func TestCustomStep(t *testing.T) {
+	RegisterStep("test_mark", func(args interface{}) (StepFunc, error) {
+		tag := args.(string)
+		return func(ctx context.Context, fc *model.FileContext) error {
+			fc.Meta.Genres = append(fc.Meta.Genres, tag)
+			return nil
+		}, nil
+	})
+	defer delete(customSteps, "test_mark")
+	assert.Contains(t, Steps(), "test_mark")
+	c := &Capture{c: &config{}}
+	steps, err := c.buildPipeline([]StepConfig{{Name: StepSearch}, {Name: "test_mark", Args: "hello"}, {Name: StepNaming}})
+	assert.NoError(t, err)
+	fc := &model.FileContext{Meta: &model.AvMeta{}}
+	assert.NoError(t, runStep(context.Background(), steps[1], fc))
+	assert.Equal(t, []string{"hello"}, fc.Meta.Genres)
+}
+
+func TestRunStepPolicy(t *testing.T) {
+	old := defaultStepRetryInterval
+	defaultStepRetryInterval = time.Millisecond
+	defer func() {
+		defaultStepRetryInterval = old
+	}()
+	ctx := context.Background()
+	fc := &model.FileContext{}
+	cnt := 0
+	failTwice := func(ctx context.Context, fc *model.FileContext) error {
+		cnt++
+		if cnt <= 2 {
+			return errors.New("fail")
+		}
+		return nil
+	}
+	assert.Error(t, runStep(ctx, &pipelineStep{name: "x", fn: failTwice, policy: StepPolicyFail}, fc))
+	assert.Equal(t, 1, cnt)
+
+	cnt = 0
+	assert.NoError(t, runStep(ctx, &pipelineStep{name: "x", fn: failTwice, policy: StepPolicyWarn}, fc))
+	assert.Equal(t, 1, cnt)
+
+	cnt = 0
+	assert.NoError(t, runStep(ctx, &pipelineStep{name: "x", fn: failTwice, policy: StepPolicyRetry, retry: 2}, fc))
+	assert.Equal(t, 3, cnt)
+
+	//未找到不重试
+	cnt = 0
+	notFound := func(ctx context.Context, fc *model.FileContext) error {
+		cnt++
+		return errSearchNotFound
+	}
+	assert.ErrorIs(t, runStep(ctx, &pipelineStep{name: "x", fn: notFound, policy: StepPolicyRetry, retry: 2}, fc), errSearchNotFound)
+	assert.Equal(t, 1, cnt)
+
+	skip := func(ctx context.Context, fc *model.FileContext) error {
+		return errSkipRemainingSteps
+	}
+	assert.ErrorIs(t, runStep(ctx, &pipelineStep{name: "x", fn: skip, policy: StepPolicyWarn}, fc), errSkipRemainingSteps)
+}
@@ -5,15 +5,20 @@ import (
 	"errors"
 	"flag"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/tailscale/hujson"
 	"github.com/xxxsen/common/logger"
 )
 
 var (
-	globalConfig *Config
+	globalConfig atomic.Pointer[Config] //常驻模式下会被Reload替换, 读取时总是获取完整的配置
 	once         sync.Once
+	cliDryRun    bool //命令行指定的dry-run, 重新加载配置时需要保留
+	cliRetry     bool
+	cliRerun     []string
 )
 
 type CategoryPlugin struct {
@@ -37,11 +42,95 @@ type NetworkConfig struct {
 	Proxy   string `json:"proxy"`
 }
 
+type ScanConfig struct {
+	MaxDepth        int      `json:"max_depth"`        //最大扫描深度, 0为不限制, 1为仅扫描scan_dir顶层
+	ExcludePatterns []string `json:"exclude_patterns"` //glob格式的排除规则, 支持`**`, 例如: `**/sample/**`, `@eaDir`
+	ExcludeRegexes  []string `json:"exclude_regexes"`  //正则格式的排除规则, 匹配相对scan_dir的路径
+	MinFileSize     int64    `json:"min_file_size"`    //最小文件大小, 单位为MB, 用于跳过预告片/样片
+	FollowSymlink   bool     `json:"follow_symlink"`   //是否进入软链接目录, 指向文件的软链接始终会被处理
+}
+
+type TransferConfig struct {
+	Mode          string            `json:"mode"`           //影片转移方式: move, copy, hardlink, symlink, relsymlink, reflink, 为空时根据link mode决定使用move或者symlink
+	Fallback      string            `json:"fallback"`       //hardlink/reflink因跨设备或者文件系统不支持而失败时使用的方式, 为空则直接报错
+	CategoryModes map[string]string `json:"category_modes"` //按分类指定转移方式, key为分类名, 例如: {"FC2": "hardlink"}
+}
+
+type CollisionConfig struct {
+	Policy        string `json:"policy"`         //保存目标已经存在时的处理方式: skip, keep_both, replace_if_better, move_to_duplicates
+	DuplicatesDir string `json:"duplicates_dir"` //重复影片的保存目录
+}
+
+type PipelineStep struct {
+	Name    string      `json:"name"`    //步骤名, 内置步骤: search, process, metaverify, naming, dedup, images, move, nfo
+	Disable bool        `json:"disable"` //是否禁用该步骤, search及naming不允许禁用
+	Policy  string      `json:"policy"`  //步骤失败时的处理策略: fail, warn, retry, 默认为fail
+	Retry   int         `json:"retry"`   //policy为retry时的重试次数
+	Args    interface{} `json:"args"`    //自定义步骤的参数
+}
+
+type HookConfig struct {
+	Name          string            `json:"name"`
+	Type          string            `json:"type"`           //hook类型: exec, webhook
+	Events        []string          `json:"events"`         //触发的事件: file_success, file_failed, run_finished, 为空则全部触发
+	Command       []string          `json:"command"`        //exec类型的命令及参数, payload以json的形式写入stdin
+	URL           string            `json:"url"`            //webhook类型的地址, payload以json的形式POST到该地址
+	Headers       map[string]string `json:"headers"`        //webhook类型的额外请求头
+	Timeout       int64             `json:"timeout"`        //单次执行的超时时间, 单位为秒, 默认30秒
+	Retry         int               `json:"retry"`          //失败后的重试次数
+	RetryInterval int64             `json:"retry_interval"` //重试间隔, 单位为秒, 默认3秒
+}
+
+type SearchConfig struct {
+	Parallel         int             `json:"parallel"`       //同时查询的插件数, 小于等于1时按顺序查询
+	PluginTimeout    int64           `json:"plugin_timeout"` //并发模式下单个插件的超时时间, 单位为秒, 默认30秒
+	AcceptScore      int             `json:"accept_score"`   //并发模式下结果得分(0~100)达到该值后直接使用, 默认80
+	Merge            MergeConfig     `json:"merge"`
+	Retry            RetryConfig     `json:"retry"`
+	RateLimit        RateLimitConfig `json:"rate_limit"`
+	BreakerThreshold int             `json:"breaker_threshold"` //插件连续被拦截(403, cloudflare验证等)的次数达到该值后, 本次运行内不再使用, 默认3
+	MatchThreshold   *int            `json:"match_threshold"`   //结果与本地影片的匹配度(0~100)低于该值时丢弃并查询下一个插件, 默认60, 为0时不校验
+}
+
+type RetryConfig struct {
+	MaxRetry     *int  `json:"max_retry"`     //超时, 5xx及429等临时错误的最大重试次数, 默认2, 为0时不重试
+	BaseInterval int64 `json:"base_interval"` //首次重试的等待时间, 单位为毫秒, 默认1000, 之后按指数增长并加入随机抖动
+	MaxInterval  int64 `json:"max_interval"`  //单次等待的最大时间, 单位为毫秒, 默认30000, Retry-After超过该值时不再重试
+}
+
+type RateLimitRule struct {
+	Rate  float64 `json:"rate"`  //每秒允许的请求数, 例如0.5为每2秒一个请求
+	Burst int     `json:"burst"` //允许的突发请求数, 默认为1
+}
+
+type RateLimitConfig struct {
+	Plugins map[string]RateLimitRule `json:"plugins"` //按插件限流, key为插件名
+	Hosts   map[string]RateLimitRule `json:"hosts"`   //按域名限流, key为域名(含端口时需要带上端口), 对所有插件的请求生效
+}
+
+type MergeFieldConfig struct {
+	Strategy string   `json:"strategy"` //合并策略: first, union, longest
+	Plugins  []string `json:"plugins"`  //插件优先级, 未出现在列表中的插件按照插件链的顺序排在后面
+}
+
+type MergeConfig struct {
+	Enable     bool                        `json:"enable"`      //是否查询多个插件并按字段合并结果
+	MaxSources int                         `json:"max_sources"` //参与合并的最大来源数, 0为不限制
+	Fields     map[string]MergeFieldConfig `json:"fields"`      //字段的合并规则, key为字段名
+}
+
+type WatchConfig struct {
+	Enable         bool   `json:"enable"`          //是否以常驻模式运行, 也可以通过命令行参数--watch开启
+	Cron           string `json:"cron"`            //定时执行的cron表达式, 例如: `*/30 * * * *`, `@every 1h`, 为空则只在文件变化时执行
+	StableDuration int64  `json:"stable_duration"` //文件停止写入多久后才开始处理, 单位为秒
+}
+
 type Config struct {
 	ScanDir          string                 `json:"scan_dir"`
 	SaveDir          string                 `json:"save_dir"`
 	DataDir          string                 `json:"data_dir"`
 	Naming           string                 `json:"naming"`
+	FileNaming       string                 `json:"file_naming"` //影片文件名的命名规则, 为空则使用番号+后缀
 	PluginConfig     map[string]interface{} `json:"plugin_config"`
 	HandlerConfig    map[string]interface{} `json:"handler_config"`
 	Plugins          []string               `json:"plugins"`
@@ -52,6 +141,20 @@ type Config struct {
 	Dependencies     []Dependency           `json:"dependencies"`
 	NetworkConfig    NetworkConfig          `json:"network_config"`
 	RegexesToReplace [][]string             `json:"regexes_to_replace"` //在提取number前,需要忽略的正则,即匹配到了就会先将其移除后才会去匹配,比如一些广告字段或者域名
+	ScanConfig       ScanConfig             `json:"scan_config"`
+	Concurrency      int                    `json:"concurrency"` //同时处理的文件数, 相同番号或者相同保存目录的文件依旧会串行处理
+	DryRun           bool                   `json:"dry_run"`     //仅输出执行计划, 不对保存目录做任何修改, 也可以通过命令行参数--dry-run开启
+	TransferConfig   TransferConfig         `json:"transfer_config"`
+	WatchConfig      WatchConfig            `json:"watch_config"`
+	CollisionConfig  CollisionConfig        `json:"collision_config"`
+	SearchConfig     SearchConfig           `json:"search_config"`
+	Pipeline         []PipelineStep         `json:"pipeline"`      //自定义处理流程, 为空则使用默认流程
+	Hooks            []HookConfig           `json:"hooks"`         //文件处理完成及运行结束后执行的hook
+	FailedDir        string                 `json:"failed_dir"`    //处理失败的影片会被移入该目录, 为空则保留在扫描目录中
+	OverrideFile     string                 `json:"override_file"` //集中式的手动覆盖配置, 为空则使用data_dir/overrides.json
+	RetryFailed      bool                   `json:"-"`             //重新处理隔离目录中的影片, 通过命令行参数--retry-failed开启
+	RerunPatterns    []string               `json:"-"`             //忽略处理状态强制重新处理的文件, 通过命令行参数--rerun指定
+	ConfigFile       string                 `json:"-"`             //配置文件路径, 用于常驻模式下重新加载配置
 }
 
 func defaultConfig() *Config {
@@ -84,6 +187,19 @@ func defaultConfig() *Config {
 			"number_title",
 			"translater",
 		},
+		Concurrency: 1,
+		TransferConfig: TransferConfig{
+			Fallback: "copy",
+		},
+		CollisionConfig: CollisionConfig{
+			Policy: "skip",
+		},
+		WatchConfig: WatchConfig{
+			StableDuration: 10,
+		},
+		ScanConfig: ScanConfig{
+			ExcludePatterns: []string{"@eaDir", "#recycle"},
+		},
 		LogConfig: logger.LogConfig{
 			Level:   "info",
 			Console: true,
@@ -118,11 +234,60 @@ func Shared() *Config {
 	once.Do(func() {
 		var err error
 		conf := flag.String("config", "./config.json", "config file")
+		dryRun := flag.Bool("dry-run", false, "run the whole pipeline but only output a plan, nothing will be written to save dir")
+		watch := flag.Bool("watch", false, "run as daemon, watch scan dir and scrape new files automatically")
+		retry := flag.Bool("retry-failed", false, "retry files quarantined in failed dir")
+		rerun := flag.String("rerun", "", "force re-run files matching these comma separated glob patterns even if they were processed before, e.g. ABC-123*,sub/**")
 		flag.Parse()
-		globalConfig, err = Parse(*conf)
+		c, err := Parse(*conf)
 		if err != nil {
 			panic(errors.New("parse config failed, err:" + err.Error()))
 		}
+		c.ConfigFile = *conf
+		cliDryRun = *dryRun
+		cliRetry = *retry
+		c.RetryFailed = *retry
+		cliRerun = splitList(*rerun)
+		c.RerunPatterns = cliRerun
+		if *dryRun {
+			c.DryRun = true
+		}
+		if *watch {
+			c.WatchConfig.Enable = true
+		}
+		globalConfig.Store(c)
 	})
-	return globalConfig
+	return globalConfig.Load()
+}
+
+// Reload 重新读取配置文件, 命令行参数指定的选项会被保留, 生效后需要调用SetShared替换全局配置
+func Reload() (*Config, error) {
+	old := Shared()
+	c, err := Parse(old.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	c.ConfigFile = old.ConfigFile
+	c.DryRun = c.DryRun || cliDryRun
+	c.RetryFailed = cliRetry
+	c.RerunPatterns = cliRerun
+	c.WatchConfig.Enable = true
+	return c, nil
+}
+
+// SetShared 替换全局配置, 已经通过Shared获取的配置不受影响
+func SetShared(c *Config) {
+	globalConfig.Store(c)
+}
+
+func splitList(s string) []string {
+	rs := make([]string, 0, 4)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		rs = append(rs, item)
+	}
+	return rs
 }
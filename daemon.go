@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+	"yamdc/capture"
+	"yamdc/config"
+	"yamdc/journal"
+	"yamdc/searcher/plugin/domainpool"
+	"yamdc/watcher"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/robfig/cron/v3"
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+const defaultConfigReloadDelay = time.Second
+
+// daemon 常驻模式, 在文件变化或者定时任务触发时执行刮削, 同一时间只会有一个刮削任务在运行;
+// 刮削及重新加载都在主循环中执行, 每次刮削使用当时的刮削实例, 重新加载不会影响正在进行的刮削
+type daemon struct {
+	c           *config.Config
+	j           journal.IJournal //dry-run方式启动时为空
+	cap         *capture.Capture
+	trigger     chan string
+	w           *watcher.Watcher
+	stopWatcher context.CancelFunc
+}
+
+func runDaemon(ctx context.Context, c *config.Config, j journal.IJournal) error {
+	d := &daemon{c: c, j: j, trigger: make(chan string, 1)}
+	cap, err := buildDaemonRunner(c, j)
+	if err != nil {
+		return err
+	}
+	d.cap = cap
+	if err := d.startWatcher(ctx, c); err != nil {
+		return err
+	}
+	reload, err := d.watchConfig(ctx)
+	if err != nil {
+		//配置文件监听失败不影响刮削
+		logutil.GetLogger(ctx).Error("watch config file failed, config reload disabled", zap.Error(err))
+	}
+	cr, err := d.startCron()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		<-cr.Stop().Done()
+	}()
+	logutil.GetLogger(ctx).Info("daemon started, waiting for new files", zap.String("dir", c.ScanDir))
+	d.notify("startup")
+	for {
+		select {
+		case <-ctx.Done():
+			logutil.GetLogger(ctx).Info("daemon exit")
+			return nil
+		case files := <-d.w.Events():
+			logutil.GetLogger(ctx).Info("new files ready", zap.Strings("files", files))
+			d.notify("fsnotify")
+		case <-reload:
+			d.reload(ctx, cr)
+		case reason := <-d.trigger:
+			d.runOnce(ctx, d.cap, reason)
+		}
+	}
+}
+
+func stableDuration(c *config.Config) time.Duration {
+	return time.Duration(c.WatchConfig.StableDuration) * time.Second
+}
+
+func watchExcludeDirs(c *config.Config) []string {
+	return []string{c.SaveDir, c.DataDir, c.FailedDir, c.CollisionConfig.DuplicatesDir}
+}
+
+// startWatcher 按照配置创建目录监听并替换正在运行的监听, 创建失败时保留原有的监听
+func (d *daemon) startWatcher(ctx context.Context, c *config.Config) error {
+	w, err := watcher.New(c.ScanDir,
+		watcher.WithStableDuration(stableDuration(c)),
+		watcher.WithExcludeDirs(watchExcludeDirs(c)...),
+	)
+	if err != nil {
+		return fmt.Errorf("create scan dir watcher failed, err:%w", err)
+	}
+	wctx, cancel := context.WithCancel(ctx)
+	go func() {
+		if err := w.Run(wctx); err != nil && wctx.Err() == nil {
+			logutil.GetLogger(ctx).Error("scan dir watcher exit", zap.Error(err))
+		}
+	}()
+	if d.stopWatcher != nil {
+		d.stopWatcher()
+	}
+	d.w, d.stopWatcher = w, cancel
+	return nil
+}
+
+func isWatcherChanged(old, c *config.Config) bool {
+	return stableDuration(old) != stableDuration(c) || !slices.Equal(watchExcludeDirs(old), watchExcludeDirs(c))
+}
+
+// notify 触发一次刮削, 已经存在待执行的任务时直接合并
+func (d *daemon) notify(reason string) {
+	select {
+	case d.trigger <- reason:
+	default:
+	}
+}
+
+func buildDaemonRunner(c *config.Config, j journal.IJournal) (*capture.Capture, error) {
+	cap, err := buildRunner(c, j, capture.WithScanMinFileAge(stableDuration(c)))
+	if err != nil {
+		return nil, fmt.Errorf("build capture runner failed, err:%w", err)
+	}
+	return cap, nil
+}
+
+func (d *daemon) startCron() (*cron.Cron, error) {
+	cr := cron.New()
+	if len(d.c.WatchConfig.Cron) > 0 {
+		if _, err := cr.AddFunc(d.c.WatchConfig.Cron, func() { d.notify("cron") }); err != nil {
+			return nil, fmt.Errorf("parse cron expression failed, cron:%s, err:%w", d.c.WatchConfig.Cron, err)
+		}
+	}
+	cr.Start()
+	return cr, nil
+}
+
+func (d *daemon) runOnce(ctx context.Context, cap *capture.Capture, reason string) {
+	logger := logutil.GetLogger(ctx).With(zap.String("reason", reason))
+	logger.Info("daemon run start")
+	err := cap.Run(ctx)
+	domainpool.Default().LogState(ctx)
+	if err != nil {
+		logger.Error("daemon run failed", zap.Error(err))
+		return
+	}
+	logger.Info("daemon run finish")
+}
+
+// watchConfig 监听配置文件所在目录, 编辑器通常以替换文件的方式保存, 因此不能直接监听文件本身
+func (d *daemon) watchConfig(ctx context.Context) (<-chan struct{}, error) {
+	abs, err := filepath.Abs(d.c.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := fw.Add(filepath.Dir(abs)); err != nil {
+		_ = fw.Close()
+		return nil, err
+	}
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer fw.Close()
+		//保存过程中可能产生多个事件, 延迟一段时间后再重新加载
+		timer := time.NewTimer(time.Hour)
+		timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-fw.Events:
+				if !ok {
+					return
+				}
+				if ev.Name != abs || !(ev.Has(fsnotify.Write) || ev.Has(fsnotify.Create)) {
+					continue
+				}
+				timer.Reset(defaultConfigReloadDelay)
+			case err, ok := <-fw.Errors:
+				if !ok {
+					return
+				}
+				logutil.GetLogger(ctx).Error("config watcher error", zap.Error(err))
+			case <-timer.C:
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// reload 重新加载配置并重建插件, 处理器及目录监听, 加载失败时继续使用旧的配置
+// 目录, 数据目录及日志等配置需要重启后才能生效
+func (d *daemon) reload(ctx context.Context, cr *cron.Cron) {
+	logger := logutil.GetLogger(ctx)
+	c, err := config.Reload()
+	if err != nil {
+		logger.Error("reload config failed, keep using old config", zap.Error(err))
+		return
+	}
+	if c.ScanDir != d.c.ScanDir || c.SaveDir != d.c.SaveDir || c.DataDir != d.c.DataDir {
+		logger.Warn("scan_dir, save_dir and data_dir changes need restart to take effect")
+		c.ScanDir, c.SaveDir, c.DataDir = d.c.ScanDir, d.c.SaveDir, d.c.DataDir
+	}
+	j := d.j
+	if !c.DryRun && j == nil {
+		//以dry-run方式启动时没有打开操作日志, 关闭dry-run前需要先打开, 否则转移操作无法撤销
+		if j, err = journal.NewSqliteJournal(journalPath(c)); err != nil {
+			logger.Error("open journal for new config failed, keep using old config", zap.Error(err))
+			return
+		}
+		d.j = j
+	}
+	if err := setupHTTPClient(c); err != nil {
+		logger.Error("setup http client with new config failed", zap.Error(err))
+	}
+	cap, err := buildDaemonRunner(c, j)
+	if err != nil {
+		logger.Error("rebuild capture with new config failed, keep using old config", zap.Error(err))
+		return
+	}
+	if isWatcherChanged(d.c, c) {
+		if err := d.startWatcher(ctx, c); err != nil {
+			logger.Error("rebuild scan dir watcher failed, keep using old watcher", zap.Error(err))
+		}
+	}
+	d.c, d.cap = c, cap
+	config.SetShared(c)
+	for _, ent := range cr.Entries() {
+		cr.Remove(ent.ID)
+	}
+	if len(c.WatchConfig.Cron) > 0 {
+		if _, err := cr.AddFunc(c.WatchConfig.Cron, func() { d.notify("cron") }); err != nil {
+			logger.Error("parse new cron expression failed, cron disabled", zap.String("cron", c.WatchConfig.Cron), zap.Error(err))
+		}
+	}
+	logger.Info("config reloaded", zap.Strings("plugins", c.Plugins), zap.Strings("handlers", c.Handlers), zap.String("naming", c.Naming), zap.String("cron", c.WatchConfig.Cron))
+	//重建监听时尚未稳定的文件不会再次输出, 重新扫描一次
+	d.notify("reload")
+}
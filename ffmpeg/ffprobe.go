@@ -48,6 +48,25 @@ func (p *FFProbe) ReadDuration(ctx context.Context, file string) (float64, error
 	return duration, nil
 }
 
+// ReadResolution 读取第一条视频流的分辨率
+func (p *FFProbe) ReadResolution(ctx context.Context, file string) (int, int, error) {
+	cmd := exec.CommandContext(ctx, p.cmd, []string{"-i", file, "-select_streams", "v:0", "-show_entries", "stream=width,height", "-v", "quiet", "-of", "csv=s=x:p=0"}...)
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("call ffprobe to detect video resolution failed, err:%w", err)
+	}
+	resStr := strings.TrimSpace(string(output))
+	var width, height int
+	if _, err := fmt.Sscanf(resStr, "%dx%d", &width, &height); err != nil {
+		return 0, 0, fmt.Errorf("parse video resolution failed, resolution:%s, err:%w", resStr, err)
+	}
+	return width, height, nil
+}
+
 func ReadDuration(ctx context.Context, file string) (float64, error) {
 	return defaultFFProbe.ReadDuration(ctx, file)
 }
+
+func ReadResolution(ctx context.Context, file string) (int, int, error) {
+	return defaultFFProbe.ReadResolution(ctx, file)
+}
@@ -0,0 +1,45 @@
+package hook
+
+import "time"
+
+const (
+	defaultTimeout       = 30 * time.Second
+	defaultRetryInterval = 3 * time.Second
+)
+
+type config struct {
+	Events        []Event
+	Timeout       time.Duration
+	Retry         int
+	RetryInterval time.Duration
+}
+
+type Option func(c *config)
+
+// WithEvents 触发hook的事件, 为空时所有事件都会触发
+func WithEvents(evs ...Event) Option {
+	return func(c *config) {
+		c.Events = append(c.Events, evs...)
+	}
+}
+
+// WithTimeout 单次执行的超时时间
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.Timeout = d
+	}
+}
+
+// WithRetry 执行失败后的重试次数
+func WithRetry(n int) Option {
+	return func(c *config) {
+		c.Retry = n
+	}
+}
+
+// WithRetryInterval 重试的间隔
+func WithRetryInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.RetryInterval = d
+	}
+}
@@ -0,0 +1,83 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const maxOutputLength = 512
+
+type execAction struct {
+	cmd []string
+}
+
+// NewExecAction 执行外部命令, payload以json的形式写入stdin, 同时通过环境变量传递常用字段
+func NewExecAction(cmd []string) (IAction, error) {
+	if len(cmd) == 0 || len(cmd[0]) == 0 {
+		return nil, fmt.Errorf("no command")
+	}
+	return &execAction{cmd: cmd}, nil
+}
+
+func (a *execAction) Name() string {
+	return "exec"
+}
+
+func (a *execAction) Do(ctx context.Context, p *Payload) error {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("encode payload failed, err:%w", err)
+	}
+	cmd := exec.CommandContext(ctx, a.cmd[0], a.cmd[1:]...)
+	cmd.Stdin = bytes.NewReader(raw)
+	cmd.Env = append(os.Environ(), buildEnv(p)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("run command failed, output:%s, err:%w", truncateOutput(output), err)
+	}
+	return nil
+}
+
+func truncateOutput(output []byte) string {
+	s := strings.TrimSpace(string(output))
+	if len(s) > maxOutputLength {
+		s = s[:maxOutputLength] + "..."
+	}
+	return s
+}
+
+func buildEnv(p *Payload) []string {
+	env := map[string]string{
+		"YAMDC_EVENT":  string(p.Event),
+		"YAMDC_RUN_ID": p.RunID,
+		"YAMDC_ERROR":  p.Error,
+	}
+	if f := p.File; f != nil {
+		env["YAMDC_SOURCE"] = f.Source
+		env["YAMDC_SAVE_DIR"] = f.SaveDir
+		if f.Number != nil {
+			env["YAMDC_NUMBER"] = f.Number.GetNumberID()
+		}
+		if len(f.Targets) > 0 {
+			env["YAMDC_TARGET"] = f.Targets[0]
+		}
+		if len(f.Quarantined) > 0 {
+			env["YAMDC_QUARANTINED"] = f.Quarantined
+			env["YAMDC_QUARANTINE_RECORD"] = f.QuarantineRecord
+		}
+		if f.Meta != nil {
+			env["YAMDC_TITLE"] = f.Meta.Title
+			env["YAMDC_SCRAPE_SOURCE"] = f.Meta.ExtInfo.ScrapeInfo.Source
+		}
+	}
+	rs := make([]string, 0, len(env))
+	for k, v := range env {
+		rs = append(rs, k+"="+v)
+	}
+	return rs
+}
@@ -0,0 +1,139 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"yamdc/model"
+
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+type Event string
+
+const (
+	EventFileSuccess Event = "file_success" //单个文件处理成功
+	EventFileFailed  Event = "file_failed"  //单个文件处理失败
+	EventRunFinished Event = "run_finished" //单次运行结束
+)
+
+// FileInfo 触发hook时的文件信息
+type FileInfo struct {
+	Source           string           `json:"source"`
+	FileName         string           `json:"file_name"`
+	Number           *model.Number    `json:"number"`
+	SaveDir          string           `json:"save_dir,omitempty"`
+	SaveFileBase     string           `json:"save_file_base,omitempty"`
+	Targets          []string         `json:"targets,omitempty"`           //影片最终的保存位置
+	Quarantined      string           `json:"quarantined,omitempty"`       //处理失败后影片被移入隔离目录的位置
+	QuarantineRecord string           `json:"quarantine_record,omitempty"` //隔离目录中失败记录的路径
+	Collision        *model.Collision `json:"collision,omitempty"`
+	Meta             *model.AvMeta    `json:"meta,omitempty"`
+}
+
+// Payload 传递给hook的数据, exec类型的hook通过stdin读取, webhook类型的hook通过请求体读取
+type Payload struct {
+	Event  Event       `json:"event"`
+	RunID  string      `json:"run_id"`
+	File   *FileInfo   `json:"file,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	Report interface{} `json:"report,omitempty"` //仅run_finished事件存在
+}
+
+// IAction hook的具体执行动作
+type IAction interface {
+	Name() string
+	Do(ctx context.Context, p *Payload) error
+}
+
+type Hook struct {
+	c      *config
+	name   string
+	events map[Event]struct{}
+	action IAction
+}
+
+func New(name string, action IAction, opts ...Option) *Hook {
+	c := &config{
+		Timeout:       defaultTimeout,
+		RetryInterval: defaultRetryInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	h := &Hook{c: c, name: name, action: action, events: make(map[Event]struct{})}
+	for _, ev := range c.Events {
+		h.events[ev] = struct{}{}
+	}
+	return h
+}
+
+func (h *Hook) Name() string {
+	return h.name
+}
+
+func (h *Hook) Match(ev Event) bool {
+	if len(h.events) == 0 {
+		return true
+	}
+	_, ok := h.events[ev]
+	return ok
+}
+
+// Trigger 执行hook, 失败时按配置重试
+func (h *Hook) Trigger(ctx context.Context, p *Payload) error {
+	var err error
+	for i := 0; i <= h.c.Retry; i++ {
+		if i > 0 {
+			logutil.GetLogger(ctx).Warn("hook failed, retry", zap.String("hook", h.name), zap.Int("retry", i), zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(h.c.RetryInterval):
+			}
+		}
+		if err = h.doOnce(ctx, p); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (h *Hook) doOnce(ctx context.Context, p *Payload) error {
+	if h.c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.c.Timeout)
+		defer cancel()
+	}
+	return h.action.Do(ctx, p)
+}
+
+// Runner 管理全部的hook, 按照配置的顺序依次执行
+type Runner struct {
+	hooks []*Hook
+}
+
+func NewRunner(hooks ...*Hook) *Runner {
+	return &Runner{hooks: hooks}
+}
+
+// Fire 执行所有监听该事件的hook, hook之间互不影响, 返回全部失败的错误
+func (r *Runner) Fire(ctx context.Context, p *Payload) error {
+	if r == nil {
+		return nil
+	}
+	var errs []error
+	for _, h := range r.hooks {
+		if !h.Match(p.Event) {
+			continue
+		}
+		if err := h.Trigger(ctx, p); err != nil {
+			errs = append(errs, fmt.Errorf("hook:%s failed, err:%w", h.Name(), err))
+			continue
+		}
+		logutil.GetLogger(ctx).Debug("hook succ", zap.String("hook", h.Name()), zap.String("event", string(p.Event)))
+	}
+	return errors.Join(errs...)
+}
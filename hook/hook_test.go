@@ -0,0 +1,97 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"yamdc/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPayload(ev Event) *Payload {
+	return &Payload{
+		Event: ev,
+		RunID: "run-1",
+		File: &FileInfo{
+			Source:  "/scan/ABC-123.mp4",
+			Number:  &model.Number{NumberId: "ABC-123"},
+			SaveDir: "/save/ABC-123",
+			Targets: []string{"/save/ABC-123/ABC-123.mp4"},
+			Meta:    &model.AvMeta{Number: "ABC-123", Title: "title"},
+		},
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var cnt int32
+	var got *Payload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//第一次请求返回500, 用于验证重试
+		if atomic.AddInt32(&cnt, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "token", r.Header.Get("X-Token"))
+		raw, _ := io.ReadAll(r.Body)
+		got = &Payload{}
+		assert.NoError(t, json.Unmarshal(raw, got))
+	}))
+	defer srv.Close()
+	act, err := NewWebhookAction(srv.URL, map[string]string{"X-Token": "token"})
+	assert.NoError(t, err)
+	h := New("test", act, WithRetry(1), WithRetryInterval(time.Millisecond), WithEvents(EventFileSuccess))
+	r := NewRunner(h)
+	assert.NoError(t, r.Fire(context.Background(), testPayload(EventFileSuccess)))
+	assert.Equal(t, int32(2), cnt)
+	assert.Equal(t, "ABC-123", got.File.Number.GetNumberID())
+	//未监听的事件不会触发
+	assert.NoError(t, r.Fire(context.Background(), testPayload(EventFileFailed)))
+	assert.Equal(t, int32(2), cnt)
+}
+
+func TestWebhookTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+	}))
+	defer srv.Close()
+	act, err := NewWebhookAction(srv.URL, nil)
+	assert.NoError(t, err)
+	h := New("slow", act, WithTimeout(50*time.Millisecond))
+	err = NewRunner(h).Fire(context.Background(), testPayload(EventRunFinished))
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "hook:slow"))
+}
+
+func TestExec(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	act, err := NewExecAction([]string{"sh", "-c", `cat > "$0"; echo >> "$0"; echo "$YAMDC_EVENT $YAMDC_NUMBER $YAMDC_TARGET" >> "$0"`, out})
+	assert.NoError(t, err)
+	assert.NoError(t, NewRunner(New("exec", act)).Fire(context.Background(), testPayload(EventFileSuccess)))
+	raw, err := os.ReadFile(out)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	assert.Equal(t, 2, len(lines))
+	p := &Payload{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), p))
+	assert.Equal(t, "title", p.File.Meta.Title)
+	assert.Equal(t, "file_success ABC-123 /save/ABC-123/ABC-123.mp4", lines[1])
+
+	act, err = NewExecAction([]string{"sh", "-c", "echo oops; exit 1"})
+	assert.NoError(t, err)
+	err = NewRunner(New("fail", act)).Fire(context.Background(), testPayload(EventFileFailed))
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "oops"))
+}
@@ -0,0 +1,54 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+type webhookAction struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewWebhookAction 以POST的方式将payload发送到指定地址, 非2xx的响应视为失败
+func NewWebhookAction(link string, headers map[string]string) (IAction, error) {
+	if _, err := url.ParseRequestURI(link); err != nil {
+		return nil, fmt.Errorf("invalid webhook url:%s, err:%w", link, err)
+	}
+	return &webhookAction{url: link, headers: headers, client: &http.Client{}}, nil
+}
+
+func (a *webhookAction) Name() string {
+	return "webhook"
+}
+
+func (a *webhookAction) Do(ctx context.Context, p *Payload) error {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("encode payload failed, err:%w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(raw))
+	if err != nil {
+		return fmt.Errorf("make request failed, err:%w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range a.headers {
+		req.Header.Set(k, v)
+	}
+	rsp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request failed, err:%w", err)
+	}
+	defer rsp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(rsp.Body, 64*1024))
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		return fmt.Errorf("invalid http status code:%d", rsp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package journal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"yamdc/hasher"
+)
+
+// NewAction 构建操作记录, 并读取目标文件当前的状态, 用于撤销时检测文件是否被修改过
+func NewAction(runID string, typ ActionType, src string, dst string) (*Action, error) {
+	act := &Action{
+		RunID: runID,
+		Type:  typ,
+		Src:   src,
+		Dst:   dst,
+	}
+	if typ == ActionMkdir {
+		return act, nil
+	}
+	fi, err := os.Lstat(dst)
+	if err != nil {
+		return nil, fmt.Errorf("stat dst file failed, err:%w", err)
+	}
+	act.Size = fi.Size()
+	act.ModTime = fi.ModTime().UnixNano()
+	return act, nil
+}
+
+func checkUnmodified(act *Action) error {
+	fi, err := os.Lstat(act.Dst)
+	if err != nil {
+		return err
+	}
+	switch act.Type {
+	case ActionSymlink:
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("dst is no longer a symlink")
+		}
+		link, err := os.Readlink(act.Dst)
+		if err != nil {
+			return err
+		}
+		//相对路径的链接需要基于链接所在目录还原
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(act.Dst), link)
+		}
+		if absPath(link) != absPath(act.Src) {
+			return fmt.Errorf("symlink target changed, expect:%s, now:%s", act.Src, link)
+		}
+		return nil
+	case ActionWrite:
+		if fi.Size() != act.Size {
+			return fmt.Errorf("file size changed, expect:%d, now:%d", act.Size, fi.Size())
+		}
+		raw, err := os.ReadFile(act.Dst)
+		if err != nil {
+			return err
+		}
+		if sum := hasher.ToSha1Bytes(raw); sum != act.Checksum {
+			return fmt.Errorf("file checksum changed, expect:%s, now:%s", act.Checksum, sum)
+		}
+		return nil
+	default:
+		//影片文件可能很大, 这里只通过大小及修改时间判断
+		if fi.Size() != act.Size {
+			return fmt.Errorf("file size changed, expect:%d, now:%d", act.Size, fi.Size())
+		}
+		if fi.ModTime().UnixNano() != act.ModTime {
+			return fmt.Errorf("file mod time changed")
+		}
+		return nil
+	}
+}
+
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
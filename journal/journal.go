@@ -0,0 +1,67 @@
+package journal
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+type ActionType string
+
+const (
+	ActionMkdir    ActionType = "mkdir"    //创建目录
+	ActionMove     ActionType = "move"     //移动文件
+	ActionSymlink  ActionType = "symlink"  //创建软链接
+	ActionWrite    ActionType = "write"    //写入文件(图片, nfo等)
+	ActionCopy     ActionType = "copy"     //复制文件(包括reflink), 源文件保持不变
+	ActionHardlink ActionType = "hardlink" //创建硬链接
+)
+
+const (
+	RunStatusRunning     = "running"
+	RunStatusFinished    = "finished"
+	RunStatusUndone      = "undone"
+	RunStatusUndoPartial = "undo_partial"
+)
+
+// Action 单个文件系统操作记录
+type Action struct {
+	ID          int64      `json:"id"`
+	RunID       string     `json:"run_id"`
+	Type        ActionType `json:"type"`
+	Src         string     `json:"src"`
+	Dst         string     `json:"dst"`
+	Size        int64      `json:"size"`        //操作完成后目标文件的大小
+	ModTime     int64      `json:"mod_time"`    //操作完成后目标文件的修改时间, 单位为纳秒
+	Checksum    string     `json:"checksum"`    //写入文件的sha1, 仅write操作存在
+	Overwritten bool       `json:"overwritten"` //目标文件在写入前已经存在
+	Backup      string     `json:"backup"`      //被覆盖的文件在写入前的备份, 撤销时用于还原
+	Undone      bool       `json:"undone"`      //是否已经被撤销
+	CreateAt    int64      `json:"create_at"`
+}
+
+// Run 一次完整运行的记录
+type Run struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	StartAt     int64  `json:"start_at"`
+	EndAt       int64  `json:"end_at"`
+	ActionCount int64  `json:"action_count"`
+}
+
+type IJournal interface {
+	CreateRun(ctx context.Context, runID string) error
+	UpdateRunStatus(ctx context.Context, runID string, status string) error
+	GetRun(ctx context.Context, runID string) (*Run, bool, error)
+	ListRuns(ctx context.Context, limit int) ([]*Run, error)
+	AddAction(ctx context.Context, act *Action) error
+	ListActions(ctx context.Context, runID string) ([]*Action, error)
+	MarkActionUndone(ctx context.Context, id int64) error
+	BackupFile(ctx context.Context, runID string, path string) (string, error) //备份即将被覆盖的文件, 返回备份的路径
+}
+
+// NewRunID 生成运行id, 使用时间作为前缀方便人工辨认
+func NewRunID() string {
+	return fmt.Sprintf("%s-%04x", time.Now().Format("20060102-150405"), rand.Intn(0x10000))
+}
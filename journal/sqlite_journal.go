@@ -0,0 +1,201 @@
+package journal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/glebarez/go-sqlite"
+)
+
+type sqliteJournal struct {
+	db        *sql.DB
+	backupDir string
+}
+
+func (j *sqliteJournal) init() error {
+	sqls := []string{
+		`CREATE TABLE IF NOT EXISTS run_tab (
+			run_id TEXT PRIMARY KEY,
+			status TEXT,
+			start_at INTEGER,
+			end_at INTEGER
+		);`,
+		`CREATE TABLE IF NOT EXISTS action_tab (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			run_id TEXT,
+			action TEXT,
+			src TEXT,
+			dst TEXT,
+			size INTEGER,
+			mod_time INTEGER,
+			checksum TEXT,
+			overwritten INTEGER,
+			backup TEXT DEFAULT '',
+			undone INTEGER DEFAULT 0,
+			create_at INTEGER
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_action_run_id ON action_tab (run_id);`,
+	}
+	for _, item := range sqls {
+		if _, err := j.db.Exec(item); err != nil {
+			return err
+		}
+	}
+	//旧版本创建的表缺少backup字段
+	return j.addColumnIfMissing("action_tab", "backup", "TEXT DEFAULT ''")
+}
+
+func (j *sqliteJournal) addColumnIfMissing(table string, column string, def string) error {
+	rows, err := j.db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = j.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, def))
+	return err
+}
+
+func (j *sqliteJournal) CreateRun(ctx context.Context, runID string) error {
+	_, err := j.db.ExecContext(ctx, "INSERT INTO run_tab (run_id, status, start_at, end_at) VALUES (?, ?, ?, 0)", runID, RunStatusRunning, time.Now().UnixMilli())
+	return err
+}
+
+func (j *sqliteJournal) UpdateRunStatus(ctx context.Context, runID string, status string) error {
+	_, err := j.db.ExecContext(ctx, "UPDATE run_tab SET status = ?, end_at = ? WHERE run_id = ?", status, time.Now().UnixMilli(), runID)
+	return err
+}
+
+func (j *sqliteJournal) GetRun(ctx context.Context, runID string) (*Run, bool, error) {
+	r := &Run{}
+	err := j.db.QueryRowContext(ctx, "SELECT run_id, status, start_at, end_at, (SELECT count(*) FROM action_tab WHERE action_tab.run_id = run_tab.run_id) FROM run_tab WHERE run_id = ?", runID).
+		Scan(&r.ID, &r.Status, &r.StartAt, &r.EndAt, &r.ActionCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return r, true, nil
+}
+
+func (j *sqliteJournal) ListRuns(ctx context.Context, limit int) ([]*Run, error) {
+	rows, err := j.db.QueryContext(ctx, "SELECT run_id, status, start_at, end_at, (SELECT count(*) FROM action_tab WHERE action_tab.run_id = run_tab.run_id) FROM run_tab ORDER BY start_at DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rs := make([]*Run, 0, limit)
+	for rows.Next() {
+		r := &Run{}
+		if err := rows.Scan(&r.ID, &r.Status, &r.StartAt, &r.EndAt, &r.ActionCount); err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	return rs, rows.Err()
+}
+
+func (j *sqliteJournal) AddAction(ctx context.Context, act *Action) error {
+	if act.CreateAt == 0 {
+		act.CreateAt = time.Now().UnixMilli()
+	}
+	res, err := j.db.ExecContext(ctx, "INSERT INTO action_tab (run_id, action, src, dst, size, mod_time, checksum, overwritten, backup, create_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		act.RunID, string(act.Type), act.Src, act.Dst, act.Size, act.ModTime, act.Checksum, act.Overwritten, act.Backup, act.CreateAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	act.ID = id
+	return nil
+}
+
+func (j *sqliteJournal) ListActions(ctx context.Context, runID string) ([]*Action, error) {
+	rows, err := j.db.QueryContext(ctx, "SELECT id, run_id, action, src, dst, size, mod_time, checksum, overwritten, backup, undone, create_at FROM action_tab WHERE run_id = ? ORDER BY id ASC", runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rs := make([]*Action, 0, 32)
+	for rows.Next() {
+		act := &Action{}
+		var typ string
+		if err := rows.Scan(&act.ID, &act.RunID, &typ, &act.Src, &act.Dst, &act.Size, &act.ModTime, &act.Checksum, &act.Overwritten, &act.Backup, &act.Undone, &act.CreateAt); err != nil {
+			return nil, err
+		}
+		act.Type = ActionType(typ)
+		rs = append(rs, act)
+	}
+	return rs, rows.Err()
+}
+
+func (j *sqliteJournal) MarkActionUndone(ctx context.Context, id int64) error {
+	_, err := j.db.ExecContext(ctx, "UPDATE action_tab SET undone = 1 WHERE id = ?", id)
+	return err
+}
+
+func NewSqliteJournal(path string) (IJournal, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	//多个worker会同时写入, 这里限制为单连接, 避免sqlite出现busy错误
+	db.SetMaxOpenConns(1)
+	j := &sqliteJournal{db: db, backupDir: filepath.Join(dir, "backup")}
+	if err := j.init(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// BackupFile 将文件复制到备份目录下以运行id命名的子目录中
+func (j *sqliteJournal) BackupFile(ctx context.Context, runID string, path string) (string, error) {
+	dir := filepath.Join(j.backupDir, runID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	//同一次运行中可能存在同名的文件
+	dst, err := os.CreateTemp(dir, "*-"+filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(dst.Name())
+		return "", err
+	}
+	if err := dst.Close(); err != nil {
+		_ = os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
@@ -0,0 +1,134 @@
+package journal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"yamdc/utils"
+
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+const (
+	UndoStatusRestored = "restored" //文件已移回原位置
+	UndoStatusRemoved  = "removed"  //生成的文件/目录已删除
+	UndoStatusSkipped  = "skipped"  //无法撤销, 具体原因见Reason
+)
+
+type UndoItem struct {
+	Action *Action `json:"action"`
+	Status string  `json:"status"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+type UndoResult struct {
+	RunID   string      `json:"run_id"`
+	Items   []*UndoItem `json:"items"`
+	Skipped int         `json:"skipped"`
+}
+
+// Undo 按照与执行相反的顺序撤销指定运行中的全部操作
+// 如果目标文件在运行后被修改过, 则跳过该文件, force为true时忽略修改检测
+func Undo(ctx context.Context, j IJournal, runID string, force bool) (*UndoResult, error) {
+	run, ok, err := j.GetRun(ctx, runID)
+	if err != nil {
+		return nil, fmt.Errorf("read run info failed, err:%w", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("run:%s not found", runID)
+	}
+	if run.Status == RunStatusUndone {
+		return nil, fmt.Errorf("run:%s already undone", runID)
+	}
+	acts, err := j.ListActions(ctx, runID)
+	if err != nil {
+		return nil, fmt.Errorf("read run actions failed, err:%w", err)
+	}
+	res := &UndoResult{RunID: runID}
+	for i := len(acts) - 1; i >= 0; i-- {
+		if acts[i].Undone { //上次撤销时已经处理过了
+			continue
+		}
+		item := undoAction(acts[i], force)
+		if item.Status == UndoStatusSkipped {
+			res.Skipped++
+			logutil.GetLogger(ctx).Warn("skip undo action", zap.String("action", string(item.Action.Type)),
+				zap.String("src", item.Action.Src), zap.String("dst", item.Action.Dst), zap.String("reason", item.Reason))
+		} else if err := j.MarkActionUndone(ctx, acts[i].ID); err != nil {
+			return res, fmt.Errorf("mark action undone failed, err:%w", err)
+		}
+		res.Items = append(res.Items, item)
+	}
+	status := RunStatusUndone
+	if res.Skipped > 0 {
+		status = RunStatusUndoPartial
+	}
+	if err := j.UpdateRunStatus(ctx, runID, status); err != nil {
+		return res, fmt.Errorf("update run status failed, err:%w", err)
+	}
+	return res, nil
+}
+
+func skipped(act *Action, format string, args ...interface{}) *UndoItem {
+	return &UndoItem{Action: act, Status: UndoStatusSkipped, Reason: fmt.Sprintf(format, args...)}
+}
+
+func undoAction(act *Action, force bool) *UndoItem {
+	if act.Type == ActionMkdir {
+		if err := os.Remove(act.Dst); err != nil {
+			if os.IsNotExist(err) {
+				return &UndoItem{Action: act, Status: UndoStatusRemoved}
+			}
+			//目录中存在其他文件, 不做处理
+			return skipped(act, "remove dir failed, err:%v", err)
+		}
+		return &UndoItem{Action: act, Status: UndoStatusRemoved}
+	}
+	if _, err := os.Lstat(act.Dst); err != nil {
+		return skipped(act, "dst not accessible, err:%v", err)
+	}
+	if err := checkUnmodified(act); err != nil && !force {
+		return skipped(act, "dst modified after run, use force to override, err:%v", err)
+	}
+	switch act.Type {
+	case ActionMove:
+		if _, err := os.Lstat(act.Src); err == nil {
+			return skipped(act, "src path already occupied")
+		}
+		if err := os.MkdirAll(filepath.Dir(act.Src), 0755); err != nil {
+			return skipped(act, "make src dir failed, err:%v", err)
+		}
+		if err := utils.NewFileManager().Move(act.Dst, act.Src); err != nil {
+			return skipped(act, "move file back failed, err:%v", err)
+		}
+		return &UndoItem{Action: act, Status: UndoStatusRestored}
+	case ActionWrite:
+		if act.Overwritten {
+			return restoreBackup(act)
+		}
+		fallthrough
+	default:
+		if err := os.Remove(act.Dst); err != nil {
+			return skipped(act, "remove file failed, err:%v", err)
+		}
+		return &UndoItem{Action: act, Status: UndoStatusRemoved}
+	}
+}
+
+// restoreBackup 使用写入前的备份还原被覆盖的文件
+func restoreBackup(act *Action) *UndoItem {
+	if len(act.Backup) == 0 {
+		return skipped(act, "file existed before run and no backup found, keep it")
+	}
+	raw, err := os.ReadFile(act.Backup)
+	if err != nil {
+		return skipped(act, "read backup file failed, err:%v", err)
+	}
+	if err := os.WriteFile(act.Dst, raw, 0644); err != nil {
+		return skipped(act, "restore backup file failed, err:%v", err)
+	}
+	_ = os.Remove(act.Backup)
+	return &UndoItem{Action: act, Status: UndoStatusRestored}
+}
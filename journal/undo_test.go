@@ -0,0 +1,144 @@
+package journal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"yamdc/hasher"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type undoTestEnv struct {
+	j     IJournal
+	runID string
+	src   string
+	dir   string
+	dst   string
+	image string
+}
+
+func setupUndoTest(t *testing.T) *undoTestEnv {
+	root := t.TempDir()
+	j, err := NewSqliteJournal(filepath.Join(root, "journal.db"))
+	assert.NoError(t, err)
+	ctx := context.Background()
+	env := &undoTestEnv{
+		j:     j,
+		runID: NewRunID(),
+		src:   filepath.Join(root, "scan", "ABC-123.mp4"),
+		dir:   filepath.Join(root, "save", "ABC-123"),
+	}
+	env.dst = filepath.Join(env.dir, "ABC-123.mp4")
+	env.image = filepath.Join(env.dir, "ABC-123-poster.jpg")
+	assert.NoError(t, j.CreateRun(ctx, env.runID))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(env.src), 0755))
+	assert.NoError(t, os.WriteFile(env.src, []byte("movie"), 0644))
+
+	//模拟一次完整的运行
+	assert.NoError(t, os.MkdirAll(env.dir, 0755))
+	assert.NoError(t, j.AddAction(ctx, &Action{RunID: env.runID, Type: ActionMkdir, Dst: env.dir}))
+	data := []byte("image")
+	assert.NoError(t, os.WriteFile(env.image, data, 0644))
+	act, err := NewAction(env.runID, ActionWrite, "", env.image)
+	assert.NoError(t, err)
+	act.Checksum = hasher.ToSha1Bytes(data)
+	assert.NoError(t, j.AddAction(ctx, act))
+	assert.NoError(t, os.Rename(env.src, env.dst))
+	act, err = NewAction(env.runID, ActionMove, env.src, env.dst)
+	assert.NoError(t, err)
+	assert.NoError(t, j.AddAction(ctx, act))
+	assert.NoError(t, j.UpdateRunStatus(ctx, env.runID, RunStatusFinished))
+	return env
+}
+
+func TestUndo(t *testing.T) {
+	env := setupUndoTest(t)
+	ctx := context.Background()
+	runs, err := env.j.ListRuns(ctx, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(runs))
+	assert.Equal(t, int64(3), runs[0].ActionCount)
+
+	res, err := Undo(ctx, env.j, env.runID, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, res.Skipped)
+	assert.Equal(t, 3, len(res.Items))
+	raw, err := os.ReadFile(env.src)
+	assert.NoError(t, err)
+	assert.Equal(t, "movie", string(raw))
+	_, err = os.Stat(env.dir)
+	assert.True(t, os.IsNotExist(err))
+
+	run, ok, err := env.j.GetRun(ctx, env.runID)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, RunStatusUndone, run.Status)
+	_, err = Undo(ctx, env.j, env.runID, false)
+	assert.Error(t, err)
+}
+
+func TestUndoModified(t *testing.T) {
+	env := setupUndoTest(t)
+	ctx := context.Background()
+	assert.NoError(t, os.WriteFile(env.image, []byte("edited"), 0644))
+
+	res, err := Undo(ctx, env.j, env.runID, false)
+	assert.NoError(t, err)
+	//图片被修改, 目录因此也无法删除
+	assert.Equal(t, 2, res.Skipped)
+	_, err = os.Stat(env.image)
+	assert.NoError(t, err)
+	_, err = os.Stat(env.src)
+	assert.NoError(t, err)
+	run, _, err := env.j.GetRun(ctx, env.runID)
+	assert.NoError(t, err)
+	assert.Equal(t, RunStatusUndoPartial, run.Status)
+
+	res, err = Undo(ctx, env.j, env.runID, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, res.Skipped)
+	_, err = os.Stat(env.image)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUndoOverwritten(t *testing.T) {
+	env := setupUndoTest(t)
+	ctx := context.Background()
+	runID := NewRunID()
+	assert.NoError(t, env.j.CreateRun(ctx, runID))
+	nfo := filepath.Join(env.dir, "ABC-123.nfo")
+	assert.NoError(t, os.WriteFile(nfo, []byte("old"), 0644))
+	//覆盖前备份
+	backup, err := env.j.BackupFile(ctx, runID, nfo)
+	assert.NoError(t, err)
+	data := []byte("new")
+	assert.NoError(t, os.WriteFile(nfo, data, 0644))
+	act, err := NewAction(runID, ActionWrite, "", nfo)
+	assert.NoError(t, err)
+	act.Checksum = hasher.ToSha1Bytes(data)
+	act.Overwritten = true
+	act.Backup = backup
+	assert.NoError(t, env.j.AddAction(ctx, act))
+	//旧版本没有备份的记录无法还原
+	assert.NoError(t, os.WriteFile(env.image, []byte("image"), 0644))
+	legacy, err := NewAction(runID, ActionWrite, "", env.image)
+	assert.NoError(t, err)
+	legacy.Checksum = hasher.ToSha1Bytes([]byte("image"))
+	legacy.Overwritten = true
+	assert.NoError(t, env.j.AddAction(ctx, legacy))
+
+	res, err := Undo(ctx, env.j, runID, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res.Skipped)
+	assert.Equal(t, UndoStatusSkipped, res.Items[0].Status)
+	assert.Equal(t, UndoStatusRestored, res.Items[1].Status)
+	raw, err := os.ReadFile(nfo)
+	assert.NoError(t, err)
+	assert.Equal(t, "old", string(raw))
+	_, err = os.Stat(backup)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(env.image)
+	assert.NoError(t, err)
+}
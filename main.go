@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 	"yamdc/capture"
 	"yamdc/client"
@@ -16,25 +19,39 @@ import (
 	"yamdc/face/goface"
 	"yamdc/face/pigo"
 	"yamdc/ffmpeg"
+	"yamdc/hook"
+	"yamdc/journal"
 	"yamdc/model"
 	"yamdc/processor"
 	"yamdc/processor/handler"
 	"yamdc/searcher"
 	"yamdc/store"
+	"yamdc/transfer"
 	"yamdc/translator"
 	"yamdc/translator/googletranslator"
 
 	"github.com/xxxsen/common/logutil"
 	"go.uber.org/zap"
 
+	"yamdc/searcher/plugin/declarative"
+	"yamdc/searcher/plugin/domainpool"
 	"yamdc/searcher/plugin/factory"
 	_ "yamdc/searcher/plugin/register"
 )
 
+// fileStateStore 文件处理状态, 与缓存共用同一个数据库
+var fileStateStore store.IFileStateStore
+
 func main() {
 
 	logkit := debugLogger.Shared()
 	c := config.Shared()
+	if args := flag.Args(); len(args) > 0 {
+		if err := runSubCommand(c, args); err != nil {
+			logkit.Fatal("run sub command failed", zap.String("cmd", args[0]), zap.Error(err))
+		}
+		return
+	}
 	if err := precheckDir(c); err != nil {
 		logkit.Fatal("precheck dir failed", zap.Error(err))
 	}
@@ -52,13 +69,25 @@ func main() {
 	}
 	logkit.Info("read env flags", zap.Any("flag", *envflag.GetFlag()))
 
-	store.SetStorage(store.MustNewSqliteStorage(filepath.Join(c.DataDir, "cache", "cache.db")))
+	st := store.MustNewSqliteStorage(filepath.Join(c.DataDir, "cache", "cache.db"))
+	store.SetStorage(st)
+	if hs, ok := st.(store.IDomainHealthStore); ok {
+		domainpool.SetDefault(domainpool.New(domainpool.WithStore(hs)))
+	}
+	if fs, ok := st.(store.IFileStateStore); ok {
+		fileStateStore = fs
+	}
 	if err := setupTranslator(c); err != nil {
 		logkit.Error("setup translator failed", zap.Error(err)) //非关键路径
 	}
 	if err := initFace(filepath.Join(c.DataDir, "models")); err != nil {
 		logkit.Error("init face recognizer failed", zap.Error(err))
 	}
+	if names, err := declarative.LoadDir(filepath.Join(c.DataDir, "plugins")); err != nil {
+		logkit.Fatal("load declarative plugins failed", zap.Error(err))
+	} else if len(names) > 0 {
+		logkit.Info("load declarative plugins succ", zap.Strings("plugins", names))
+	}
 	logkit.Info("support plugins", zap.Strings("plugins", factory.Plugins()))
 	logkit.Info("support handlers", zap.Strings("handlers", handler.Handlers()))
 	logkit.Info("current use plugins", zap.Strings("plugins", c.Plugins))
@@ -66,7 +95,7 @@ func main() {
 		logkit.Info("-- cat plugins", zap.String("cat", ct.Name), zap.Strings("plugins", ct.Plugins))
 	}
 	logkit.Info("current use handlers", zap.Strings("handlers", c.Handlers))
-	logkit.Info("use naming rule", zap.String("rule", c.Naming))
+	logkit.Info("use naming rule", zap.String("rule", c.Naming), zap.String("file_rule", c.FileNaming))
 	// 将二维字符串数组转换为一维，以便于日志打印
 	flattenedRegex := make([]string, 0)
 	for _, regexGroup := range c.RegexesToReplace {
@@ -74,56 +103,168 @@ func main() {
 	}
 	logkit.Info("use ignore regex", zap.Strings("IgnoreRegex", flattenedRegex))
 	logkit.Info("scrape from dir", zap.String("dir", c.ScanDir))
+	logkit.Info("-- scan config", zap.Int("max_depth", c.ScanConfig.MaxDepth), zap.Strings("exclude_patterns", c.ScanConfig.ExcludePatterns),
+		zap.Strings("exclude_regexes", c.ScanConfig.ExcludeRegexes), zap.Int64("min_file_size_mb", c.ScanConfig.MinFileSize), zap.Bool("follow_symlink", c.ScanConfig.FollowSymlink))
 	logkit.Info("save to dir", zap.String("dir", c.SaveDir))
+	logkit.Info("use concurrency", zap.Int("concurrency", c.Concurrency))
+	logkit.Info("use collision policy", zap.String("policy", c.CollisionConfig.Policy), zap.String("duplicates_dir", c.CollisionConfig.DuplicatesDir))
+	if len(c.FailedDir) > 0 {
+		logkit.Info("failed files will be moved to failed dir", zap.String("dir", c.FailedDir), zap.Bool("retry", c.RetryFailed))
+	}
+	if c.DryRun {
+		logkit.Info("dry-run mode enabled, nothing will be written to save dir")
+	}
 	logkit.Info("use data dir", zap.String("dir", c.DataDir))
+	if c.WatchConfig.Enable {
+		logkit.Info("watch mode enabled", zap.String("cron", c.WatchConfig.Cron), zap.Int64("stable_duration", c.WatchConfig.StableDuration))
+	}
 	logkit.Info("check current feature list")
 	logkit.Info("-- ffmpeg", zap.Bool("enable", ffmpeg.IsFFMpegEnabled()))
 	logkit.Info("-- ffprobe", zap.Bool("enable", ffmpeg.IsFFProbeEnabled()))
 	logkit.Info("-- translator", zap.Bool("enable", translator.IsTranslatorEnabled()))
 	logkit.Info("-- face recognize", zap.Bool("enable", face.IsFaceRecognizeEnabled()))
 
-	ss, err := buildSearcher(c.Plugins, c.PluginConfig)
-	if err != nil {
-		logkit.Fatal("build searcher failed", zap.Error(err))
-	}
-	catSs, err := buildCatSearcher(c.CategoryPlugins, c.PluginConfig)
-	if err != nil {
-		logkit.Fatal("build cat searcher failed", zap.Error(err))
+	var j journal.IJournal
+	if !c.DryRun {
+		var err error
+		if j, err = journal.NewSqliteJournal(journalPath(c)); err != nil {
+			logkit.Fatal("open journal failed", zap.Error(err))
+		}
 	}
-	ps, err := buildProcessor(c.Handlers, c.HandlerConfig)
-	if err != nil {
-		logkit.Fatal("build processor failed", zap.Error(err))
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	if c.WatchConfig.Enable {
+		if err := runDaemon(ctx, c, j); err != nil {
+			logkit.Error("run daemon failed", zap.Error(err))
+		}
+		return
 	}
-	cap, err := buildCapture(c, ss, catSs, ps)
+	cap, err := buildRunner(c, j)
 	if err != nil {
 		logkit.Fatal("build capture runner failed", zap.Error(err))
 	}
 	logkit.Info("capture kit init success, start scraping------------------------------------------")
 	// 启动抓取
-	if err := cap.Run(context.Background()); err != nil {
+	err = cap.Run(ctx)
+	domainpool.Default().LogState(ctx)
+	if err != nil {
 		logkit.Error("run capture kit failed", zap.Error(err))
 		return
 	}
+	if c.DryRun {
+		logkit.Info("run capture kit finish, dry-run plan generated")
+		return
+	}
 	logkit.Info("run capture kit finish, all file scrape succ")
 }
 
-func buildCapture(c *config.Config, ss []searcher.ISearcher, catSs map[model.Category][]searcher.ISearcher, ps []processor.IProcessor) (*capture.Capture, error) {
+// buildRunner 根据配置构建插件, 处理器及刮削实例
+func buildRunner(c *config.Config, j journal.IJournal, extOpts ...capture.Option) (*capture.Capture, error) {
+	if err := verifyPluginConfig(c.PluginConfig); err != nil {
+		return nil, err
+	}
+	reqOpts := buildRequestOptions(&c.SearchConfig)
+	ss, err := buildSearcher(c.Plugins, c.PluginConfig, reqOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("build searcher failed, err:%w", err)
+	}
+	catSs, err := buildCatSearcher(c.CategoryPlugins, c.PluginConfig, reqOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("build cat searcher failed, err:%w", err)
+	}
+	ps, err := buildProcessor(c.Handlers, c.HandlerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("build processor failed, err:%w", err)
+	}
+	return buildCapture(c, ss, catSs, ps, j, extOpts...)
+}
+
+func buildCapture(c *config.Config, ss []searcher.ISearcher, catSs map[model.Category][]searcher.ISearcher, ps []processor.IProcessor, j journal.IJournal, extOpts ...capture.Option) (*capture.Capture, error) {
+	overrideFile := c.OverrideFile
+	if len(overrideFile) == 0 {
+		overrideFile = filepath.Join(c.DataDir, "overrides.json")
+	}
+	searchOpts, err := buildSearchOptions(&c.SearchConfig)
+	if err != nil {
+		return nil, fmt.Errorf("build search options failed, err:%w", err)
+	}
 	opts := make([]capture.Option, 0, 10)
 	opts = append(opts,
 		capture.WithNamingRule(c.Naming),
+		capture.WithFileNamingRule(c.FileNaming),
 		capture.WithScanDir(c.ScanDir),
 		capture.WithSaveDir(c.SaveDir),
-		capture.WithSeacher(searcher.NewCategorySearcher(ss, catSs)),
+		capture.WithSeacher(searcher.NewCategorySearcher(ss, catSs, searchOpts...)),
 		capture.WithProcessor(processor.NewGroup(ps)),
 		capture.WithExtraMediaExtList(c.ExtraMediaExts),
+		capture.WithScanMaxDepth(c.ScanConfig.MaxDepth),
+		capture.WithScanExcludePatterns(c.ScanConfig.ExcludePatterns),
+		capture.WithScanExcludeRegexes(c.ScanConfig.ExcludeRegexes),
+		capture.WithScanMinFileSize(c.ScanConfig.MinFileSize*1024*1024),
+		capture.WithScanFollowSymlink(c.ScanConfig.FollowSymlink),
+		capture.WithConcurrency(c.Concurrency),
+		capture.WithDryRun(c.DryRun),
+		capture.WithPlanDir(filepath.Join(c.DataDir, "plan")),
+		capture.WithReportDir(filepath.Join(c.DataDir, "report")),
+		capture.WithFailedDir(c.FailedDir),
+		capture.WithRetryFailed(c.RetryFailed),
+		capture.WithRerunPatterns(c.RerunPatterns),
+		capture.WithCollisionPolicy(c.CollisionConfig.Policy),
+		capture.WithDuplicatesDir(c.CollisionConfig.DuplicatesDir),
+		capture.WithOverrideFile(overrideFile),
+		capture.WithSteps(buildPipelineSteps(c.Pipeline)),
 	)
+	tf, catTf, err := buildTransfer(&c.TransferConfig)
+	if err != nil {
+		return nil, fmt.Errorf("build transfer failed, err:%w", err)
+	}
+	opts = append(opts, capture.WithTransfer(tf), capture.WithCategoryTransfer(catTf))
+	hooks, err := buildHooks(c.Hooks)
+	if err != nil {
+		return nil, fmt.Errorf("build hooks failed, err:%w", err)
+	}
+	if hooks != nil {
+		opts = append(opts, capture.WithHooks(hooks))
+	}
+	if j != nil {
+		opts = append(opts, capture.WithJournal(j))
+	}
+	if fileStateStore != nil {
+		opts = append(opts, capture.WithStateStore(fileStateStore))
+	}
+	opts = append(opts, extOpts...)
 	return capture.New(opts...)
 }
 
-func buildCatSearcher(cplgs []config.CategoryPlugin, m map[string]interface{}) (map[model.Category][]searcher.ISearcher, error) {
+func buildTransfer(c *config.TransferConfig) (transfer.ITransfer, map[model.Category]transfer.ITransfer, error) {
+	mode := c.Mode
+	if len(mode) == 0 {
+		mode = string(transfer.ModeMove)
+		if envflag.IsEnableLinkMode() {
+			mode = string(transfer.ModeSymlink)
+		}
+	}
+	tf, err := transfer.Create(mode, c.Fallback)
+	if err != nil {
+		return nil, nil, err
+	}
+	logutil.GetLogger(context.Background()).Info("use transfer mode", zap.String("mode", tf.Name()), zap.String("fallback", c.Fallback))
+	catTf := make(map[model.Category]transfer.ITransfer, len(c.CategoryModes))
+	for cat, catMode := range c.CategoryModes {
+		t, err := transfer.Create(catMode, c.Fallback)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create transfer for cat:%s failed, err:%w", cat, err)
+		}
+		logutil.GetLogger(context.Background()).Info("-- cat transfer mode", zap.String("cat", cat), zap.String("mode", t.Name()))
+		catTf[model.Category(strings.ToUpper(cat))] = t
+	}
+	return tf, catTf, nil
+}
+
+func buildCatSearcher(cplgs []config.CategoryPlugin, m map[string]interface{}, opts ...searcher.Option) (map[model.Category][]searcher.ISearcher, error) {
 	rs := make(map[model.Category][]searcher.ISearcher, len(cplgs))
 	for _, plg := range cplgs {
-		ss, err := buildSearcher(plg.Plugins, m)
+		ss, err := buildSearcher(plg.Plugins, m, opts...)
 		if err != nil {
 			return nil, err
 		}
@@ -132,7 +273,17 @@ func buildCatSearcher(cplgs []config.CategoryPlugin, m map[string]interface{}) (
 	return rs, nil
 }
 
-func buildSearcher(plgs []string, m map[string]interface{}) ([]searcher.ISearcher, error) {
+// verifyPluginConfig 校验plugin_config中全部插件的配置, 避免未启用的插件配置错误直到使用时才暴露
+func verifyPluginConfig(m map[string]interface{}) error {
+	for name, args := range m {
+		if _, err := factory.CreatePlugin(name, args); err != nil {
+			return fmt.Errorf("verify plugin config failed, name:%s, err:%w", name, err)
+		}
+	}
+	return nil
+}
+
+func buildSearcher(plgs []string, m map[string]interface{}, opts ...searcher.Option) ([]searcher.ISearcher, error) {
 	rs := make([]searcher.ISearcher, 0, len(plgs))
 	for _, name := range plgs {
 		args, ok := m[name]
@@ -143,7 +294,7 @@ func buildSearcher(plgs []string, m map[string]interface{}) ([]searcher.ISearche
 		if err != nil {
 			return nil, fmt.Errorf("create plugin failed, name:%s, err:%w", name, err)
 		}
-		sr, err := searcher.NewDefaultSearcher(name, plg)
+		sr, err := searcher.NewDefaultSearcher(name, plg, opts...)
 		if err != nil {
 			return nil, fmt.Errorf("create searcher failed, plugin:%s, err:%w", name, err)
 		}
@@ -171,6 +322,104 @@ func buildProcessor(hs []string, m map[string]interface{}) ([]processor.IProcess
 	return rs, nil
 }
 
+// buildRequestOptions 单个插件请求相关的配置, 限流器在全部插件间共享
+func buildRequestOptions(c *config.SearchConfig) []searcher.Option {
+	opts := []searcher.Option{searcher.WithBreakerThreshold(c.BreakerThreshold)}
+	if c.Retry.MaxRetry != nil {
+		opts = append(opts, searcher.WithMaxRetry(*c.Retry.MaxRetry))
+	}
+	if c.MatchThreshold != nil {
+		opts = append(opts, searcher.WithMatchThreshold(*c.MatchThreshold))
+	}
+	opts = append(opts, searcher.WithRetryInterval(time.Duration(c.Retry.BaseInterval)*time.Millisecond, time.Duration(c.Retry.MaxInterval)*time.Millisecond))
+	if len(c.RateLimit.Plugins) == 0 && len(c.RateLimit.Hosts) == 0 {
+		return opts
+	}
+	convert := func(in map[string]config.RateLimitRule) map[string]searcher.RateLimitRule {
+		rs := make(map[string]searcher.RateLimitRule, len(in))
+		for k, v := range in {
+			rs[k] = searcher.RateLimitRule{Rate: v.Rate, Burst: v.Burst}
+		}
+		return rs
+	}
+	return append(opts, searcher.WithRateLimiter(searcher.NewRateLimiter(convert(c.RateLimit.Plugins), convert(c.RateLimit.Hosts))))
+}
+
+func buildSearchOptions(c *config.SearchConfig) ([]searcher.Option, error) {
+	opts := []searcher.Option{searcher.WithParallel(c.Parallel)}
+	if c.PluginTimeout > 0 {
+		opts = append(opts, searcher.WithPluginTimeout(time.Duration(c.PluginTimeout)*time.Second))
+	}
+	if c.AcceptScore > 0 {
+		opts = append(opts, searcher.WithAcceptScore(c.AcceptScore))
+	}
+	if !c.Merge.Enable {
+		return opts, nil
+	}
+	rules := make(map[string]searcher.MergeRule, len(c.Merge.Fields))
+	for name, item := range c.Merge.Fields {
+		rules[name] = searcher.MergeRule{Strategy: item.Strategy, Plugins: item.Plugins}
+	}
+	if err := searcher.ValidateMergeRules(rules); err != nil {
+		return nil, err
+	}
+	opts = append(opts, searcher.WithMerge(c.Merge.MaxSources, rules))
+	return opts, nil
+}
+
+func buildHooks(hs []config.HookConfig) (*hook.Runner, error) {
+	if len(hs) == 0 {
+		return nil, nil
+	}
+	rs := make([]*hook.Hook, 0, len(hs))
+	for idx, item := range hs {
+		name := item.Name
+		if len(name) == 0 {
+			name = fmt.Sprintf("%s-%d", item.Type, idx)
+		}
+		var act hook.IAction
+		var err error
+		switch item.Type {
+		case "exec":
+			act, err = hook.NewExecAction(item.Command)
+		case "webhook":
+			act, err = hook.NewWebhookAction(item.URL, item.Headers)
+		default:
+			err = fmt.Errorf("unknown hook type:%s", item.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("create hook failed, name:%s, err:%w", name, err)
+		}
+		opts := []hook.Option{hook.WithRetry(item.Retry)}
+		for _, ev := range item.Events {
+			opts = append(opts, hook.WithEvents(hook.Event(ev)))
+		}
+		if item.Timeout > 0 {
+			opts = append(opts, hook.WithTimeout(time.Duration(item.Timeout)*time.Second))
+		}
+		if item.RetryInterval > 0 {
+			opts = append(opts, hook.WithRetryInterval(time.Duration(item.RetryInterval)*time.Second))
+		}
+		rs = append(rs, hook.New(name, act, opts...))
+		logutil.GetLogger(context.Background()).Info("create hook succ", zap.String("hook", name), zap.String("type", item.Type))
+	}
+	return hook.NewRunner(rs...), nil
+}
+
+func buildPipelineSteps(ps []config.PipelineStep) []capture.StepConfig {
+	rs := make([]capture.StepConfig, 0, len(ps))
+	for _, item := range ps {
+		rs = append(rs, capture.StepConfig{
+			Name:    item.Name,
+			Disable: item.Disable,
+			Policy:  item.Policy,
+			Retry:   item.Retry,
+			Args:    item.Args,
+		})
+	}
+	return rs
+}
+
 func precheckDir(c *config.Config) error {
 	if len(c.DataDir) == 0 {
 		return fmt.Errorf("no data dir")
@@ -34,8 +34,9 @@ type TranslateInfo struct {
 }
 
 type ScrapeInfo struct {
-	Source string `json:"source"`
-	DateTs int64  `json:"date_ts"`
+	Source       string            `json:"source"`
+	DateTs       int64             `json:"date_ts"`
+	FieldSources map[string]string `json:"field_sources,omitempty"` //合并多个来源时每个字段的来源, key为字段名
 }
 
 type ExtInfo struct {
@@ -56,6 +57,34 @@ type FileContext struct {
 	SaveDir      string
 	Meta         *AvMeta
 	Number       *Number
+	PartIndex    int            //多分段影片的分段序号, 从1开始, 0表示非多分段影片
+	Parts        []*FileContext //多分段影片的全部分段(包含自身), 仅存在于第一个分段上
+	Sidecars     []*SidecarFile //与影片同名的字幕, 图片等附属文件
+	Collision    *Collision     //保存目标已经存在时的处理结果
+	Override     *Override      //用户指定的手动覆盖配置
+}
+
+// Collision 保存目标已经存在其他影片时的处理结果
+type Collision struct {
+	Policy   string `json:"policy"`
+	Action   string `json:"action"`
+	Existing string `json:"existing"`        //已经存在的影片
+	Moved    string `json:"moved,omitempty"` //被移入重复目录的影片
+	Reason   string `json:"reason,omitempty"`
+}
+
+// SidecarFile 影片的附属文件, 如字幕及已有的图片
+type SidecarFile struct {
+	FullFilePath string
+	Tag          string //文件名中位于影片名之后, 扩展名之前的部分, 例如: .chs, -poster
+	Ext          string
+	IsSubtitle   bool
+	IsChinese    bool
+}
+
+// IsMultiPart 是否为多分段影片的主分段
+func (f *FileContext) IsMultiPart() bool {
+	return len(f.Parts) > 1
 }
 
 /* 返回当前文件的目录,入参为第几级目录,0为该文件当前目录,1为上一级 */
@@ -1,15 +1,20 @@
 package model
 
+import (
+	"fmt"
+	"strings"
+)
+
 type Number struct {
-	NumberId string `option:"mandatory"`
-	IsCnSub  bool
+	NumberId string `option:"mandatory" json:"number_id"`
+	IsCnSub  bool   `json:"is_cn_sub"`
 
-	Episode      string
-	IsUncensored bool
-	Is4k         bool
-	IsCracked    bool
-	IsLeaked     bool
-	Cat          Category
+	Episode      string   `json:"episode"`
+	IsUncensored bool     `json:"is_uncensored"`
+	Is4k         bool     `json:"is_4k"`
+	IsCracked    bool     `json:"is_cracked"`
+	IsLeaked     bool     `json:"is_leaked"`
+	Cat          Category `json:"cat"`
 }
 
 func (number *Number) WithNumberId(numberId string) *Number {
@@ -98,12 +103,19 @@ func (n *Number) GenerateSuffix(base string) string {
 	if n.GetIsLeak() {
 		base += "-" + DefaultSuffixLeak
 	}
-	// if n.GetIsMultiCD() {
-	// 	base += "-" + DefaultSuffixMultiCD + n.GetEpisode()
-	// }
+	//多CD的后缀需要在完成分组后才能确定, 见GenerateMultiCDSuffix
 	return base
 }
 
+func (n *Number) GetIsMultiCD() bool {
+	return len(n.Episode) > 0
+}
+
+// GenerateMultiCDSuffix 生成多CD影片的文件名, 使用kodi/jellyfin支持的堆叠命名格式, 例如: ABC-123-cd1
+func (n *Number) GenerateMultiCDSuffix(base string, part int) string {
+	return fmt.Sprintf("%s-%s%d", base, strings.ToLower(DefaultSuffixMultiCD), part)
+}
+
 func (n *Number) GenerateTags() []string {
 	rs := make([]string, 0, 5)
 	if n.GetIsUncensorMovie() {
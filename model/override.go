@@ -0,0 +1,51 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Override 单个文件的手动覆盖配置, 用于修正番号识别或者刮削结果
+type Override struct {
+	Number     string            `json:"number"`      //强制指定番号, 指定后不再从文件名中解析
+	Category   string            `json:"category"`    //强制指定分类, 例如: FC2
+	Flags      OverrideFlags     `json:"flags"`       //强制指定中文字幕/4K等标记, 未指定的保持识别结果
+	Plugins    []string          `json:"plugins"`     //强制指定使用的插件及顺序
+	DetailURLs map[string]string `json:"detail_urls"` //为插件固定详情页地址, key为插件名
+	Meta       json.RawMessage   `json:"meta"`        //刮削完成后覆盖的元数据字段, 格式同AvMeta
+}
+
+type OverrideFlags struct {
+	CnSub      *bool `json:"cnsub"`
+	Uncensored *bool `json:"uncensored"`
+	Is4K       *bool `json:"4k"`
+	Leaked     *bool `json:"leaked"`
+	Cracked    *bool `json:"cracked"`
+}
+
+// ApplyToNumber 将覆盖配置中的分类及标记应用到番号信息上
+func (o *Override) ApplyToNumber(n *Number) {
+	if len(o.Category) > 0 {
+		n.Cat = Category(strings.ToUpper(o.Category))
+	}
+	applyFlag(&n.IsCnSub, o.Flags.CnSub)
+	applyFlag(&n.IsUncensored, o.Flags.Uncensored)
+	applyFlag(&n.Is4k, o.Flags.Is4K)
+	applyFlag(&n.IsLeaked, o.Flags.Leaked)
+	applyFlag(&n.IsCracked, o.Flags.Cracked)
+}
+
+// ApplyToMeta 将覆盖配置中的元数据字段覆盖到刮削结果上, 未出现的字段保持不变
+func (o *Override) ApplyToMeta(m *AvMeta) error {
+	if len(o.Meta) == 0 {
+		return nil
+	}
+	return json.Unmarshal(o.Meta, m)
+}
+
+func applyFlag(dst *bool, v *bool) {
+	if v == nil {
+		return
+	}
+	*dst = *v
+}
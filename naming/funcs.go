@@ -0,0 +1,117 @@
+package naming
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type templateFunc func(v interface{}, args []string) (interface{}, error)
+
+type funcDef struct {
+	argc int
+	fn   templateFunc
+}
+
+var funcs = map[string]funcDef{
+	"upper":    {argc: 0, fn: fnUpper},
+	"lower":    {argc: 0, fn: fnLower},
+	"truncate": {argc: 1, fn: fnTruncate},
+	"default":  {argc: 1, fn: fnDefault},
+	"first":    {argc: 1, fn: fnFirst},
+	"join":     {argc: 1, fn: fnJoin},
+	"replace":  {argc: 2, fn: fnReplace},
+}
+
+func toString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case []string:
+		return strings.Join(t, ",")
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		return fmt.Sprintf("%v", t)
+	}
+}
+
+func isTruthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case string:
+		return len(t) > 0
+	case []string:
+		return len(t) > 0
+	default:
+		return len(toString(v)) > 0
+	}
+}
+
+func parseCount(arg string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid count:%s", arg)
+	}
+	return n, nil
+}
+
+func fnUpper(v interface{}, _ []string) (interface{}, error) {
+	return strings.ToUpper(toString(v)), nil
+}
+
+func fnLower(v interface{}, _ []string) (interface{}, error) {
+	return strings.ToLower(toString(v)), nil
+}
+
+// fnTruncate 按字符(而不是字节)截断
+func fnTruncate(v interface{}, args []string) (interface{}, error) {
+	n, err := parseCount(args[0])
+	if err != nil {
+		return nil, err
+	}
+	rs := []rune(toString(v))
+	if len(rs) <= n {
+		return string(rs), nil
+	}
+	return strings.TrimSpace(string(rs[:n])), nil
+}
+
+func fnDefault(v interface{}, args []string) (interface{}, error) {
+	if isTruthy(v) {
+		return v, nil
+	}
+	return args[0], nil
+}
+
+func fnFirst(v interface{}, args []string) (interface{}, error) {
+	n, err := parseCount(args[0])
+	if err != nil {
+		return nil, err
+	}
+	lst, ok := v.([]string)
+	if !ok {
+		return nil, fmt.Errorf("first can only be used on list field")
+	}
+	if len(lst) > n {
+		lst = lst[:n]
+	}
+	return lst, nil
+}
+
+func fnJoin(v interface{}, args []string) (interface{}, error) {
+	lst, ok := v.([]string)
+	if !ok {
+		return toString(v), nil
+	}
+	return strings.Join(lst, args[0]), nil
+}
+
+func fnReplace(v interface{}, args []string) (interface{}, error) {
+	return strings.ReplaceAll(toString(v), args[0], args[1]), nil
+}
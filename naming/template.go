@@ -0,0 +1,225 @@
+package naming
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Template 命名模板, 语法如下:
+//
+//	{NUMBER}                        输出字段值
+//	{TITLE|truncate:50}             通过`|`串联函数, 函数参数使用`:`分隔
+//	{ACTORS|first:2|join:,}         列表字段可以使用first截取前N项, 使用join拼接
+//	{if CNSUB}-C{else}-N{end}       条件输出, 字段为true/非空字符串/非空列表时成立, 可以使用`!`取反
+type Template struct {
+	rule  string
+	nodes []node
+}
+
+type node interface {
+	render(vars map[string]interface{}, sb *strings.Builder) error
+}
+
+type textNode struct {
+	text string
+}
+
+func (n *textNode) render(_ map[string]interface{}, sb *strings.Builder) error {
+	sb.WriteString(n.text)
+	return nil
+}
+
+type funcCall struct {
+	name string
+	args []string
+	fn   templateFunc
+}
+
+type valueNode struct {
+	field string
+	funcs []*funcCall
+}
+
+func (n *valueNode) render(vars map[string]interface{}, sb *strings.Builder) error {
+	v, ok := vars[n.field]
+	if !ok {
+		return fmt.Errorf("unknown field:%s", n.field)
+	}
+	var err error
+	for _, call := range n.funcs {
+		v, err = call.fn(v, call.args)
+		if err != nil {
+			return fmt.Errorf("call func:%s on field:%s failed, err:%w", call.name, n.field, err)
+		}
+	}
+	sb.WriteString(toString(v))
+	return nil
+}
+
+type ifNode struct {
+	field  string
+	negate bool
+	then   []node
+	els    []node
+}
+
+func (n *ifNode) render(vars map[string]interface{}, sb *strings.Builder) error {
+	v, ok := vars[n.field]
+	if !ok {
+		return fmt.Errorf("unknown field:%s", n.field)
+	}
+	nodes := n.els
+	if isTruthy(v) != n.negate {
+		nodes = n.then
+	}
+	return renderNodes(nodes, vars, sb)
+}
+
+func renderNodes(nodes []node, vars map[string]interface{}, sb *strings.Builder) error {
+	for _, item := range nodes {
+		if err := item.render(vars, sb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parse 解析命名模板
+func Parse(rule string) (*Template, error) {
+	p := &parser{rule: rule}
+	nodes, end, err := p.parseNodes()
+	if err != nil {
+		return nil, err
+	}
+	if len(end) > 0 {
+		return nil, fmt.Errorf("unexpected {%s} at pos:%d", end, p.pos)
+	}
+	return &Template{rule: rule, nodes: nodes}, nil
+}
+
+// MustParse 解析命名模板, 失败时panic
+func MustParse(rule string) *Template {
+	t, err := Parse(rule)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func (t *Template) String() string {
+	return t.rule
+}
+
+// Render 使用给定的字段渲染模板, 模板中引用了不存在的字段时返回错误
+func (t *Template) Render(vars map[string]interface{}) (string, error) {
+	sb := &strings.Builder{}
+	if err := renderNodes(t.nodes, vars, sb); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+type parser struct {
+	rule string
+	pos  int
+}
+
+// parseNodes 解析直到文本结束或者遇到else/end, 返回遇到的结束标记
+func (p *parser) parseNodes() ([]node, string, error) {
+	nodes := make([]node, 0, 8)
+	for p.pos < len(p.rule) {
+		idx := strings.IndexAny(p.rule[p.pos:], "{}")
+		if idx < 0 {
+			nodes = append(nodes, &textNode{text: p.rule[p.pos:]})
+			p.pos = len(p.rule)
+			break
+		}
+		if idx > 0 {
+			nodes = append(nodes, &textNode{text: p.rule[p.pos : p.pos+idx]})
+			p.pos += idx
+		}
+		if p.rule[p.pos] == '}' {
+			return nil, "", fmt.Errorf("unexpected '}' at pos:%d", p.pos)
+		}
+		start := p.pos
+		closeIdx := strings.IndexByte(p.rule[start:], '}')
+		if closeIdx < 0 {
+			return nil, "", fmt.Errorf("unclosed '{' at pos:%d", start)
+		}
+		raw := p.rule[start+1 : start+closeIdx]
+		expr := strings.TrimSpace(raw)
+		p.pos = start + closeIdx + 1
+		if strings.Contains(expr, "{") {
+			return nil, "", fmt.Errorf("unexpected '{' at pos:%d", start)
+		}
+		switch {
+		case expr == "else" || expr == "end":
+			return nodes, expr, nil
+		case strings.HasPrefix(expr, "if "):
+			n, err := p.parseIf(strings.TrimSpace(expr[3:]))
+			if err != nil {
+				return nil, "", err
+			}
+			nodes = append(nodes, n)
+		default:
+			n, err := parseValue(raw) //函数参数中的空白需要保留
+			if err != nil {
+				return nil, "", fmt.Errorf("parse expr:%s failed, err:%w", expr, err)
+			}
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, "", nil
+}
+
+func (p *parser) parseIf(cond string) (node, error) {
+	n := &ifNode{}
+	if strings.HasPrefix(cond, "!") {
+		n.negate = true
+		cond = strings.TrimSpace(cond[1:])
+	}
+	if len(cond) == 0 {
+		return nil, fmt.Errorf("empty if condition at pos:%d", p.pos)
+	}
+	n.field = cond
+	then, end, err := p.parseNodes()
+	if err != nil {
+		return nil, err
+	}
+	n.then = then
+	if end == "else" {
+		els, end2, err := p.parseNodes()
+		if err != nil {
+			return nil, err
+		}
+		n.els = els
+		end = end2
+	}
+	if end != "end" {
+		return nil, fmt.Errorf("if %s not closed by {end}", cond)
+	}
+	return n, nil
+}
+
+func parseValue(expr string) (node, error) {
+	items := strings.Split(expr, "|")
+	field := strings.TrimSpace(items[0])
+	if len(field) == 0 {
+		return nil, fmt.Errorf("empty field")
+	}
+	n := &valueNode{field: field}
+	for _, item := range items[1:] {
+		parts := strings.Split(item, ":")
+		name := strings.TrimSpace(parts[0])
+		def, ok := funcs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown func:%s", name)
+		}
+		args := parts[1:]
+		if len(args) != def.argc {
+			return nil, fmt.Errorf("func:%s requires %d args, got:%d", name, def.argc, len(args))
+		}
+		n.funcs = append(n.funcs, &funcCall{name: name, args: args, fn: def.fn})
+	}
+	return n, nil
+}
@@ -0,0 +1,64 @@
+package naming
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	vars := map[string]interface{}{
+		"NUMBER": "ABC-123",
+		"YEAR":   "2024",
+		"TITLE":  "一个非常非常长的标题",
+		"STUDIO": "",
+		"ACTORS": []string{"a", "b", "c"},
+		"CNSUB":  true,
+		"4K":     false,
+	}
+	tests := []struct {
+		rule string
+		out  string
+	}{
+		{"{YEAR}/{NUMBER}", "2024/ABC-123"},
+		{"{ YEAR }/{NUMBER|lower}", "2024/abc-123"},
+		{"{TITLE|truncate:4}", "一个非常"},
+		{"{STUDIO|default:unknown|upper}", "UNKNOWN"},
+		{"{ACTORS}", "a,b,c"},
+		{"{ACTORS|first:2|join: & }", "a & b"},
+		{"{NUMBER}{if CNSUB}-C{end}{if 4K}-4K{end}", "ABC-123-C"},
+		{"{if !4K}HD{else}4K{end}", "HD"},
+		{"{if STUDIO}{STUDIO}{else}{if ACTORS}{ACTORS|first:1}{end}{end}", "a"},
+		{"{NUMBER|replace:-:_}", "ABC_123"},
+		{"plain", "plain"},
+	}
+	for _, tst := range tests {
+		tpl, err := Parse(tst.rule)
+		assert.NoError(t, err, tst.rule)
+		out, err := tpl.Render(vars)
+		assert.NoError(t, err, tst.rule)
+		assert.Equal(t, tst.out, out, tst.rule)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, rule := range []string{
+		"{NUMBER",
+		"NUMBER}",
+		"{NUMBER|unknown}",
+		"{NUMBER|truncate}",
+		"{if CNSUB}-C",
+		"{end}",
+		"{if }x{end}",
+		"{}",
+	} {
+		_, err := Parse(rule)
+		assert.Error(t, err, rule)
+	}
+	tpl := MustParse("{UNKNOWN}")
+	_, err := tpl.Render(map[string]interface{}{})
+	assert.Error(t, err)
+	tpl = MustParse("{NUMBER|first:1}")
+	_, err = tpl.Render(map[string]interface{}{"NUMBER": "x"})
+	assert.Error(t, err)
+}
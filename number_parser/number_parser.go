@@ -203,23 +203,25 @@ func ReorganizeAllNumbers(fcs []*model.FileContext) {
 	// 1. 遍历 fcs, 找到所有的番号含C的
 	for _, fc := range fcs {
 		// 如果是单集, 则跳过
-		if fc.Number.Episode == "C" {
-			// 查找出 fcs中 同GetNumberID 的所有文件
-			sameNumberIDs := lo.Filter(fcs, func(itemIn *model.FileContext, _ int) bool {
-				return itemIn.Number.GetNumberID() == fc.Number.GetNumberID()
-			})
-			// 找出是否有同id中 含有 B 集且目录相同的
-			isSameDirContainB := lo.ContainsBy(sameNumberIDs, func(itemIn *model.FileContext) bool {
-				return (itemIn.Number.Episode == "B") && itemIn.Dir(0) == fc.Dir(0)
-			})
-			if isSameDirContainB {
-				fc.Number.Episode = "C"
-				fc.Number.SetIsChineseSubtitle(false)
-			} else {
-			}
-
-		} else {
+		if fc.Number.Episode != "C" {
+			continue
+		}
+		// 查找出 fcs中 同GetNumberID 的所有文件
+		sameNumberIDs := lo.Filter(fcs, func(itemIn *model.FileContext, _ int) bool {
+			return itemIn.Number.GetNumberID() == fc.Number.GetNumberID()
+		})
+		// 找出是否有同id中 含有 B 集且目录相同的
+		isSameDirContainB := lo.ContainsBy(sameNumberIDs, func(itemIn *model.FileContext) bool {
+			return (itemIn.Number.Episode == "B") && itemIn.Dir(0) == fc.Dir(0)
+		})
+		if isSameDirContainB {
+			fc.Number.Episode = "C"
+			fc.Number.SetIsChineseSubtitle(false)
+			continue
 		}
+		// 同目录下不存在B集, 那么这里的C表示中文字幕
+		fc.Number.Episode = ""
+		fc.Number.SetIsChineseSubtitle(true)
 	}
 }
 
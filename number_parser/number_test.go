@@ -101,3 +101,20 @@ func TestCategory(t *testing.T) {
 func TestAlnumber(t *testing.T) {
 	assert.Equal(t, "fc2ppv12345", GetCleanID("fc2-ppv_12345"))
 }
+
+func TestReorganizeAllNumbers(t *testing.T) {
+	newFc := func(f string, ep string) *model.FileContext {
+		return &model.FileContext{FullFilePath: f, Number: &model.Number{NumberId: "ABC-123", Episode: ep}}
+	}
+	fcs := []*model.FileContext{
+		newFc("/a/ABC-123-A.mp4", "A"),
+		newFc("/a/ABC-123-B.mp4", "B"),
+		newFc("/a/ABC-123-C.mp4", "C"),
+		newFc("/b/ABC-123-C.mp4", "C"),
+	}
+	ReorganizeAllNumbers(fcs)
+	assert.Equal(t, "C", fcs[2].Number.Episode)
+	assert.False(t, fcs[2].Number.GetIsChineseSubtitle())
+	assert.Equal(t, "", fcs[3].Number.Episode)
+	assert.True(t, fcs[3].Number.GetIsChineseSubtitle())
+}
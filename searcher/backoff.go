@@ -0,0 +1,83 @@
+package searcher
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultBlockedBackoff     = 2 * time.Minute
+	defaultRateLimitedBackoff = 5 * time.Minute
+)
+
+type backoffItem struct {
+	until time.Time //open为true时无效
+	open  bool      //熔断, 本次运行内不再使用该插件
+	err   error
+}
+
+// pluginBackoff 被站点拦截或者限流的插件在一段时间内不再发起请求,
+// 连续被拦截的次数达到阈值后熔断, 本次运行内不再使用, 每次运行开始时重置
+type pluginBackoff struct {
+	mu     sync.Mutex
+	m      map[string]*backoffItem
+	blocks map[string]int
+}
+
+var defaultBackoff = newPluginBackoff()
+
+func newPluginBackoff() *pluginBackoff {
+	return &pluginBackoff{m: make(map[string]*backoffItem), blocks: make(map[string]int)}
+}
+
+func (b *pluginBackoff) set(name string, d time.Duration, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.m[name] = &backoffItem{until: time.Now().Add(d), err: err}
+}
+
+// onBlocked 记录一次拦截, 返回是否已经熔断
+func (b *pluginBackoff) onBlocked(name string, threshold int, err error) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.blocks[name]++
+	if b.blocks[name] >= threshold {
+		b.m[name] = &backoffItem{open: true, err: err}
+		return true
+	}
+	b.m[name] = &backoffItem{until: time.Now().Add(defaultBlockedBackoff), err: err}
+	return false
+}
+
+// onSuccess 请求正常完成(包括未找到)时清空连续拦截次数
+func (b *pluginBackoff) onSuccess(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.blocks, name)
+}
+
+func (b *pluginBackoff) get(name string) (*backoffItem, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	item, ok := b.m[name]
+	if !ok {
+		return nil, false
+	}
+	if !item.open && time.Now().After(item.until) {
+		delete(b.m, name)
+		return nil, false
+	}
+	return item, true
+}
+
+func (b *pluginBackoff) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.m = make(map[string]*backoffItem)
+	b.blocks = make(map[string]int)
+}
+
+// ResetBackoff 清空插件的退避及熔断状态, 在每次运行开始时调用
+func ResetBackoff() {
+	defaultBackoff.reset()
+}
@@ -9,12 +9,13 @@ import (
 )
 
 type categorySearcher struct {
+	c            *config
 	defSearcher  []ISearcher
 	catSearchers map[model.Category][]ISearcher
 }
 
-func NewCategorySearcher(def []ISearcher, cats map[model.Category][]ISearcher) ISearcher {
-	return &categorySearcher{defSearcher: def, catSearchers: cats}
+func NewCategorySearcher(def []ISearcher, cats map[model.Category][]ISearcher, opts ...Option) ISearcher {
+	return &categorySearcher{c: applyOpts(opts...), defSearcher: def, catSearchers: cats}
 }
 
 func (s *categorySearcher) Name() string {
@@ -34,6 +35,13 @@ func (s *categorySearcher) Search(ctx context.Context, n *model.Number) (*model.
 			logger.Debug("use cat chain for search")
 		}
 	}
+	//单个文件可以通过覆盖配置指定插件链, 此时从全部插件中挑选
+	cats := make([][]ISearcher, 0, len(s.catSearchers)+1)
+	cats = append(cats, s.defSearcher)
+	for _, c := range s.catSearchers {
+		cats = append(cats, c)
+	}
+	chain = selectPluginChain(ctx, chain, cats...)
 
-	return performGroupSearch(ctx, n, chain)
+	return performGroupSearch(ctx, n, chain, s.c)
 }
@@ -0,0 +1,128 @@
+package searcher
+
+import "time"
+
+const (
+	defaultAcceptScore       = 80
+	defaultPluginTimeout     = 30 * time.Second
+	defaultMaxRetry          = 2
+	defaultRetryBaseInterval = time.Second
+	defaultRetryMaxInterval  = 30 * time.Second
+	defaultBreakerThreshold  = 3
+	defaultMatchThreshold    = 60
+)
+
+type config struct {
+	Parallel      int           //同时查询的插件数, 小于等于1时按顺序查询
+	PluginTimeout time.Duration //并发模式下单个插件的超时时间
+	AcceptScore   int           //并发模式下结果得分达到该值后取消其他插件的查询
+
+	Merge           bool                 //是否查询多个插件并按字段合并结果
+	MergeMaxSources int                  //参与合并的最大来源数, 0为不限制
+	MergeRules      map[string]MergeRule //字段的合并规则
+
+	MaxRetry          int           //单个请求遇到临时错误时的最大重试次数
+	RetryBaseInterval time.Duration //首次重试的等待时间, 之后按指数增长
+	RetryMaxInterval  time.Duration //单次等待的最大时间
+	RateLimiter       *RateLimiter  //按插件及域名限制请求频率
+	BreakerThreshold  int           //插件连续被拦截的次数达到该值后, 本次运行内不再使用
+	MatchThreshold    int           //结果的匹配度低于该值时视为未找到, 0为不校验
+}
+
+// pluginTimeout 仅并发模式下限制单个插件的超时时间
+func (c *config) pluginTimeout() time.Duration {
+	if c.Parallel <= 1 {
+		return 0
+	}
+	return c.PluginTimeout
+}
+
+type Option func(c *config)
+
+// WithParallel 同时查询排名靠前的n个插件, 小于等于1时按顺序查询
+func WithParallel(n int) Option {
+	return func(c *config) {
+		c.Parallel = n
+	}
+}
+
+// WithPluginTimeout 并发模式下单个插件的超时时间
+func WithPluginTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.PluginTimeout = d
+	}
+}
+
+// WithAcceptScore 并发模式下结果得分(0~100)达到该值后直接使用, 并取消其他插件的查询
+func WithAcceptScore(score int) Option {
+	return func(c *config) {
+		c.AcceptScore = score
+	}
+}
+
+// WithMerge 查询插件链中的多个插件, 并按字段的合并规则合并结果
+func WithMerge(maxSources int, rules map[string]MergeRule) Option {
+	return func(c *config) {
+		c.Merge = true
+		c.MergeMaxSources = maxSources
+		c.MergeRules = rules
+	}
+}
+
+// WithMaxRetry 请求遇到超时, 5xx及429等临时错误时的最大重试次数, 小于等于0时不重试
+func WithMaxRetry(n int) Option {
+	return func(c *config) {
+		c.MaxRetry = n
+	}
+}
+
+// WithRetryInterval 重试的等待时间, 从baseInterval开始按指数增长并加入随机抖动, 单次等待不超过maxInterval
+func WithRetryInterval(baseInterval, maxInterval time.Duration) Option {
+	return func(c *config) {
+		if baseInterval > 0 {
+			c.RetryBaseInterval = baseInterval
+		}
+		if maxInterval > 0 {
+			c.RetryMaxInterval = maxInterval
+		}
+	}
+}
+
+// WithRateLimiter 使用令牌桶按插件及域名限制请求频率, 同一个limiter可以在多个插件间共享
+func WithRateLimiter(l *RateLimiter) Option {
+	return func(c *config) {
+		c.RateLimiter = l
+	}
+}
+
+// WithBreakerThreshold 插件连续被拦截n次后, 本次运行内不再使用该插件
+func WithBreakerThreshold(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.BreakerThreshold = n
+		}
+	}
+}
+
+// WithMatchThreshold 结果的匹配度(0~100)低于该值时视为未找到, 继续查询下一个插件, 小于等于0时不校验
+func WithMatchThreshold(n int) Option {
+	return func(c *config) {
+		c.MatchThreshold = n
+	}
+}
+
+func applyOpts(opts ...Option) *config {
+	c := &config{
+		PluginTimeout:     defaultPluginTimeout,
+		AcceptScore:       defaultAcceptScore,
+		MaxRetry:          defaultMaxRetry,
+		RetryBaseInterval: defaultRetryBaseInterval,
+		RetryMaxInterval:  defaultRetryMaxInterval,
+		BreakerThreshold:  defaultBreakerThreshold,
+		MatchThreshold:    defaultMatchThreshold,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
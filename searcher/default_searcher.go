@@ -2,7 +2,9 @@ package searcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"strings"
 	"time"
@@ -11,6 +13,7 @@ import (
 	"yamdc/hasher"
 	"yamdc/model"
 	"yamdc/searcher/plugin/api"
+	"yamdc/searcher/plugin/domainpool"
 	"yamdc/searcher/plugin/meta"
 	"yamdc/store"
 	"yamdc/useragent"
@@ -21,17 +24,19 @@ import (
 
 const (
 	defaultPageSearchCacheExpire = 30 * 24 * time.Hour
+	defaultNotFoundCacheExpire   = 24 * time.Hour //未找到的结果缓存较短的时间, 避免错过站点后续收录的影片
 )
 
 type DefaultSearcher struct {
+	c       *config
 	name    string
 	ua      string
 	invoker api.HTTPInvoker
 	plg     api.IPlugin
 }
 
-func MustNewDefaultSearcher(name string, plg api.IPlugin) ISearcher {
-	s, err := NewDefaultSearcher(name, plg)
+func MustNewDefaultSearcher(name string, plg api.IPlugin, opts ...Option) ISearcher {
+	s, err := NewDefaultSearcher(name, plg, opts...)
 	if err != nil {
 		panic(err)
 	}
@@ -45,12 +50,14 @@ func defaultInvoker() api.HTTPInvoker {
 	}
 }
 
-func NewDefaultSearcher(name string, plg api.IPlugin) (ISearcher, error) {
+// NewDefaultSearcher 创建插件的搜索器, 支持WithMaxRetry, WithRetryInterval, WithRateLimiter, WithBreakerThreshold及WithMatchThreshold
+func NewDefaultSearcher(name string, plg api.IPlugin, opts ...Option) (ISearcher, error) {
 	invoker := plg.OnHTTPClientInit()
 	if invoker == nil {
 		invoker = defaultInvoker()
 	}
 	ss := &DefaultSearcher{
+		c:       applyOpts(opts...),
 		name:    name,
 		invoker: invoker,
 		plg:     plg,
@@ -97,41 +104,206 @@ func (p *DefaultSearcher) invokeHTTPRequest(ctx context.Context, req *http.Reque
 	if err := p.decorateRequest(ctx, req); err != nil {
 		return nil, fmt.Errorf("decorate request failed, err:%w", err)
 	}
-	return p.invoker(ctx, req)
+	//并发搜索时需要能够取消请求
+	rsp, err := p.doRequest(ctx, req.WithContext(ctx))
+	if err != nil && ctx.Err() == nil {
+		return nil, api.WrapError(api.ErrNetwork, err)
+	}
+	return rsp, err
+}
+
+// doRequest 限流后发起请求, 遇到临时错误时按指数退避重试;
+// 域名池在单次尝试内可能已经切换镜像重试过, 这些请求同样计入重试次数, 因此单次请求最多发出max_retry+镜像数次
+func (p *DefaultSearcher) doRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
+	sent := 0
+	//域名限流在域名池内按实际请求的域名执行, 故障转移后的镜像同样受到限制
+	ctx = domainpool.WithRequestGate(ctx, func(ctx context.Context, host string) error {
+		sent++
+		if err := p.c.RateLimiter.WaitHost(ctx, host); err != nil {
+			return fmt.Errorf("wait host rate limit failed, err:%w", err)
+		}
+		return nil
+	})
+	for attempt := 0; ; attempt++ {
+		if err := p.c.RateLimiter.WaitPlugin(ctx, p.name); err != nil {
+			return nil, fmt.Errorf("wait rate limit failed, err:%w", err)
+		}
+		rsp, err := p.invoker(ctx, req)
+		used := attempt
+		if sent-1 > used {
+			used = sent - 1
+		}
+		wait, ok := p.retryInterval(ctx, req, rsp, err, used)
+		if !ok {
+			return rsp, err
+		}
+		nextReq, rerr := rebuildRequest(ctx, req)
+		if rerr != nil {
+			return rsp, err
+		}
+		if rsp != nil {
+			rsp.Body.Close()
+		}
+		logutil.GetLogger(ctx).Warn("request failed with transient error, retry later", zap.String("plugin", p.name),
+			zap.String("url", req.URL.String()), zap.Int("attempt", attempt+1), zap.Duration("wait", wait), zap.Error(transientReason(rsp, err)))
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+		req = nextReq
+	}
+}
+
+// transientReason 返回可重试的错误原因, 不可重试时返回nil
+func transientReason(rsp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	if rsp.StatusCode == http.StatusTooManyRequests || rsp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("http status code:%d", rsp.StatusCode)
+	}
+	return nil
+}
+
+// retryInterval 计算下次重试前的等待时间, 优先使用Retry-After, 超过最大等待时间时不再重试
+func (p *DefaultSearcher) retryInterval(ctx context.Context, req *http.Request, rsp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= p.c.MaxRetry || ctx.Err() != nil || transientReason(rsp, err) == nil {
+		return 0, false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return 0, false
+	}
+	if rsp != nil {
+		if d, ok := api.ParseRetryAfter(rsp.Header.Get("Retry-After"), time.Now()); ok {
+			return d, d <= p.c.RetryMaxInterval
+		}
+	}
+	return jitterBackoff(p.c.RetryBaseInterval, p.c.RetryMaxInterval, attempt), true
 }
 
-func (p *DefaultSearcher) onRetriveData(ctx context.Context, req *http.Request, number *model.Number) ([]byte, error) {
+// jitterBackoff 指数退避, 实际等待时间在[d/2, d]之间随机, 避免多个请求同时重试
+func jitterBackoff(base, max time.Duration, attempt int) time.Duration {
+	d := base << attempt
+	if d <= 0 || d > max {
+		d = max
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func rebuildRequest(ctx context.Context, req *http.Request) (*http.Request, error) {
+	newReq := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("rebuild request body failed, err:%w", err)
+		}
+		newReq.Body = body
+	}
+	return newReq, nil
+}
+
+func (p *DefaultSearcher) makeHTTPRequest(ctx context.Context, number *model.Number) (*http.Request, bool, error) {
+	if link, ok := pinnedDetailURL(ctx, p.name); ok {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+		if err != nil {
+			return nil, false, fmt.Errorf("make request for pinned url:%s failed, err:%w", link, err)
+		}
+		return req, true, nil
+	}
+	req, err := p.plg.OnMakeHTTPRequest(ctx, number)
+	if err != nil {
+		return nil, false, err
+	}
+	return req, false, nil
+}
+
+func (p *DefaultSearcher) onRetriveData(ctx context.Context, req *http.Request, number *model.Number, pinned bool) ([]byte, error) {
 	key := p.name + ":" + number.GetNumberID()
+	handler := p.plg.OnHandleHTTPRequest
+	if pinned {
+		//固定了详情页时直接请求该页面, 跳过插件自身的搜索逻辑
+		key = p.name + ":" + hasher.ToSha1(req.URL.String())
+		handler = func(ctx context.Context, invoker api.HTTPInvoker, req *http.Request) (*http.Response, error) {
+			return invoker(ctx, req)
+		}
+	}
 	dataLoader := func() ([]byte, error) {
-		rsp, err := p.plg.OnHandleHTTPRequest(ctx, p.invokeHTTPRequest, req)
+		rsp, err := handler(ctx, p.invokeHTTPRequest, req)
 		if err != nil {
 			return nil, fmt.Errorf("do request failed, err:%w", err)
 		}
+		defer rsp.Body.Close()
 		isSearchSucc, err := p.plg.OnPrecheckResponse(ctx, req, rsp)
 		if err != nil {
 			return nil, fmt.Errorf("precheck responnse failed, err:%w", err)
 		}
 		if !isSearchSucc {
-			return nil, fmt.Errorf("no data found")
+			return nil, api.WrapError(api.ErrNotFound, fmt.Errorf("no data found"))
 		}
 		if rsp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("invalid http status code:%d", rsp.StatusCode)
+			return nil, api.NewStatusError(rsp)
 		}
-		defer rsp.Body.Close()
 		data, err := client.ReadHTTPData(rsp)
 		if err != nil {
-			return nil, fmt.Errorf("read body failed, err:%w", err)
+			return nil, api.WrapError(api.ErrNetwork, fmt.Errorf("read body failed, err:%w", err))
 		}
 		return data, nil
 	}
 	if !envflag.IsEnableSearchMetaCache() {
 		return dataLoader()
 	}
-	return store.LoadData(ctx, key, defaultPageSearchCacheExpire, dataLoader)
+	notFoundKey := "notfound:" + key
+	if ok, _ := store.IsDataExist(ctx, notFoundKey); ok {
+		return nil, api.WrapError(api.ErrNotFound, fmt.Errorf("cached not found result"))
+	}
+	data, err := store.LoadData(ctx, key, defaultPageSearchCacheExpire, dataLoader)
+	if errors.Is(err, api.ErrNotFound) {
+		if perr := store.PutDataWithExpire(ctx, notFoundKey, []byte{1}, defaultNotFoundCacheExpire); perr != nil {
+			logutil.GetLogger(ctx).Error("cache not found result failed", zap.Error(perr), zap.String("plugin", p.name))
+		}
+	}
+	return data, err
+}
+
+// onSearchError 根据错误类型处理搜索失败: 未找到不视为错误, 被拦截或者限流时插件暂停使用一段时间
+func (p *DefaultSearcher) onSearchError(ctx context.Context, err error) (*model.AvMeta, bool, error) {
+	logger := logutil.GetLogger(ctx).With(zap.String("plugin", p.name), zap.String("kind", api.ErrorKind(err)))
+	switch {
+	case errors.Is(err, api.ErrNotFound):
+		defaultBackoff.onSuccess(p.name)
+		logger.Debug("search item not found", zap.Error(err))
+		return nil, false, nil
+	case errors.Is(err, api.ErrBlocked):
+		if defaultBackoff.onBlocked(p.name, p.c.BreakerThreshold, err) {
+			logger.Error("plugin blocked by site repeatedly, disable it for the rest of run", zap.Int("threshold", p.c.BreakerThreshold), zap.Error(err))
+			break
+		}
+		logger.Warn("plugin blocked by site, back off", zap.Duration("duration", defaultBlockedBackoff), zap.Error(err))
+	case errors.Is(err, api.ErrRateLimited):
+		d := defaultRateLimitedBackoff
+		if ra, ok := api.RetryAfter(err); ok {
+			d = ra
+		}
+		defaultBackoff.set(p.name, d, err)
+		logger.Warn("plugin rate limited by site, back off", zap.Duration("duration", d), zap.Error(err))
+	case errors.Is(err, api.ErrDecode):
+		logger.Error("decode data failed, plugin may be broken", zap.Error(err))
+	}
+	return nil, false, err
 }
 
 func (p *DefaultSearcher) Search(ctx context.Context, number *model.Number) (*model.AvMeta, bool, error) {
 	ctx = meta.SetNumberId(ctx, number.GetNumberID())
+	if item, ok := defaultBackoff.get(p.name); ok {
+		if item.open {
+			return nil, false, fmt.Errorf("plugin disabled by circuit breaker for the rest of run, err:%w", item.err)
+		}
+		return nil, false, fmt.Errorf("plugin in backoff until:%s, err:%w", item.until.Format(time.DateTime), item.err)
+	}
 	ok, err := p.plg.OnPrecheckRequest(ctx, number)
 	if err != nil {
 		return nil, false, fmt.Errorf("precheck failed, err:%w", err)
@@ -139,28 +311,44 @@ func (p *DefaultSearcher) Search(ctx context.Context, number *model.Number) (*mo
 	if !ok {
 		return nil, false, nil
 	}
-	req, err := p.plg.OnMakeHTTPRequest(ctx, number)
+	req, pinned, err := p.makeHTTPRequest(ctx, number)
 	if err != nil {
 		return nil, false, fmt.Errorf("make http request failed, err:%w", err)
 	}
-	data, err := p.onRetriveData(ctx, req, number)
+	if pinned {
+		logutil.GetLogger(ctx).Info("use pinned detail url", zap.String("plugin", p.name), zap.String("url", req.URL.String()))
+	}
+	data, err := p.onRetriveData(ctx, req, number, pinned)
 	if err != nil {
-		return nil, false, err
+		return p.onSearchError(ctx, err)
 	}
+	defaultBackoff.onSuccess(p.name)
 	meta, decodeSucc, err := p.plg.OnDecodeHTTPData(ctx, data)
 	if err != nil {
-		return nil, false, fmt.Errorf("decode http data failed, err:%w", err)
+		return p.onSearchError(ctx, api.WrapError(api.ErrDecode, fmt.Errorf("decode http data failed, err:%w", err)))
 	}
 	if !decodeSucc {
 		return nil, false, nil
 	}
 	//重建不规范的元数据
 	p.fixMeta(req, meta)
+	if err := p.verifyMeta(meta); err != nil {
+		//页面正常返回但缺少关键字段, 通常意味着站点改版
+		return p.onSearchError(ctx, api.WrapError(api.ErrDecode, fmt.Errorf("verify meta failed, err:%w", err)))
+	}
+	//用户指定的详情页不做校验; 在下载图片前校验, 避免为错误的结果下载图片
+	if !pinned {
+		if score := scoreMatch(ctx, number, meta, time.Now()); score < p.c.MatchThreshold {
+			logutil.GetLogger(ctx).Warn("match score too low, treat as not found", zap.String("plugin", p.name),
+				zap.String("search", meta.Number), zap.String("file", number.GetNumberID()), zap.Int("score", score), zap.Int("threshold", p.c.MatchThreshold))
+			return nil, false, nil
+		}
+	}
 	//将远程数据保存到本地, 并替换文件key
 	p.storeImageData(ctx, meta)
-	if err := p.verifyMeta(meta); err != nil {
-		logutil.GetLogger(ctx).Error("verify meta not pass, treat as not found", zap.Error(err), zap.String("plugin", p.name))
-		return nil, false, nil
+	if meta.Cover == nil {
+		//封面地址已经通过校验, 下载失败属于网络问题而非插件失效
+		return p.onSearchError(ctx, api.WrapError(api.ErrNetwork, fmt.Errorf("download cover failed")))
 	}
 	meta.ExtInfo.ScrapeInfo.Source = p.name
 	meta.ExtInfo.ScrapeInfo.DateTs = time.Now().UnixMilli()
@@ -275,7 +463,7 @@ func (p *DefaultSearcher) fetchImageData(ctx context.Context, url string) ([]byt
 	if err := p.decorateImageRequest(ctx, req); err != nil {
 		return nil, fmt.Errorf("decode request failed, err:%w", err)
 	}
-	rsp, err := p.invoker(ctx, req)
+	rsp, err := p.doRequest(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("get url data failed, err:%w", err)
 	}
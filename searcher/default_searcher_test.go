@@ -0,0 +1,301 @@
+package searcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+	"yamdc/envflag"
+	"yamdc/model"
+	"yamdc/searcher/plugin/api"
+	"yamdc/searcher/plugin/domainpool"
+	"yamdc/store"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type httpTestPlugin struct {
+	api.DefaultPlugin
+	base string
+}
+
+func (p *httpTestPlugin) OnMakeHTTPRequest(ctx context.Context, number *model.Number) (*http.Request, error) {
+	return http.NewRequest(http.MethodGet, p.base+"/"+number.GetNumberID(), nil)
+}
+
+func (p *httpTestPlugin) OnHTTPClientInit() api.HTTPInvoker {
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return http.DefaultClient.Do(req)
+	}
+}
+
+func (p *httpTestPlugin) OnDecodeHTTPData(ctx context.Context, data []byte) (*model.AvMeta, bool, error) {
+	return nil, false, fmt.Errorf("unexpected page:%s", string(data))
+}
+
+func TestStatusErrorKind(t *testing.T) {
+	tests := []struct {
+		code   int
+		header http.Header
+		kind   string
+	}{
+		{code: http.StatusNotFound, kind: api.ErrKindNotFound},
+		{code: http.StatusTooManyRequests, kind: api.ErrKindRateLimited},
+		{code: http.StatusForbidden, kind: api.ErrKindBlocked},
+		{code: http.StatusServiceUnavailable, header: http.Header{"Server": []string{"cloudflare"}}, kind: api.ErrKindBlocked},
+		{code: http.StatusServiceUnavailable, kind: api.ErrKindUnknown},
+	}
+	for _, tst := range tests {
+		err := api.NewStatusError(&http.Response{StatusCode: tst.code, Header: tst.header})
+		assert.Equal(t, tst.kind, api.ErrorKind(err), "code:%d", tst.code)
+	}
+	err := api.WrapError(api.ErrDecode, fmt.Errorf("wrap:%w", api.WrapError(api.ErrNotFound, errors.New("x"))))
+	assert.Equal(t, api.ErrKindNotFound, api.ErrorKind(err))
+}
+
+func TestDefaultSearcherErrorKind(t *testing.T) {
+	store.SetStorage(store.MustNewSqliteStorage(filepath.Join(t.TempDir(), "cache.db")))
+	envflag.GetFlag().EnableSearchMetaCache = true
+	defer func() {
+		envflag.GetFlag().EnableSearchMetaCache = false
+	}()
+	ResetBackoff()
+	defer ResetBackoff()
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/NOTFOUND-1":
+			w.WriteHeader(http.StatusNotFound)
+		case "/BLOCK-1":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			_, _ = w.Write([]byte("changed layout"))
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	s := MustNewDefaultSearcher("test", &httpTestPlugin{base: srv.URL})
+
+	//未找到不视为错误, 且结果会被缓存
+	for i := 0; i < 2; i++ {
+		_, ok, err := s.Search(ctx, &model.Number{NumberId: "NOTFOUND-1"})
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	//解析失败
+	_, _, err := s.Search(ctx, &model.Number{NumberId: "DECODE-1"})
+	assert.Error(t, err)
+	assert.Equal(t, api.ErrKindDecode, api.ErrorKind(err))
+
+	//被拦截后插件进入退避状态, 不再发起请求
+	_, _, err = s.Search(ctx, &model.Number{NumberId: "BLOCK-1"})
+	assert.Equal(t, api.ErrKindBlocked, api.ErrorKind(err))
+	before := atomic.LoadInt32(&hits)
+	_, _, err = s.Search(ctx, &model.Number{NumberId: "DECODE-2"})
+	assert.Equal(t, api.ErrKindBlocked, api.ErrorKind(err))
+	assert.Equal(t, before, atomic.LoadInt32(&hits))
+	ResetBackoff()
+	_, _, err = s.Search(ctx, &model.Number{NumberId: "DECODE-2"})
+	assert.Equal(t, api.ErrKindDecode, api.ErrorKind(err))
+}
+
+type incompletePlugin struct {
+	httpTestPlugin
+}
+
+func (p *incompletePlugin) OnDecodeHTTPData(ctx context.Context, data []byte) (*model.AvMeta, bool, error) {
+	return &model.AvMeta{Number: "ABC-123", Title: "title"}, true, nil
+}
+
+func TestDefaultSearcherIncompleteMeta(t *testing.T) {
+	ResetBackoff()
+	defer ResetBackoff()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	//缺少封面等关键字段视为解析失败, 以便在报告中标记插件可能已经失效
+	s := MustNewDefaultSearcher("incomplete", &incompletePlugin{httpTestPlugin{base: srv.URL}})
+	_, ok, err := s.Search(context.Background(), &model.Number{NumberId: "ABC-123"})
+	assert.False(t, ok)
+	assert.Equal(t, api.ErrKindDecode, api.ErrorKind(err))
+}
+
+type coverFailPlugin struct {
+	httpTestPlugin
+}
+
+func (p *coverFailPlugin) OnHTTPClientInit() api.HTTPInvoker {
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/cover.jpg" {
+			return nil, errors.New("connection reset by peer")
+		}
+		return http.DefaultClient.Do(req)
+	}
+}
+
+func (p *coverFailPlugin) OnDecodeHTTPData(ctx context.Context, data []byte) (*model.AvMeta, bool, error) {
+	return &model.AvMeta{Number: "ABC-123", Title: "title", Cover: &model.File{Name: "/cover.jpg"}, ReleaseDate: time.Now().UnixMilli()}, true, nil
+}
+
+func TestDefaultSearcherCoverDownloadFailed(t *testing.T) {
+	store.SetStorage(store.MustNewSqliteStorage(filepath.Join(t.TempDir(), "cache.db")))
+	ResetBackoff()
+	defer ResetBackoff()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	//封面下载失败属于网络错误, 不应被当作插件失效
+	s := MustNewDefaultSearcher("cover", &coverFailPlugin{httpTestPlugin{base: srv.URL}}, WithMaxRetry(0))
+	_, ok, err := s.Search(context.Background(), &model.Number{NumberId: "ABC-123"})
+	assert.False(t, ok)
+	assert.Equal(t, api.ErrKindNetwork, api.ErrorKind(err))
+}
+
+type okPlugin struct {
+	httpTestPlugin
+}
+
+func (p *okPlugin) OnDecodeHTTPData(ctx context.Context, data []byte) (*model.AvMeta, bool, error) {
+	return nil, false, nil
+}
+
+func TestDefaultSearcherRetry(t *testing.T) {
+	ResetBackoff()
+	defer ResetBackoff()
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/FLAKY-1":
+			if n <= 2 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+		case "/SLOWDOWN-1":
+			if n <= 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		case "/LIMIT-1":
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	s := MustNewDefaultSearcher("retry", &okPlugin{httpTestPlugin{base: srv.URL}}, WithRetryInterval(time.Millisecond, 10*time.Millisecond))
+
+	//5xx按指数退避重试
+	_, _, err := s.Search(ctx, &model.Number{NumberId: "FLAKY-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+
+	//429遵循Retry-After
+	atomic.StoreInt32(&hits, 0)
+	_, _, err = s.Search(ctx, &model.Number{NumberId: "SLOWDOWN-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+
+	//Retry-After超过最大等待时间时不再重试, 插件按Retry-After退避
+	atomic.StoreInt32(&hits, 0)
+	_, _, err = s.Search(ctx, &model.Number{NumberId: "LIMIT-1"})
+	assert.Equal(t, api.ErrKindRateLimited, api.ErrorKind(err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+	item, ok := defaultBackoff.get("retry")
+	assert.True(t, ok)
+	assert.True(t, time.Until(item.until) > 100*time.Second)
+
+	//不重试
+	ResetBackoff()
+	atomic.StoreInt32(&hits, 0)
+	s = MustNewDefaultSearcher("noretry", &okPlugin{httpTestPlugin{base: srv.URL}}, WithMaxRetry(0))
+	_, _, err = s.Search(ctx, &model.Number{NumberId: "FLAKY-1"})
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+type mirrorPlugin struct {
+	httpTestPlugin
+	domains []string
+}
+
+func (p *mirrorPlugin) OnMakeHTTPRequest(ctx context.Context, number *model.Number) (*http.Request, error) {
+	return http.NewRequest(http.MethodGet, "http://"+api.SelectPluginDomain(ctx, p.domains)+"/"+number.GetNumberID(), nil)
+}
+
+func TestDefaultSearcherRetryWithMirrors(t *testing.T) {
+	ResetBackoff()
+	defer ResetBackoff()
+	old := domainpool.Default()
+	domainpool.SetDefault(domainpool.New())
+	defer domainpool.SetDefault(old)
+	var hits int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	srv1 := httptest.NewServer(handler)
+	defer srv1.Close()
+	srv2 := httptest.NewServer(handler)
+	defer srv2.Close()
+	plg, err := api.WithPluginConfig(&mirrorPlugin{domains: []string{srv1.Listener.Addr().String(), srv2.Listener.Addr().String()}}, &api.PluginConfig{})
+	assert.NoError(t, err)
+	//域名池内切换镜像的请求同样计入重试次数
+	s := MustNewDefaultSearcher("mirror", plg, WithMaxRetry(1), WithRetryInterval(time.Millisecond, 10*time.Millisecond))
+	_, _, err = s.Search(context.Background(), &model.Number{NumberId: "ABC-123"})
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+func TestDefaultSearcherCircuitBreaker(t *testing.T) {
+	ResetBackoff()
+	defer ResetBackoff()
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("cf-mitigated", "challenge")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	s := MustNewDefaultSearcher("breaker", &okPlugin{httpTestPlugin{base: srv.URL}}, WithBreakerThreshold(2))
+	expire := func() {
+		defaultBackoff.mu.Lock()
+		defer defaultBackoff.mu.Unlock()
+		if item, ok := defaultBackoff.m["breaker"]; ok {
+			item.until = time.Now().Add(-time.Second)
+		}
+	}
+	_, _, err := s.Search(ctx, &model.Number{NumberId: "ABC-1"})
+	assert.Equal(t, api.ErrKindBlocked, api.ErrorKind(err))
+	item, ok := defaultBackoff.get("breaker")
+	assert.True(t, ok)
+	assert.False(t, item.open)
+	expire()
+	_, _, err = s.Search(ctx, &model.Number{NumberId: "ABC-2"})
+	assert.Equal(t, api.ErrKindBlocked, api.ErrorKind(err))
+	item, ok = defaultBackoff.get("breaker")
+	assert.True(t, ok)
+	assert.True(t, item.open)
+	//熔断后不再请求, 直到下次运行
+	expire()
+	_, _, err = s.Search(ctx, &model.Number{NumberId: "ABC-3"})
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	ResetBackoff()
+	_, _, _ = s.Search(ctx, &model.Number{NumberId: "ABC-4"})
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+}
@@ -8,25 +8,33 @@ import (
 )
 
 type group struct {
+	c  *config
 	ss []ISearcher
 }
 
-func NewGroup(ss []ISearcher) ISearcher {
-	return &group{ss: ss}
+func NewGroup(ss []ISearcher, opts ...Option) ISearcher {
+	return &group{c: applyOpts(opts...), ss: ss}
 }
 func (g *group) Name() string {
 	return "group"
 }
 
 func (g *group) Search(ctx context.Context, number *model.Number) (*model.AvMeta, bool, error) {
-	return performGroupSearch(ctx, number, g.ss)
+	return performGroupSearch(ctx, number, selectPluginChain(ctx, g.ss), g.c)
 }
 
-func performGroupSearch(ctx context.Context, number *model.Number, ss []ISearcher) (*model.AvMeta, bool, error) {
+func performGroupSearch(ctx context.Context, number *model.Number, ss []ISearcher, c *config) (*model.AvMeta, bool, error) {
+	if c.Merge {
+		return performMergeSearch(ctx, number, ss, c)
+	}
+	if c.Parallel > 1 {
+		return performParallelSearch(ctx, number, ss, c)
+	}
 	var lastErr error
 	for _, s := range ss {
 		logutil.GetLogger(ctx).Debug("search number", zap.String("plugin", s.Name()))
 		meta, found, err := s.Search(ctx, number)
+		recordSearchAttempt(ctx, s.Name(), found, err)
 		if err != nil {
 			lastErr = err
 			continue
@@ -0,0 +1,123 @@
+package searcher
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+	"yamdc/model"
+	"yamdc/number_parser"
+)
+
+// 匹配度的组成, 总分为100
+const (
+	matchNumberExact     = 60 //去除分隔符后完全一致
+	matchNumberLoose     = 50 //忽略数字部分的前导0或者FC2的不同写法后一致
+	matchYearPlausible   = 20
+	matchDurationClose   = 20
+	matchDurationNear    = 10 //时长有一定差异, 可能是不同的版本
+	matchUnknownPartial  = 10 //缺少比较的依据时给一半的分数
+	matchMinReleaseYear  = 1980
+	matchDurationTolSec  = 120
+	matchDurationTolRate = 0.1
+	matchDurationMaxRate = 0.3
+)
+
+type localDurationKeyType struct{}
+
+var defaultLocalDurationKey = localDurationKeyType{}
+
+// WithLocalDuration 指定本地影片的时长(秒), 用于校验搜索结果的时长
+func WithLocalDuration(ctx context.Context, sec int64) context.Context {
+	if sec <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, defaultLocalDurationKey, sec)
+}
+
+func localDuration(ctx context.Context) int64 {
+	if v, ok := ctx.Value(defaultLocalDurationKey).(int64); ok {
+		return v
+	}
+	return 0
+}
+
+// canonicalNumber 将番号转换为便于比较的形式: 大写, 移除分隔符, 数字部分移除前导0, FC2统一为FC2+数字
+func canonicalNumber(n string) string {
+	n = strings.ToUpper(number_parser.GetCleanID(strings.TrimSpace(n)))
+	if model.IsFc2(n) {
+		n = strings.TrimPrefix(strings.TrimPrefix(n, "FC2"), "PPV")
+		return "FC2" + trimLeadingZero(n)
+	}
+	sb := strings.Builder{}
+	digits := strings.Builder{}
+	flush := func() {
+		if digits.Len() == 0 {
+			return
+		}
+		sb.WriteString(trimLeadingZero(digits.String()))
+		digits.Reset()
+	}
+	for _, c := range n {
+		if unicode.IsDigit(c) {
+			digits.WriteRune(c)
+			continue
+		}
+		flush()
+		sb.WriteRune(c)
+	}
+	flush()
+	return sb.String()
+}
+
+func trimLeadingZero(s string) string {
+	rs := strings.TrimLeft(s, "0")
+	if len(rs) == 0 && len(s) > 0 {
+		return "0"
+	}
+	return rs
+}
+
+func scoreMatchNumber(number *model.Number, meta *model.AvMeta) int {
+	if strings.EqualFold(number_parser.GetCleanID(meta.Number), number_parser.GetCleanID(number.GetNumberID())) {
+		return matchNumberExact
+	}
+	if canonicalNumber(meta.Number) == canonicalNumber(number.GetNumberID()) {
+		return matchNumberLoose
+	}
+	return 0
+}
+
+func scoreMatchYear(meta *model.AvMeta, now time.Time) int {
+	if meta.ReleaseDate <= 0 {
+		return matchUnknownPartial
+	}
+	year := time.UnixMilli(meta.ReleaseDate).Year()
+	if year < matchMinReleaseYear || year > now.Year()+1 {
+		return 0
+	}
+	return matchYearPlausible
+}
+
+func scoreMatchDuration(number *model.Number, meta *model.AvMeta, local int64) int {
+	//分段的影片只包含部分时长, 无法比较
+	if local <= 0 || meta.Duration <= 0 || number.GetIsMultiCD() {
+		return matchUnknownPartial
+	}
+	diff := local - meta.Duration
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff <= matchDurationTolSec || float64(diff) <= float64(meta.Duration)*matchDurationTolRate {
+		return matchDurationClose
+	}
+	if float64(diff) <= float64(meta.Duration)*matchDurationMaxRate {
+		return matchDurationNear
+	}
+	return 0
+}
+
+// scoreMatch 计算搜索结果与本地影片的匹配度(0~100), 包括番号, 发行年份的合理性以及时长
+func scoreMatch(ctx context.Context, number *model.Number, meta *model.AvMeta, now time.Time) int {
+	return scoreMatchNumber(number, meta) + scoreMatchYear(meta, now) + scoreMatchDuration(number, meta, localDuration(ctx))
+}
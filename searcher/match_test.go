@@ -0,0 +1,129 @@
+package searcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"yamdc/model"
+	"yamdc/store"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalNumber(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{in: "ABC-001", out: "ABC1"},
+		{in: "abc00001", out: "ABC1"},
+		{in: "300MIUM-010", out: "300MIUM10"},
+		{in: "FC2-PPV-0123456", out: "FC2123456"},
+		{in: "FC2-123456", out: "FC2123456"},
+		{in: "fc2ppv_123456", out: "FC2123456"},
+		{in: "ABC-000", out: "ABC0"},
+	}
+	for _, tst := range tests {
+		assert.Equal(t, tst.out, canonicalNumber(tst.in), "in:%s", tst.in)
+	}
+}
+
+func TestScoreMatch(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	release := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	future := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	ctx := WithLocalDuration(context.Background(), 7200)
+	tests := []struct {
+		name   string
+		number *model.Number
+		meta   *model.AvMeta
+		ctx    context.Context
+		score  int
+	}{
+		{name: "exact", number: &model.Number{NumberId: "ABC-123"}, meta: &model.AvMeta{Number: "ABC123", ReleaseDate: release, Duration: 7180}, ctx: ctx, score: 100},
+		{name: "leading_zero", number: &model.Number{NumberId: "ABC-1"}, meta: &model.AvMeta{Number: "ABC-001", ReleaseDate: release, Duration: 7200}, ctx: ctx, score: 90},
+		{name: "fc2", number: &model.Number{NumberId: "FC2-PPV-123456"}, meta: &model.AvMeta{Number: "FC2-123456", ReleaseDate: release}, ctx: ctx, score: 80},
+		{name: "mismatch", number: &model.Number{NumberId: "ABC-1"}, meta: &model.AvMeta{Number: "ABC-111", ReleaseDate: release, Duration: 7200}, ctx: ctx, score: 40},
+		{name: "bad_year_and_duration", number: &model.Number{NumberId: "ABC-1"}, meta: &model.AvMeta{Number: "ABC-001", ReleaseDate: future, Duration: 1200}, ctx: ctx, score: 50},
+		{name: "duration_near", number: &model.Number{NumberId: "ABC-1"}, meta: &model.AvMeta{Number: "ABC-1", ReleaseDate: release, Duration: 9000}, ctx: ctx, score: 90},
+		{name: "multi_cd", number: &model.Number{NumberId: "ABC-1", Episode: "1"}, meta: &model.AvMeta{Number: "ABC-1", ReleaseDate: release, Duration: 14400}, ctx: ctx, score: 90},
+		{name: "no_local_duration", number: &model.Number{NumberId: "ABC-1"}, meta: &model.AvMeta{Number: "ABC-1", ReleaseDate: release, Duration: 1200}, ctx: context.Background(), score: 90},
+	}
+	for _, tst := range tests {
+		assert.Equal(t, tst.score, scoreMatch(tst.ctx, tst.number, tst.meta, now), "name:%s", tst.name)
+	}
+}
+
+type matchTestPlugin struct {
+	httpTestPlugin
+	number string
+}
+
+func (p *matchTestPlugin) OnDecodeHTTPData(ctx context.Context, data []byte) (*model.AvMeta, bool, error) {
+	return &model.AvMeta{
+		Number:      p.number,
+		Title:       "title",
+		Cover:       &model.File{Name: "/cover.jpg"},
+		ReleaseDate: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli(),
+		Duration:    7200,
+	}, true, nil
+}
+
+func TestGroupSearchRejectLowMatch(t *testing.T) {
+	store.SetStorage(store.MustNewSqliteStorage(filepath.Join(t.TempDir(), "cache.db")))
+	ResetBackoff()
+	defer ResetBackoff()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	wrong := MustNewDefaultSearcher("wrong", &matchTestPlugin{httpTestPlugin: httpTestPlugin{base: srv.URL}, number: "ABC-111"})
+	right := MustNewDefaultSearcher("right", &matchTestPlugin{httpTestPlugin: httpTestPlugin{base: srv.URL}, number: "ABC-001"})
+	ctx, st := WithSearchTrace(WithLocalDuration(context.Background(), 7200))
+	meta, ok, err := NewGroup([]ISearcher{wrong, right}).Search(ctx, &model.Number{NumberId: "ABC-1"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "right", meta.ExtInfo.ScrapeInfo.Source)
+	attempts := st.Attempts()
+	assert.Equal(t, 2, len(attempts))
+	assert.False(t, attempts[0].Found)
+	assert.True(t, attempts[1].Found)
+
+	//关闭校验后使用第一个结果
+	wrong = MustNewDefaultSearcher("wrong", &matchTestPlugin{httpTestPlugin: httpTestPlugin{base: srv.URL}, number: "ABC-111"}, WithMatchThreshold(0))
+	meta, ok, err = wrong.Search(context.Background(), &model.Number{NumberId: "ABC-1"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, strings.EqualFold("ABC-111", meta.Number))
+}
+
+func TestDefaultSearcherSkipMatchForPinned(t *testing.T) {
+	store.SetStorage(store.MustNewSqliteStorage(filepath.Join(t.TempDir(), "cache.db")))
+	ResetBackoff()
+	defer ResetBackoff()
+	var imageHits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/cover.jpg" {
+			atomic.AddInt32(&imageHits, 1)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	s := MustNewDefaultSearcher("wrong", &matchTestPlugin{httpTestPlugin: httpTestPlugin{base: srv.URL}, number: "ABC-111"})
+	//匹配度过低时不下载图片
+	_, ok, err := s.Search(context.Background(), &model.Number{NumberId: "ABC-1"})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&imageHits))
+	//用户指定的详情页不做校验
+	ctx := WithDetailURLs(context.Background(), map[string]string{"wrong": srv.URL + "/detail"})
+	meta, ok, err := s.Search(ctx, &model.Number{NumberId: "ABC-1"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, strings.EqualFold("ABC-111", meta.Number))
+}
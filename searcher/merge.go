@@ -0,0 +1,269 @@
+package searcher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"yamdc/model"
+
+	"github.com/samber/lo"
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+const (
+	MergeFirst   = "first"   //按优先级取第一个非空值
+	MergeUnion   = "union"   //合并全部来源的值并去重, 仅适用于列表字段
+	MergeLongest = "longest" //取最长的值
+)
+
+// MergeRule 单个字段的合并规则
+type MergeRule struct {
+	Strategy string   //合并策略, 为空时使用字段的默认策略
+	Plugins  []string //插件优先级, 未出现在列表中的插件按照插件链的顺序排在后面
+}
+
+type mergeField struct {
+	strategy string //默认策略
+	empty    func(m *model.AvMeta) bool
+	assign   func(dst, src *model.AvMeta)
+	list     func(m *model.AvMeta) *[]string //列表字段, 用于union
+	size     func(m *model.AvMeta) int       //用于longest
+}
+
+func stringField(get func(m *model.AvMeta) *string) *mergeField {
+	return &mergeField{
+		strategy: MergeFirst,
+		empty:    func(m *model.AvMeta) bool { return len(*get(m)) == 0 },
+		assign:   func(dst, src *model.AvMeta) { *get(dst) = *get(src) },
+		size:     func(m *model.AvMeta) int { return utf8.RuneCountInString(*get(m)) },
+	}
+}
+
+func listField(get func(m *model.AvMeta) *[]string, strategy string) *mergeField {
+	return &mergeField{
+		strategy: strategy,
+		empty:    func(m *model.AvMeta) bool { return len(*get(m)) == 0 },
+		assign:   func(dst, src *model.AvMeta) { *get(dst) = *get(src) },
+		list:     get,
+		size:     func(m *model.AvMeta) int { return len(*get(m)) },
+	}
+}
+
+func int64Field(get func(m *model.AvMeta) *int64) *mergeField {
+	return &mergeField{
+		strategy: MergeFirst,
+		empty:    func(m *model.AvMeta) bool { return *get(m) == 0 },
+		assign:   func(dst, src *model.AvMeta) { *get(dst) = *get(src) },
+	}
+}
+
+func fileField(get func(m *model.AvMeta) **model.File) *mergeField {
+	return &mergeField{
+		strategy: MergeFirst,
+		empty:    func(m *model.AvMeta) bool { f := *get(m); return f == nil || len(f.Key) == 0 },
+		assign:   func(dst, src *model.AvMeta) { *get(dst) = *get(src) },
+	}
+}
+
+var mergeFields = map[string]*mergeField{
+	"number":       stringField(func(m *model.AvMeta) *string { return &m.Number }),
+	"title":        stringField(func(m *model.AvMeta) *string { return &m.Title }),
+	"plot":         withStrategy(stringField(func(m *model.AvMeta) *string { return &m.Plot }), MergeLongest),
+	"actors":       listField(func(m *model.AvMeta) *[]string { return &m.Actors }, MergeFirst),
+	"release_date": int64Field(func(m *model.AvMeta) *int64 { return &m.ReleaseDate }),
+	"duration":     int64Field(func(m *model.AvMeta) *int64 { return &m.Duration }),
+	"studio":       stringField(func(m *model.AvMeta) *string { return &m.Studio }),
+	"label":        stringField(func(m *model.AvMeta) *string { return &m.Label }),
+	"series":       stringField(func(m *model.AvMeta) *string { return &m.Series }),
+	"genres":       listField(func(m *model.AvMeta) *[]string { return &m.Genres }, MergeUnion),
+	"cover":        fileField(func(m *model.AvMeta) **model.File { return &m.Cover }),
+	"poster":       fileField(func(m *model.AvMeta) **model.File { return &m.Poster }),
+	"director":     stringField(func(m *model.AvMeta) *string { return &m.Director }),
+	"sample_images": {
+		strategy: MergeFirst,
+		empty:    func(m *model.AvMeta) bool { return len(m.SampleImages) == 0 },
+		assign:   func(dst, src *model.AvMeta) { dst.SampleImages = src.SampleImages },
+		size:     func(m *model.AvMeta) int { return len(m.SampleImages) },
+	},
+}
+
+func withStrategy(f *mergeField, strategy string) *mergeField {
+	f.strategy = strategy
+	return f
+}
+
+// MergeFields 返回全部支持合并的字段名
+func MergeFields() []string {
+	return lo.Keys(mergeFields)
+}
+
+// ValidateMergeRules 检查合并规则的字段及策略是否有效
+func ValidateMergeRules(rules map[string]MergeRule) error {
+	for name, rule := range rules {
+		f, ok := mergeFields[name]
+		if !ok {
+			return fmt.Errorf("unknown merge field:%s", name)
+		}
+		switch rule.Strategy {
+		case "", MergeFirst:
+		case MergeUnion:
+			if f.list == nil {
+				return fmt.Errorf("merge field:%s not support strategy:%s", name, rule.Strategy)
+			}
+		case MergeLongest:
+			if f.size == nil {
+				return fmt.Errorf("merge field:%s not support strategy:%s", name, rule.Strategy)
+			}
+		default:
+			return fmt.Errorf("invalid merge strategy:%s for field:%s", rule.Strategy, name)
+		}
+	}
+	return nil
+}
+
+type mergeSource struct {
+	idx  int
+	name string
+	meta *model.AvMeta
+}
+
+// sortSources 按照字段的插件优先级对来源进行排序
+func sortSources(srcs []*mergeSource, plugins []string) []*mergeSource {
+	rs := make([]*mergeSource, len(srcs))
+	copy(rs, srcs)
+	prio := make(map[string]int, len(plugins))
+	for i, p := range plugins {
+		prio[strings.ToLower(p)] = i
+	}
+	rank := func(s *mergeSource) int {
+		if p, ok := prio[strings.ToLower(s.name)]; ok {
+			return p
+		}
+		return len(plugins) + s.idx
+	}
+	sort.SliceStable(rs, func(i, j int) bool {
+		return rank(rs[i]) < rank(rs[j])
+	})
+	return rs
+}
+
+// mergeMeta 按字段合并多个来源的元数据, 并记录每个字段的来源
+func mergeMeta(srcs []*mergeSource, rules map[string]MergeRule) *model.AvMeta {
+	rs := &model.AvMeta{}
+	sources := make(map[string]string, len(mergeFields))
+	for name, f := range mergeFields {
+		rule := rules[name]
+		strategy := rule.Strategy
+		if len(strategy) == 0 {
+			strategy = f.strategy
+		}
+		ordered := sortSources(srcs, rule.Plugins)
+		switch strategy {
+		case MergeUnion:
+			names := make([]string, 0, len(ordered))
+			for _, src := range ordered {
+				if f.empty(src.meta) {
+					continue
+				}
+				*f.list(rs) = lo.Uniq(append(*f.list(rs), *f.list(src.meta)...))
+				names = append(names, src.name)
+			}
+			if len(names) > 0 {
+				sources[name] = strings.Join(names, ",")
+			}
+		case MergeLongest:
+			var best *mergeSource
+			for _, src := range ordered {
+				if f.empty(src.meta) {
+					continue
+				}
+				if best == nil || f.size(src.meta) > f.size(best.meta) {
+					best = src
+				}
+			}
+			if best != nil {
+				f.assign(rs, best.meta)
+				sources[name] = best.name
+			}
+		default:
+			for _, src := range ordered {
+				if f.empty(src.meta) {
+					continue
+				}
+				f.assign(rs, src.meta)
+				sources[name] = src.name
+				break
+			}
+		}
+	}
+	rs.ExtInfo.ScrapeInfo.Source = sources["title"]
+	if len(rs.ExtInfo.ScrapeInfo.Source) == 0 && len(srcs) > 0 {
+		rs.ExtInfo.ScrapeInfo.Source = srcs[0].name
+	}
+	rs.ExtInfo.ScrapeInfo.DateTs = time.Now().UnixMilli()
+	rs.ExtInfo.ScrapeInfo.FieldSources = sources
+	return rs
+}
+
+// performMergeSearch 查询插件链中的多个插件, 并按字段合并结果
+func performMergeSearch(ctx context.Context, number *model.Number, ss []ISearcher, c *config) (*model.AvMeta, bool, error) {
+	srcs, lastErr := collectMergeSources(ctx, number, ss, c)
+	if len(srcs) == 0 {
+		return nil, false, lastErr
+	}
+	meta := mergeMeta(srcs, c.MergeRules)
+	logutil.GetLogger(ctx).Debug("merge search result", zap.Int("sources", len(srcs)), zap.Any("field_sources", meta.ExtInfo.ScrapeInfo.FieldSources))
+	return meta, true, nil
+}
+
+func collectMergeSources(ctx context.Context, number *model.Number, ss []ISearcher, c *config) ([]*mergeSource, error) {
+	batch := c.Parallel
+	if batch <= 1 {
+		batch = 1
+	}
+	srcs := make([]*mergeSource, 0, len(ss))
+	var lastErr error
+	for start := 0; start < len(ss); start += batch {
+		end := start + batch
+		if end > len(ss) {
+			end = len(ss)
+		}
+		ch := make(chan *searchResult, end-start)
+		for i := start; i < end; i++ {
+			go func(idx int, s ISearcher) {
+				ch <- searchOne(ctx, number, idx, s, c.pluginTimeout())
+			}(i, ss[i])
+		}
+		results := make([]*searchResult, 0, end-start)
+		for i := start; i < end; i++ {
+			rs := <-ch
+			recordSearchAttempt(ctx, rs.name, rs.found, rs.err)
+			results = append(results, rs)
+		}
+		sort.Slice(results, func(i, j int) bool {
+			return results[i].idx < results[j].idx
+		})
+		for _, rs := range results {
+			if rs.err != nil {
+				lastErr = rs.err
+				continue
+			}
+			if !rs.found {
+				continue
+			}
+			srcs = append(srcs, &mergeSource{idx: rs.idx, name: rs.name, meta: rs.meta})
+		}
+		if ctx.Err() != nil {
+			return srcs, ctx.Err()
+		}
+		if c.MergeMaxSources > 0 && len(srcs) >= c.MergeMaxSources {
+			srcs = srcs[:c.MergeMaxSources]
+			break
+		}
+	}
+	return srcs, lastErr
+}
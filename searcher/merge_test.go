@@ -0,0 +1,78 @@
+package searcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"yamdc/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeMeta(t *testing.T) {
+	srcs := []*mergeSource{
+		{idx: 0, name: "javbus", meta: &model.AvMeta{
+			Number: "ABC-123", Title: "bus title", Plot: "short", Actors: []string{"a"}, Genres: []string{"g1", "g2"},
+			Cover: &model.File{Name: "c", Key: "bus-cover"},
+		}},
+		{idx: 1, name: "javdb", meta: &model.AvMeta{
+			Number: "ABC-123", Title: "db title", Plot: "a much longer plot", Actors: []string{"b"}, Genres: []string{"g2", "g3"},
+			Studio: "studio", Cover: &model.File{Name: "c"},
+		}},
+		{idx: 2, name: "fc2ppvdb", meta: &model.AvMeta{Actors: []string{"c", "d"}}},
+	}
+	meta := mergeMeta(srcs, map[string]MergeRule{
+		"actors": {Plugins: []string{"fc2ppvdb"}},
+		"title":  {Plugins: []string{"javdb"}},
+	})
+	assert.Equal(t, "ABC-123", meta.Number)
+	assert.Equal(t, "db title", meta.Title)
+	assert.Equal(t, "a much longer plot", meta.Plot)
+	assert.Equal(t, []string{"c", "d"}, meta.Actors)
+	assert.Equal(t, []string{"g1", "g2", "g3"}, meta.Genres)
+	assert.Equal(t, "studio", meta.Studio)
+	//javdb的封面没有下载成功, 使用javbus的封面
+	assert.Equal(t, "bus-cover", meta.Cover.Key)
+	info := meta.ExtInfo.ScrapeInfo
+	assert.Equal(t, "javdb", info.Source)
+	assert.Equal(t, "javdb", info.FieldSources["plot"])
+	assert.Equal(t, "fc2ppvdb", info.FieldSources["actors"])
+	assert.Equal(t, "javbus,javdb", info.FieldSources["genres"])
+	assert.Equal(t, "javbus", info.FieldSources["cover"])
+	_, ok := info.FieldSources["series"]
+	assert.False(t, ok)
+}
+
+func TestValidateMergeRules(t *testing.T) {
+	assert.NoError(t, ValidateMergeRules(map[string]MergeRule{"actors": {Strategy: MergeUnion}, "plot": {Strategy: MergeLongest}}))
+	assert.Error(t, ValidateMergeRules(map[string]MergeRule{"unknown": {}}))
+	assert.Error(t, ValidateMergeRules(map[string]MergeRule{"title": {Strategy: MergeUnion}}))
+	assert.Error(t, ValidateMergeRules(map[string]MergeRule{"cover": {Strategy: MergeLongest}}))
+	assert.Error(t, ValidateMergeRules(map[string]MergeRule{"title": {Strategy: "xx"}}))
+}
+
+func TestMergeSearch(t *testing.T) {
+	ss := []ISearcher{
+		&fakeSearcher{name: "p1", meta: &model.AvMeta{Number: "ABC-123", Title: "t1"}},
+		&fakeSearcher{name: "p2", err: errors.New("broken")},
+		&fakeSearcher{name: "p3", meta: &model.AvMeta{Number: "ABC-123", Title: "t3", Plot: "plot"}},
+		&fakeSearcher{name: "p4", meta: &model.AvMeta{Number: "ABC-123", Director: "d"}},
+	}
+	ctx, st := WithSearchTrace(context.Background())
+	s := NewGroup(ss, WithMerge(2, nil))
+	meta, ok, err := s.Search(ctx, &model.Number{NumberId: "ABC-123"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "t1", meta.Title)
+	assert.Equal(t, "plot", meta.Plot)
+	//达到最大来源数后不再查询后续插件
+	assert.Equal(t, "", meta.Director)
+	assert.Equal(t, 3, len(st.Attempts()))
+
+	s = NewGroup(ss, WithMerge(0, nil), WithParallel(4))
+	meta, ok, err = s.Search(context.Background(), &model.Number{NumberId: "ABC-123"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "d", meta.Director)
+	assert.Equal(t, "p4", meta.ExtInfo.ScrapeInfo.FieldSources["director"])
+}
@@ -0,0 +1,177 @@
+package searcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"yamdc/model"
+
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+// 结果得分的组成, 总分为100
+const (
+	scoreNumberExact   = 40
+	scoreNumberLoose   = 20 //忽略大小写及分隔符后匹配
+	scoreCompleteness  = 30
+	scoreCover         = 10
+	scorePoster        = 5
+	scoreSampleImages  = 5
+	scorePriorityRange = 10
+)
+
+type searchResult struct {
+	idx   int
+	name  string
+	meta  *model.AvMeta
+	found bool
+	err   error
+	score int
+}
+
+func normalizeNumber(n string) string {
+	n = strings.ToUpper(n)
+	return strings.NewReplacer("-", "", "_", "", " ", "").Replace(n)
+}
+
+// scoreMeta 计算搜索结果的得分, 包括番号匹配程度, 字段完整度, 图片可用性以及插件优先级
+func scoreMeta(number *model.Number, meta *model.AvMeta, idx int, total int) int {
+	score := 0
+	switch {
+	case strings.EqualFold(meta.Number, number.GetNumberID()):
+		score += scoreNumberExact
+	case normalizeNumber(meta.Number) == normalizeNumber(number.GetNumberID()):
+		score += scoreNumberLoose
+	}
+	fields := []bool{
+		len(meta.Title) > 0,
+		len(meta.Plot) > 0,
+		len(meta.Actors) > 0,
+		meta.ReleaseDate > 0,
+		meta.Duration > 0,
+		len(meta.Studio) > 0,
+		len(meta.Label) > 0,
+		len(meta.Series) > 0,
+		len(meta.Genres) > 0,
+		len(meta.Director) > 0,
+	}
+	filled := 0
+	for _, ok := range fields {
+		if ok {
+			filled++
+		}
+	}
+	score += scoreCompleteness * filled / len(fields)
+	if meta.Cover != nil && len(meta.Cover.Key) > 0 {
+		score += scoreCover
+	}
+	if meta.Poster != nil && len(meta.Poster.Key) > 0 {
+		score += scorePoster
+	}
+	if len(meta.SampleImages) > 0 {
+		score += scoreSampleImages
+	}
+	if total > 0 {
+		score += scorePriorityRange * (total - idx) / total
+	}
+	return score
+}
+
+// performParallelSearch 按优先级每次同时查询c.Parallel个插件, 本批次存在结果时选择得分最高的结果,
+// 否则继续查询下一批插件; 得分达到c.AcceptScore时直接返回并取消本批次中其他插件的查询
+func performParallelSearch(ctx context.Context, number *model.Number, ss []ISearcher, c *config) (*model.AvMeta, bool, error) {
+	var lastErr error
+	for start := 0; start < len(ss); start += c.Parallel {
+		end := start + c.Parallel
+		if end > len(ss) {
+			end = len(ss)
+		}
+		best, err := searchBatch(ctx, number, ss, start, end, c)
+		if err != nil {
+			lastErr = err
+		}
+		if best != nil {
+			logutil.GetLogger(ctx).Debug("select search result", zap.String("plugin", best.name), zap.Int("score", best.score))
+			return best.meta, true, nil
+		}
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+	}
+	if lastErr != nil {
+		return nil, false, lastErr
+	}
+	return nil, false, nil
+}
+
+func searchBatch(ctx context.Context, number *model.Number, ss []ISearcher, start, end int, c *config) (*searchResult, error) {
+	bctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ch := make(chan *searchResult, end-start)
+	for i := start; i < end; i++ {
+		go func(idx int, s ISearcher) {
+			ch <- searchOne(bctx, number, idx, s, c.PluginTimeout)
+		}(i, ss[i])
+	}
+	var best *searchResult
+	var lastErr error
+	for i := start; i < end; i++ {
+		var rs *searchResult
+		select {
+		case rs = <-ch:
+		case <-ctx.Done():
+			return best, ctx.Err()
+		}
+		recordSearchAttempt(ctx, rs.name, rs.found, rs.err)
+		if rs.err != nil {
+			lastErr = rs.err
+			continue
+		}
+		if !rs.found {
+			continue
+		}
+		rs.score = scoreMeta(number, rs.meta, rs.idx, len(ss))
+		logutil.GetLogger(ctx).Debug("search result scored", zap.String("plugin", rs.name), zap.Int("score", rs.score))
+		if best == nil || rs.score > best.score || (rs.score == best.score && rs.idx < best.idx) {
+			best = rs
+		}
+		if best.score >= c.AcceptScore {
+			break
+		}
+	}
+	return best, lastErr
+}
+
+// searchOne 在超时时间内执行单个插件的搜索, 插件未响应取消时直接放弃等待
+func searchOne(ctx context.Context, number *model.Number, idx int, s ISearcher, timeout time.Duration) *searchResult {
+	rs := &searchResult{idx: idx, name: s.Name()}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		meta, found, err := s.Search(ctx, number)
+		if ctx.Err() != nil {
+			return
+		}
+		rs.meta, rs.found, rs.err = meta, found, err
+	}()
+	select {
+	case <-done:
+		if ctx.Err() == nil {
+			return rs
+		}
+	case <-ctx.Done():
+	}
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("plugin search timeout after %s", timeout)
+	}
+	return &searchResult{idx: idx, name: s.Name(), err: err}
+}
@@ -0,0 +1,99 @@
+package searcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"yamdc/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSearcher struct {
+	name  string
+	delay time.Duration
+	meta  *model.AvMeta
+	err   error
+}
+
+func (s *fakeSearcher) Name() string {
+	return s.name
+}
+
+func (s *fakeSearcher) Search(ctx context.Context, number *model.Number) (*model.AvMeta, bool, error) {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+	if s.err != nil {
+		return nil, false, s.err
+	}
+	if s.meta == nil {
+		return nil, false, nil
+	}
+	return s.meta, true, nil
+}
+
+func fullMeta(number string, title string) *model.AvMeta {
+	return &model.AvMeta{
+		Number: number, Title: title, Plot: "plot", Actors: []string{"a"}, ReleaseDate: 1, Duration: 1,
+		Studio: "s", Label: "l", Series: "s", Genres: []string{"g"}, Director: "d",
+		Cover: &model.File{Name: "c", Key: "c"}, Poster: &model.File{Name: "p", Key: "p"},
+		SampleImages: []*model.File{{Name: "x", Key: "x"}},
+	}
+}
+
+func TestScoreMeta(t *testing.T) {
+	n := &model.Number{NumberId: "ABC-123"}
+	assert.Equal(t, 100, scoreMeta(n, fullMeta("ABC-123", "t"), 0, 2))
+	assert.Equal(t, 95, scoreMeta(n, fullMeta("ABC-123", "t"), 1, 2))
+	assert.Equal(t, 80, scoreMeta(n, fullMeta("ABC123", "t"), 0, 2))
+	assert.Equal(t, 10+3, scoreMeta(n, &model.AvMeta{Number: "XYZ-999", Title: "t"}, 0, 1))
+}
+
+func TestParallelSearchSelectBest(t *testing.T) {
+	ss := []ISearcher{
+		&fakeSearcher{name: "poor", delay: 10 * time.Millisecond, meta: &model.AvMeta{Number: "ABC-123", Title: "poor"}},
+		&fakeSearcher{name: "good", delay: 30 * time.Millisecond, meta: fullMeta("ABC-123", "good")},
+		&fakeSearcher{name: "broken", err: errors.New("broken")},
+	}
+	ctx, st := WithSearchTrace(context.Background())
+	s := NewGroup(ss, WithParallel(3), WithAcceptScore(101))
+	meta, ok, err := s.Search(ctx, &model.Number{NumberId: "ABC-123"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "good", meta.Title)
+	assert.Equal(t, 3, len(st.Attempts()))
+}
+
+func TestParallelSearchTimeoutAndCancel(t *testing.T) {
+	ss := []ISearcher{
+		&fakeSearcher{name: "hang", delay: time.Hour, meta: fullMeta("ABC-123", "hang")},
+		&fakeSearcher{name: "fast", delay: 10 * time.Millisecond, meta: fullMeta("ABC-123", "fast")},
+	}
+	start := time.Now()
+	s := NewGroup(ss, WithParallel(2), WithPluginTimeout(time.Second))
+	meta, ok, err := s.Search(context.Background(), &model.Number{NumberId: "ABC-123"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "fast", meta.Title)
+	//得分达到阈值后不再等待挂起的插件
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	ss = []ISearcher{
+		&fakeSearcher{name: "hang", delay: time.Hour},
+		&fakeSearcher{name: "notfound", delay: time.Millisecond},
+		&fakeSearcher{name: "next", delay: time.Millisecond, meta: &model.AvMeta{Number: "ABC-123", Title: "next"}},
+	}
+	ctx, st := WithSearchTrace(context.Background())
+	s = NewGroup(ss, WithParallel(2), WithPluginTimeout(50*time.Millisecond))
+	meta, ok, err = s.Search(ctx, &model.Number{NumberId: "ABC-123"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "next", meta.Title)
+	attempts := st.Attempts()
+	assert.Equal(t, 3, len(attempts))
+	assert.Contains(t, attempts[1].Error, "timeout")
+}
@@ -8,6 +8,8 @@ import (
 
 type HTTPInvoker func(ctx context.Context, req *http.Request) (*http.Response, error)
 
+// IPlugin 站点插件, 各个回调可以返回(或者包装)ErrNotFound, ErrBlocked, ErrRateLimited, ErrDecode等错误,
+// 搜索器会据此决定是否缓存未找到的结果, 暂停使用插件或者在报告中标记插件可能已经失效
 type IPlugin interface {
 	OnHTTPClientInit() HTTPInvoker
 	OnPrecheckRequest(ctx context.Context, number *model.Number) (bool, error)
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"yamdc/client"
+	"yamdc/model"
+	"yamdc/searcher/plugin/domainpool"
+)
+
+// PluginConfig plugin_config中单个插件的配置
+type PluginConfig struct {
+	Domains             []string          `json:"domains"`               //可选的域名/镜像列表, 覆盖插件内置的域名, 每次请求随机选择一个
+	Headers             map[string]string `json:"headers"`               //额外的请求头, 会覆盖插件内置的同名请求头
+	Cookies             map[string]string `json:"cookies"`               //额外的cookie
+	Language            string            `json:"language"`              //站点的语言参数, 仅部分插件支持, 例如airav的lng
+	Timeout             int64             `json:"timeout"`               //单次请求的超时时间, 单位为秒, 0为使用全局配置, 不能超过全局的超时时间
+	DisableSampleImages bool              `json:"disable_sample_images"` //不抓取样品图
+}
+
+// IConfigValidator 插件可以通过实现该接口对配置做额外的校验
+type IConfigValidator interface {
+	OnValidateConfig(c *PluginConfig) error
+}
+
+type pluginConfigKeyType struct{}
+
+var defaultPluginConfigKey = pluginConfigKeyType{}
+
+// DecodePluginConfig 将plugin_config中的配置解析为PluginConfig, 未知的字段会直接报错
+func DecodePluginConfig(args interface{}) (*PluginConfig, error) {
+	c := &PluginConfig{}
+	if args == nil {
+		return c, nil
+	}
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("encode plugin args failed, err:%w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("decode plugin args failed, err:%w", err)
+	}
+	if err := c.verify(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *PluginConfig) verify() error {
+	for _, domain := range c.Domains {
+		if len(domain) == 0 || strings.ContainsAny(domain, "/?# ") {
+			return fmt.Errorf("invalid domain:`%s`, should be host or host:port", domain)
+		}
+	}
+	for k := range c.Headers {
+		if len(strings.TrimSpace(k)) == 0 {
+			return fmt.Errorf("empty header name")
+		}
+	}
+	for k := range c.Cookies {
+		if len(strings.TrimSpace(k)) == 0 {
+			return fmt.Errorf("empty cookie name")
+		}
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("invalid timeout:%d", c.Timeout)
+	}
+	return nil
+}
+
+// GetPluginConfig 获取当前插件的配置, 未配置时返回空配置
+func GetPluginConfig(ctx context.Context) *PluginConfig {
+	if c, ok := ctx.Value(defaultPluginConfigKey).(*PluginConfig); ok {
+		return c
+	}
+	return &PluginConfig{}
+}
+
+// SelectPluginDomain 优先使用配置的域名, 未配置时使用插件内置的域名, 并从中选择当前最健康的一个
+func SelectPluginDomain(ctx context.Context, defaults []string) string {
+	domains := defaults
+	if configured := GetPluginConfig(ctx).Domains; len(configured) > 0 {
+		domains = configured
+	}
+	return domainpool.Default().Select(ctx, domains)
+}
+
+// PluginLanguage 获取配置的语言参数, 未配置时使用插件的默认值
+func PluginLanguage(ctx context.Context, def string) string {
+	if lng := GetPluginConfig(ctx).Language; len(lng) > 0 {
+		return lng
+	}
+	return def
+}
+
+// WithPluginConfig 使用配置包装插件, 插件的所有回调都可以通过GetPluginConfig拿到该配置
+func WithPluginConfig(plg IPlugin, c *PluginConfig) (IPlugin, error) {
+	if v, ok := plg.(IConfigValidator); ok {
+		if err := v.OnValidateConfig(c); err != nil {
+			return nil, fmt.Errorf("validate plugin config failed, err:%w", err)
+		}
+	}
+	return &configuredPlugin{impl: plg, c: c}, nil
+}
+
+type configuredPlugin struct {
+	impl IPlugin
+	c    *PluginConfig
+}
+
+func (p *configuredPlugin) withConfig(ctx context.Context) context.Context {
+	return context.WithValue(ctx, defaultPluginConfigKey, p.c)
+}
+
+func (p *configuredPlugin) OnHTTPClientInit() HTTPInvoker {
+	invoker := p.impl.OnHTTPClientInit()
+	if invoker == nil {
+		invoker = func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			return client.DefaultClient().Do(req)
+		}
+	}
+	if p.c.Timeout > 0 {
+		invoker = withTimeout(invoker, time.Duration(p.c.Timeout)*time.Second)
+	}
+	//超时在每次尝试时单独计算, 因此域名池需要包在最外层
+	return domainpool.Default().WrapInvoker(invoker)
+}
+
+func withTimeout(invoker HTTPInvoker, timeout time.Duration) HTTPInvoker {
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		rsp, err := invoker(ctx, req.WithContext(ctx))
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		//body读取完成前不能取消ctx, 这里在body关闭时再释放
+		rsp.Body = &cancelOnClose{ReadCloser: rsp.Body, cancel: cancel}
+		return rsp, nil
+	}
+}
+
+func (p *configuredPlugin) OnPrecheckRequest(ctx context.Context, number *model.Number) (bool, error) {
+	return p.impl.OnPrecheckRequest(p.withConfig(ctx), number)
+}
+
+func (p *configuredPlugin) OnMakeHTTPRequest(ctx context.Context, number *model.Number) (*http.Request, error) {
+	return p.impl.OnMakeHTTPRequest(p.withConfig(ctx), number)
+}
+
+func (p *configuredPlugin) OnDecorateRequest(ctx context.Context, req *http.Request) error {
+	if err := p.impl.OnDecorateRequest(p.withConfig(ctx), req); err != nil {
+		return err
+	}
+	for k, v := range p.c.Headers {
+		req.Header.Set(k, v)
+	}
+	setCookies(req, p.c.Cookies)
+	return nil
+}
+
+func (p *configuredPlugin) OnHandleHTTPRequest(ctx context.Context, invoker HTTPInvoker, req *http.Request) (*http.Response, error) {
+	return p.impl.OnHandleHTTPRequest(p.withConfig(ctx), invoker, req)
+}
+
+func (p *configuredPlugin) OnPrecheckResponse(ctx context.Context, req *http.Request, rsp *http.Response) (bool, error) {
+	return p.impl.OnPrecheckResponse(p.withConfig(ctx), req, rsp)
+}
+
+func (p *configuredPlugin) OnDecodeHTTPData(ctx context.Context, data []byte) (*model.AvMeta, bool, error) {
+	meta, ok, err := p.impl.OnDecodeHTTPData(p.withConfig(ctx), data)
+	if err != nil || !ok {
+		return meta, ok, err
+	}
+	if p.c.DisableSampleImages {
+		meta.SampleImages = nil
+	}
+	return meta, ok, nil
+}
+
+func (p *configuredPlugin) OnDecorateMediaRequest(ctx context.Context, req *http.Request) error {
+	if err := p.impl.OnDecorateMediaRequest(p.withConfig(ctx), req); err != nil {
+		return err
+	}
+	setCookies(req, p.c.Cookies)
+	return nil
+}
+
+// setCookies 设置cookie, 插件已经设置的同名cookie会被覆盖
+func setCookies(req *http.Request, cookies map[string]string) {
+	if len(cookies) == 0 {
+		return
+	}
+	exists := req.Cookies()
+	req.Header.Del("Cookie")
+	for _, item := range exists {
+		if _, ok := cookies[item.Name]; ok {
+			continue
+		}
+		req.AddCookie(item)
+	}
+	for k, v := range cookies {
+		req.AddCookie(&http.Cookie{Name: k, Value: v})
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"yamdc/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testPlugin struct {
+	DefaultPlugin
+	domain string
+}
+
+func (p *testPlugin) OnMakeHTTPRequest(ctx context.Context, number *model.Number) (*http.Request, error) {
+	return http.NewRequest(http.MethodGet, "http://"+SelectPluginDomain(ctx, []string{p.domain})+"/"+number.GetNumberID(), nil)
+}
+
+func (p *testPlugin) OnDecorateRequest(ctx context.Context, req *http.Request) error {
+	req.AddCookie(&http.Cookie{Name: "age", Value: "0"})
+	req.AddCookie(&http.Cookie{Name: "keep", Value: "1"})
+	req.Header.Set("X-Plugin", "plugin")
+	return nil
+}
+
+func (p *testPlugin) OnDecodeHTTPData(ctx context.Context, data []byte) (*model.AvMeta, bool, error) {
+	return &model.AvMeta{
+		Title:        GetPluginConfig(ctx).Language,
+		SampleImages: []*model.File{{Name: "a.jpg"}},
+	}, true, nil
+}
+
+func TestDecodePluginConfig(t *testing.T) {
+	c, err := DecodePluginConfig(map[string]interface{}{
+		"domains": []interface{}{"a.com", "b.com:8080"},
+		"timeout": 5,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.com", "b.com:8080"}, c.Domains)
+	assert.Equal(t, int64(5), c.Timeout)
+
+	c, err = DecodePluginConfig(struct{}{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(c.Domains))
+
+	invalids := []interface{}{
+		map[string]interface{}{"unknown": 1},
+		map[string]interface{}{"domains": []interface{}{"https://a.com"}},
+		map[string]interface{}{"timeout": -1},
+		map[string]interface{}{"headers": map[string]interface{}{" ": "1"}},
+		"abc",
+	}
+	for _, args := range invalids {
+		_, err := DecodePluginConfig(args)
+		assert.Error(t, err, "args:%v", args)
+	}
+}
+
+func TestWithPluginConfig(t *testing.T) {
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	srvHost := srv.Listener.Addr().String()
+
+	//默认插件不支持language
+	_, err := WithPluginConfig(&testPlugin{}, &PluginConfig{Language: "en"})
+	assert.Error(t, err)
+
+	plg, err := WithPluginConfig(&testPlugin{domain: "unused.example"}, &PluginConfig{
+		Domains:             []string{srvHost},
+		Headers:             map[string]string{"X-Plugin": "config", "X-Extra": "1"},
+		Cookies:             map[string]string{"age": "verified"},
+		Timeout:             5,
+		DisableSampleImages: true,
+	})
+	assert.NoError(t, err)
+	ctx := context.Background()
+	req, err := plg.OnMakeHTTPRequest(ctx, &model.Number{})
+	assert.NoError(t, err)
+	assert.Equal(t, srvHost, req.URL.Host)
+	assert.NoError(t, plg.OnDecorateRequest(ctx, req))
+	rsp, err := plg.OnHTTPClientInit()(ctx, req)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(rsp.Body)
+	assert.NoError(t, err)
+	assert.NoError(t, rsp.Body.Close())
+	assert.Equal(t, "ok", string(data))
+	assert.Equal(t, "config", header.Get("X-Plugin"))
+	assert.Equal(t, "1", header.Get("X-Extra"))
+	cookieReq := &http.Request{Header: header}
+	age, err := cookieReq.Cookie("age")
+	assert.NoError(t, err)
+	assert.Equal(t, "verified", age.Value)
+	assert.Equal(t, 2, len(cookieReq.Cookies())) //keep + age
+	meta, ok, err := plg.OnDecodeHTTPData(ctx, nil)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 0, len(meta.SampleImages))
+}
+
+func TestPluginConfigTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(3 * time.Second):
+		}
+	}))
+	defer srv.Close()
+	plg, err := WithPluginConfig(&testPlugin{}, &PluginConfig{Timeout: 1})
+	assert.NoError(t, err)
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	assert.NoError(t, err)
+	start := time.Now()
+	_, err = plg.OnHTTPClientInit()(context.Background(), req)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestSelectPluginDomain(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "a.com", SelectPluginDomain(ctx, []string{"a.com"}))
+	ctx = context.WithValue(ctx, defaultPluginConfigKey, &PluginConfig{Domains: []string{"b.com"}, Language: "en"})
+	assert.Equal(t, "b.com", SelectPluginDomain(ctx, []string{"a.com"}))
+	assert.Equal(t, "en", PluginLanguage(ctx, "zh-TW"))
+	assert.Equal(t, "zh-TW", PluginLanguage(context.Background(), "zh-TW"))
+}
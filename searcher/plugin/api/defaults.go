@@ -26,6 +26,7 @@ func (p *DefaultPlugin) OnDecorateRequest(ctx context.Context, req *http.Request
 	return nil
 }
 
+// OnPrecheckResponse 返回false时视为未找到, 需要区分被拦截或者限流等情况时, 插件可以返回api.NewStatusError构建的错误
 func (p *DefaultPlugin) OnPrecheckResponse(ctx context.Context, req *http.Request, rsp *http.Response) (bool, error) {
 	if rsp.StatusCode == http.StatusNotFound {
 		return false, nil
@@ -44,3 +45,10 @@ func (p *DefaultPlugin) OnDecodeHTTPData(ctx context.Context, data []byte) (*mod
 func (p *DefaultPlugin) OnDecorateMediaRequest(ctx context.Context, req *http.Request) error {
 	return nil
 }
+
+func (p *DefaultPlugin) OnValidateConfig(c *PluginConfig) error {
+	if len(c.Language) > 0 {
+		return fmt.Errorf("language not supported")
+	}
+	return nil
+}
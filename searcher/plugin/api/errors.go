@@ -0,0 +1,143 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 插件在各个回调中可以返回(或者包装)以下错误, 搜索器会根据错误类型决定后续的处理方式
+var (
+	ErrNotFound    = errors.New("not found")       //站点上不存在该番号, 结果会被缓存一段时间
+	ErrBlocked     = errors.New("blocked")         //请求被拦截(403, cloudflare验证等), 插件会暂停使用一段时间
+	ErrRateLimited = errors.New("rate limited")    //请求过于频繁(429), 插件会暂停使用一段时间
+	ErrNetwork     = errors.New("network failure") //连接失败, 超时等
+	ErrDecode      = errors.New("decode failure")  //页面解析失败, 通常意味着站点改版, 插件需要更新
+)
+
+const (
+	ErrKindNotFound    = "not_found"
+	ErrKindBlocked     = "blocked"
+	ErrKindRateLimited = "rate_limited"
+	ErrKindNetwork     = "network"
+	ErrKindDecode      = "decode"
+	ErrKindUnknown     = "unknown"
+)
+
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.kind.Error() + ", err:" + e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// WrapError 为错误附加类型, 已经带有类型的错误保持不变
+func WrapError(kind error, err error) error {
+	if err == nil {
+		return nil
+	}
+	if HasErrorKind(err) {
+		return err
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// HasErrorKind 判断错误是否已经带有类型
+func HasErrorKind(err error) bool {
+	return ErrorKind(err) != ErrKindUnknown
+}
+
+// ErrorKind 返回错误的类型名, 用于日志及报告
+func ErrorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return ErrKindNotFound
+	case errors.Is(err, ErrBlocked):
+		return ErrKindBlocked
+	case errors.Is(err, ErrRateLimited):
+		return ErrKindRateLimited
+	case errors.Is(err, ErrNetwork):
+		return ErrKindNetwork
+	case errors.Is(err, ErrDecode):
+		return ErrKindDecode
+	}
+	return ErrKindUnknown
+}
+
+func isCloudflareChallenge(rsp *http.Response) bool {
+	if len(rsp.Header.Get("cf-mitigated")) > 0 {
+		return true
+	}
+	return strings.EqualFold(rsp.Header.Get("Server"), "cloudflare") &&
+		(rsp.StatusCode == http.StatusForbidden || rsp.StatusCode == http.StatusServiceUnavailable)
+}
+
+// StatusError 非200的http响应
+type StatusError struct {
+	Code       int
+	RetryAfter time.Duration //响应中Retry-After指定的等待时间, 0为未指定
+}
+
+func (e *StatusError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("invalid http status code:%d, retry after:%s", e.Code, e.RetryAfter)
+	}
+	return fmt.Sprintf("invalid http status code:%d", e.Code)
+}
+
+// ParseRetryAfter 解析Retry-After, 支持秒数及http时间两种格式
+func ParseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if len(v) == 0 {
+		return 0, false
+	}
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if sec < 0 {
+			return 0, false
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// RetryAfter 获取错误中携带的Retry-After
+func RetryAfter(err error) (time.Duration, bool) {
+	var se *StatusError
+	if !errors.As(err, &se) || se.RetryAfter <= 0 {
+		return 0, false
+	}
+	return se.RetryAfter, true
+}
+
+// NewStatusError 根据http响应构建带类型的错误
+func NewStatusError(rsp *http.Response) error {
+	err := &StatusError{Code: rsp.StatusCode}
+	if d, ok := ParseRetryAfter(rsp.Header.Get("Retry-After"), time.Now()); ok {
+		err.RetryAfter = d
+	}
+	switch {
+	case rsp.StatusCode == http.StatusNotFound:
+		return WrapError(ErrNotFound, err)
+	case rsp.StatusCode == http.StatusTooManyRequests:
+		return WrapError(ErrRateLimited, err)
+	case rsp.StatusCode == http.StatusForbidden || isCloudflareChallenge(rsp):
+		return WrapError(ErrBlocked, err)
+	}
+	return err
+}
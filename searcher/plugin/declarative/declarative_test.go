@@ -0,0 +1,148 @@
+package declarative
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"yamdc/model"
+	"yamdc/searcher/plugin/factory"
+	"yamdc/searcher/plugin/meta"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSearchPage = `<html><body>
+<div class="item"><a href="/v/1">XYZ-999 other</a></div>
+<div class="item"><a href="/v/2">abc123 target</a></div>
+</body></html>`
+
+const testDetailPage = `<html><body>
+<h1 class="title">[ABC-123]  Title Here </h1>
+<span class="number">ABC-123</span>
+<span class="date">2024/01/02</span>
+<span class="duration">01:02:00</span>
+<a class="actor">A</a><a class="actor"> </a><a class="actor">B</a>
+<img class="cover" src="/cover.jpg"/>
+</body></html>`
+
+const testSpec = `
+name: test_site
+domains: ["%s"]
+request:
+  url: "http://{domain}/search?q={clean_number}"
+  headers:
+    X-Test: "1"
+  cookies:
+    age: verified
+two_step:
+  link_xpath: '//div[@class="item"]/a/@href'
+  title_xpath: '//div[@class="item"]/a/text()'
+  match: clean_number
+  link_prefix: "http://{domain}"
+  check_count: true
+decode:
+  number: '//span[@class="number"]/text()'
+  title: '//h1[@class="title"]/text()'
+  actors: '//a[@class="actor"]/text()'
+  release_date: '//span[@class="date"]/text()'
+  duration: '//span[@class="duration"]/text()'
+  cover: '//img[@class="cover"]/@src'
+  date_parser: "2006/01/02"
+  duration_parser: hhmmss
+  require_number: true
+  post_process:
+    title:
+      - pattern: '^\[[^\]]+\]'
+        replace: ""
+`
+
+func TestDeclarativePlugin(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/search":
+			assert.Equal(t, "ABC123", r.URL.Query().Get("q"))
+			assert.Equal(t, "1", r.Header.Get("X-Test"))
+			c, err := r.Cookie("age")
+			assert.NoError(t, err)
+			assert.Equal(t, "verified", c.Value)
+			_, _ = io.WriteString(w, testSearchPage)
+		case "/v/2":
+			_, _ = io.WriteString(w, testDetailPage)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	spec, err := ParseSpec("test.yaml", []byte(strings.ReplaceAll(testSpec, "%s", host)))
+	assert.NoError(t, err)
+	plg, err := NewPlugin(spec)
+	assert.NoError(t, err)
+
+	ctx := meta.SetNumberId(context.Background(), "ABC-123")
+	req, err := plg.OnMakeHTTPRequest(ctx, &model.Number{NumberId: "ABC-123"})
+	assert.NoError(t, err)
+	invoker := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		if err := plg.OnDecorateRequest(ctx, req); err != nil {
+			return nil, err
+		}
+		return http.DefaultClient.Do(req)
+	}
+	rsp, err := plg.OnHandleHTTPRequest(ctx, invoker, req)
+	assert.NoError(t, err)
+	defer rsp.Body.Close()
+	ok, err := plg.OnPrecheckResponse(ctx, req, rsp)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	data, err := io.ReadAll(rsp.Body)
+	assert.NoError(t, err)
+	avmeta, ok, err := plg.OnDecodeHTTPData(ctx, data)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "ABC-123", avmeta.Number)
+	assert.Equal(t, "Title Here", avmeta.Title)
+	assert.Equal(t, []string{"A", "B"}, avmeta.Actors)
+	assert.Equal(t, int64(3720), avmeta.Duration)
+	assert.NotEqual(t, int64(0), avmeta.ReleaseDate)
+	assert.Equal(t, "/cover.jpg", avmeta.Cover.Name)
+
+	_, ok, err = plg.OnDecodeHTTPData(ctx, []byte(`<html><h1 class="title">x</h1></html>`))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestParseSpecInvalid(t *testing.T) {
+	invalids := []string{
+		`{"request": {"url": "http://a/{number}"}, "decode": {"title": "//h1"}}`,
+		`{"name": "a", "decode": {"title": "//h1"}}`,
+		`{"name": "a", "request": {"url": "http://{domain}/{number}"}, "decode": {"title": "//h1"}}`,
+		`{"name": "a", "request": {"url": "http://a/{number}"}, "decode": {}}`,
+		`{"name": "a", "request": {"url": "http://a/{number}"}, "two_step": {"link_xpath": "//a", "match": "clean_number"}, "decode": {"title": "//h1"}}`,
+		`{"name": "a", "request": {"url": "http://a/{number}"}, "decode": {"title": "//h1", "post_process": {"title": [{"pattern": "("}]}}}`,
+		`{"name": "a", "request": {"url": "http://a/{number}"}, "decode": {"title": "//h1", "post_process": {"xx": []}}}`,
+	}
+	for _, item := range invalids {
+		_, err := ParseSpec("a.json", []byte(item))
+		assert.Error(t, err, item)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"name": "declarative_test_a", "request": {"url": "http://a/{number}"}, "decode": {"title": "//h1"}}`), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "readme.txt"), []byte("ignore"), 0644))
+	names, err := LoadDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"declarative_test_a"}, names)
+	_, err = factory.CreatePlugin("declarative_test_a", nil)
+	assert.NoError(t, err)
+
+	names, err = LoadDir(filepath.Join(dir, "not_exist"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(names))
+}
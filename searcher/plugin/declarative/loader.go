@@ -0,0 +1,84 @@
+package declarative
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"yamdc/searcher/plugin/factory"
+
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// ParseSpec 解析插件定义, 根据扩展名选择yaml或者json格式
+func ParseSpec(file string, raw []byte) (*Spec, error) {
+	spec := &Spec{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(raw, spec); err != nil {
+			return nil, fmt.Errorf("decode yaml failed, err:%w", err)
+		}
+	case ".json":
+		if err := json.Unmarshal(raw, spec); err != nil {
+			return nil, fmt.Errorf("decode json failed, err:%w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported plugin file:%s", file)
+	}
+	if err := spec.Verify(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// LoadDir 加载目录中全部的声明式插件并注册, 与内置插件同名时会覆盖内置插件, 目录不存在时直接忽略
+func LoadDir(dir string) ([]string, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read plugin dir failed, err:%w", err)
+	}
+	exist := make(map[string]struct{})
+	for _, name := range factory.Plugins() {
+		exist[name] = struct{}{}
+	}
+	rs := make([]string, 0, len(ents))
+	for _, ent := range ents {
+		if ent.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(ent.Name())) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		file := filepath.Join(dir, ent.Name())
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read plugin file:%s failed, err:%w", file, err)
+		}
+		spec, err := ParseSpec(file, raw)
+		if err != nil {
+			return nil, fmt.Errorf("parse plugin file:%s failed, err:%w", file, err)
+		}
+		plg, err := NewPlugin(spec)
+		if err != nil {
+			return nil, fmt.Errorf("create plugin from file:%s failed, err:%w", file, err)
+		}
+		logger := logutil.GetLogger(context.Background()).With(zap.String("plugin", spec.Name), zap.String("file", file))
+		if _, ok := exist[spec.Name]; ok {
+			logger.Warn("declarative plugin overrides registered plugin")
+		}
+		factory.Register(spec.Name, factory.PluginToCreator(plg))
+		exist[spec.Name] = struct{}{}
+		logger.Info("load declarative plugin succ")
+		rs = append(rs, spec.Name)
+	}
+	return rs, nil
+}
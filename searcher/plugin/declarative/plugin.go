@@ -0,0 +1,223 @@
+package declarative
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+	"yamdc/model"
+	"yamdc/number_parser"
+	"yamdc/searcher/decoder"
+	"yamdc/searcher/parser"
+	"yamdc/searcher/plugin/api"
+	"yamdc/searcher/plugin/meta"
+	"yamdc/searcher/plugin/twostep"
+	putils "yamdc/searcher/utils"
+
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+type declarativePlugin struct {
+	api.DefaultPlugin
+	spec *Spec
+}
+
+// NewPlugin 根据插件定义创建插件
+func NewPlugin(spec *Spec) (api.IPlugin, error) {
+	if err := spec.Verify(); err != nil {
+		return nil, err
+	}
+	return &declarativePlugin{spec: spec}, nil
+}
+
+func renderTemplate(tpl string, domain string, number string) string {
+	return strings.NewReplacer(
+		"{domain}", domain,
+		"{number}", number,
+		"{number_lower}", strings.ToLower(number),
+		"{clean_number}", number_parser.GetCleanID(number),
+		"{number_underscore}", strings.ReplaceAll(number, "-", "_"),
+	).Replace(tpl)
+}
+
+func (p *declarativePlugin) selectDomain(ctx context.Context) string {
+	if len(p.spec.Domains) == 0 && len(api.GetPluginConfig(ctx).Domains) == 0 {
+		return ""
+	}
+	return api.SelectPluginDomain(ctx, p.spec.Domains)
+}
+
+func (p *declarativePlugin) OnMakeHTTPRequest(ctx context.Context, number *model.Number) (*http.Request, error) {
+	link := renderTemplate(p.spec.Request.URL, p.selectDomain(ctx), number.GetNumberID())
+	return http.NewRequest(http.MethodGet, link, nil)
+}
+
+func (p *declarativePlugin) OnDecorateRequest(ctx context.Context, req *http.Request) error {
+	for k, v := range p.spec.Request.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range p.spec.Request.Cookies {
+		req.AddCookie(&http.Cookie{Name: k, Value: v})
+	}
+	return nil
+}
+
+func (p *declarativePlugin) OnHandleHTTPRequest(ctx context.Context, invoker api.HTTPInvoker, req *http.Request) (*http.Response, error) {
+	ts := p.spec.TwoStep
+	if ts == nil {
+		return invoker(ctx, req)
+	}
+	ps := []*twostep.XPathPair{{Name: "links", XPath: ts.LinkXPath}}
+	if len(ts.TitleXPath) > 0 {
+		ps = append(ps, &twostep.XPathPair{Name: "titles", XPath: ts.TitleXPath})
+	}
+	validStatus := ts.ValidStatus
+	if len(validStatus) == 0 {
+		validStatus = []int{http.StatusOK}
+	}
+	cleanNumberId := strings.ToUpper(number_parser.GetCleanID(meta.GetNumberId(ctx)))
+	return twostep.HandleXPathTwoStepSearch(ctx, invoker, req, &twostep.XPathTwoStepContext{
+		Ps: ps,
+		LinkSelector: func(ps []*twostep.XPathPair) (string, bool, error) {
+			links := ps[0].Result
+			if ts.Match != MatchCleanNumber {
+				if len(links) == 0 {
+					return "", false, nil
+				}
+				return links[0], true, nil
+			}
+			titles := ps[1].Result
+			for i, link := range links {
+				if i >= len(titles) {
+					break
+				}
+				title := strings.ToUpper(number_parser.GetCleanID(titles[i]))
+				if strings.Contains(title, cleanNumberId) {
+					return link, true, nil
+				}
+			}
+			return "", false, nil
+		},
+		ValidStatusCode:       validStatus,
+		CheckResultCountMatch: ts.CheckCount,
+		LinkPrefix:            renderTemplate(ts.LinkPrefix, req.URL.Host, meta.GetNumberId(ctx)),
+	})
+}
+
+func (p *declarativePlugin) OnPrecheckResponse(ctx context.Context, req *http.Request, rsp *http.Response) (bool, error) {
+	notFound := p.spec.Request.NotFoundStatus
+	if len(notFound) == 0 {
+		notFound = []int{http.StatusNotFound}
+	}
+	for _, code := range notFound {
+		if rsp.StatusCode == code {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (p *declarativePlugin) OnDecodeHTTPData(ctx context.Context, data []byte) (*model.AvMeta, bool, error) {
+	d := &p.spec.Decode
+	dec := decoder.XPathHtmlDecoder{
+		NumberExpr:          d.Number,
+		TitleExpr:           d.Title,
+		PlotExpr:            d.Plot,
+		ActorListExpr:       d.Actors,
+		ReleaseDateExpr:     d.ReleaseDate,
+		DurationExpr:        d.Duration,
+		StudioExpr:          d.Studio,
+		LabelExpr:           d.Label,
+		DirectorExpr:        d.Director,
+		SeriesExpr:          d.Series,
+		GenreListExpr:       d.Genres,
+		CoverExpr:           d.Cover,
+		PosterExpr:          d.Poster,
+		SampleImageListExpr: d.SampleImages,
+	}
+	rs, err := dec.DecodeHTML(data, p.decodeOptions(ctx)...)
+	if err != nil {
+		return nil, false, err
+	}
+	if d.RequireNumber && len(rs.Number) == 0 {
+		return nil, false, nil
+	}
+	if p.spec.Translate {
+		putils.EnableDataTranslate(rs)
+	}
+	return rs, true, nil
+}
+
+func (p *declarativePlugin) stringParser(field string) decoder.StringParseFunc {
+	rules := p.spec.Decode.compiledReplace[field]
+	return func(v string) string {
+		for _, rule := range rules {
+			v = rule.re.ReplaceAllString(v, rule.replace)
+		}
+		return strings.TrimSpace(v)
+	}
+}
+
+func (p *declarativePlugin) stringListParser(field string) decoder.StringListParseFunc {
+	fn := p.stringParser(field)
+	return func(vs []string) []string {
+		rs := make([]string, 0, len(vs))
+		for _, v := range vs {
+			v = fn(v)
+			if len(v) == 0 {
+				continue
+			}
+			rs = append(rs, v)
+		}
+		return rs
+	}
+}
+
+func (p *declarativePlugin) numberParser(field string, next decoder.NumberParseFunc) decoder.NumberParseFunc {
+	fn := p.stringParser(field)
+	return func(v string) int64 {
+		return next(fn(v))
+	}
+}
+
+func (p *declarativePlugin) releaseDateParser(ctx context.Context) decoder.NumberParseFunc {
+	layout := p.spec.Decode.DateParser
+	if len(layout) == 0 || layout == DateParserDefault {
+		return parser.DefaultReleaseDateParser(ctx)
+	}
+	return func(v string) int64 {
+		t, err := time.Parse(layout, v)
+		if err != nil {
+			logutil.GetLogger(ctx).Error("decode release date failed", zap.Error(err), zap.String("data", v), zap.String("layout", layout))
+			return 0
+		}
+		return t.UnixMilli()
+	}
+}
+
+func (p *declarativePlugin) durationParser(ctx context.Context) decoder.NumberParseFunc {
+	if p.spec.Decode.DurationParser == DurationHHMMSS {
+		return parser.DefaultHHMMSSDurationParser(ctx)
+	}
+	return parser.DefaultDurationParser(ctx)
+}
+
+func (p *declarativePlugin) decodeOptions(ctx context.Context) []decoder.Option {
+	return []decoder.Option{
+		decoder.WithNumberParser(p.stringParser("number")),
+		decoder.WithTitleParser(p.stringParser("title")),
+		decoder.WithPlotParser(p.stringParser("plot")),
+		decoder.WithActorListParser(p.stringListParser("actors")),
+		decoder.WithReleaseDateParser(p.numberParser("release_date", p.releaseDateParser(ctx))),
+		decoder.WithDurationParser(p.numberParser("duration", p.durationParser(ctx))),
+		decoder.WithStudioParser(p.stringParser("studio")),
+		decoder.WithLabelParser(p.stringParser("label")),
+		decoder.WithDirectorParser(p.stringParser("director")),
+		decoder.WithSeriesParser(p.stringParser("series")),
+		decoder.WithGenreListParser(p.stringListParser("genres")),
+		decoder.WithCoverParser(p.stringParser("cover")),
+		decoder.WithPosterParser(p.stringParser("poster")),
+		decoder.WithSampleImageListParser(p.stringListParser("sample_images")),
+	}
+}
@@ -0,0 +1,130 @@
+package declarative
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	MatchCleanNumber = "clean_number" //标题去除分隔符后包含番号时选中该链接
+	MatchFirst       = "first"        //直接选择第一个链接
+)
+
+const (
+	DateParserDefault = "default" //yyyy-mm-dd
+	DurationDefault   = "default" //从文本中提取分钟数
+	DurationHHMMSS    = "hhmmss"  //hh:mm:ss
+)
+
+// Spec 声明式插件的定义, 支持yaml及json格式
+type Spec struct {
+	Name      string       `yaml:"name" json:"name"`
+	Domains   []string     `yaml:"domains" json:"domains"` //url模板中{domain}的候选列表, 每次请求随机选择一个
+	Request   RequestSpec  `yaml:"request" json:"request"`
+	TwoStep   *TwoStepSpec `yaml:"two_step" json:"two_step"` //可选, 先请求搜索页, 再从中选择详情页链接
+	Decode    DecodeSpec   `yaml:"decode" json:"decode"`
+	Translate bool         `yaml:"translate" json:"translate"` //是否需要翻译标题及简介
+}
+
+type RequestSpec struct {
+	URL            string            `yaml:"url" json:"url"` //支持{domain}, {number}, {number_lower}, {clean_number}, {number_underscore}
+	Headers        map[string]string `yaml:"headers" json:"headers"`
+	Cookies        map[string]string `yaml:"cookies" json:"cookies"`
+	NotFoundStatus []int             `yaml:"not_found_status" json:"not_found_status"` //视为未找到的http状态码, 默认为404
+}
+
+type TwoStepSpec struct {
+	LinkXPath   string `yaml:"link_xpath" json:"link_xpath"`
+	TitleXPath  string `yaml:"title_xpath" json:"title_xpath"` //match为clean_number时使用
+	Match       string `yaml:"match" json:"match"`
+	LinkPrefix  string `yaml:"link_prefix" json:"link_prefix"` //链接为相对路径时的前缀, 支持{domain}
+	ValidStatus []int  `yaml:"valid_status" json:"valid_status"`
+	CheckCount  bool   `yaml:"check_count" json:"check_count"`
+}
+
+type ReplaceRule struct {
+	Pattern string `yaml:"pattern" json:"pattern"`
+	Replace string `yaml:"replace" json:"replace"`
+}
+
+type DecodeSpec struct {
+	Number          string                   `yaml:"number" json:"number"`
+	Title           string                   `yaml:"title" json:"title"`
+	Plot            string                   `yaml:"plot" json:"plot"`
+	Actors          string                   `yaml:"actors" json:"actors"`
+	ReleaseDate     string                   `yaml:"release_date" json:"release_date"`
+	Duration        string                   `yaml:"duration" json:"duration"`
+	Studio          string                   `yaml:"studio" json:"studio"`
+	Label           string                   `yaml:"label" json:"label"`
+	Director        string                   `yaml:"director" json:"director"`
+	Series          string                   `yaml:"series" json:"series"`
+	Genres          string                   `yaml:"genres" json:"genres"`
+	Cover           string                   `yaml:"cover" json:"cover"`
+	Poster          string                   `yaml:"poster" json:"poster"`
+	SampleImages    string                   `yaml:"sample_images" json:"sample_images"`
+	DateParser      string                   `yaml:"date_parser" json:"date_parser"`         //default或者go的时间格式, 例如: 2006/01/02
+	DurationParser  string                   `yaml:"duration_parser" json:"duration_parser"` //default, hhmmss
+	PostProcess     map[string][]ReplaceRule `yaml:"post_process" json:"post_process"`       //字段的正则替换规则, key为字段名
+	RequireNumber   bool                     `yaml:"require_number" json:"require_number"`   //番号为空时视为未找到
+	compiledReplace map[string][]*compiledRule
+}
+
+type compiledRule struct {
+	re      *regexp.Regexp
+	replace string
+}
+
+var decodeFields = map[string]struct{}{
+	"number": {}, "title": {}, "plot": {}, "actors": {}, "release_date": {}, "duration": {}, "studio": {},
+	"label": {}, "director": {}, "series": {}, "genres": {}, "cover": {}, "poster": {}, "sample_images": {},
+}
+
+// Verify 检查插件定义是否有效, 同时预编译正则
+func (s *Spec) Verify() error {
+	if len(s.Name) == 0 {
+		return fmt.Errorf("no plugin name")
+	}
+	if len(s.Request.URL) == 0 {
+		return fmt.Errorf("no request url")
+	}
+	if strings.Contains(s.Request.URL, "{domain}") && len(s.Domains) == 0 {
+		return fmt.Errorf("url contains {domain} but no domains")
+	}
+	if s.TwoStep != nil {
+		if len(s.TwoStep.LinkXPath) == 0 {
+			return fmt.Errorf("no two step link xpath")
+		}
+		switch s.TwoStep.Match {
+		case "", MatchFirst:
+		case MatchCleanNumber:
+			if len(s.TwoStep.TitleXPath) == 0 {
+				return fmt.Errorf("two step match:%s requires title xpath", s.TwoStep.Match)
+			}
+		default:
+			return fmt.Errorf("invalid two step match:%s", s.TwoStep.Match)
+		}
+	}
+	if len(s.Decode.Title) == 0 {
+		return fmt.Errorf("no title xpath")
+	}
+	switch s.Decode.DurationParser {
+	case "", DurationDefault, DurationHHMMSS:
+	default:
+		return fmt.Errorf("invalid duration parser:%s", s.Decode.DurationParser)
+	}
+	s.Decode.compiledReplace = make(map[string][]*compiledRule, len(s.Decode.PostProcess))
+	for field, rules := range s.Decode.PostProcess {
+		if _, ok := decodeFields[field]; !ok {
+			return fmt.Errorf("unknown post process field:%s", field)
+		}
+		for _, rule := range rules {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return fmt.Errorf("compile post process regex failed, field:%s, pattern:%s, err:%w", field, rule.Pattern, err)
+			}
+			s.Decode.compiledReplace[field] = append(s.Decode.compiledReplace[field], &compiledRule{re: re, replace: rule.Replace})
+		}
+	}
+	return nil
+}
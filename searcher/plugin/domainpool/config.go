@@ -0,0 +1,65 @@
+package domainpool
+
+import (
+	"time"
+	"yamdc/store"
+)
+
+const (
+	defaultFailureThreshold = 3
+	defaultCooldown         = 10 * time.Minute
+)
+
+type config struct {
+	st               store.IDomainHealthStore
+	failureThreshold int64
+	cooldown         time.Duration
+	now              func() time.Time
+}
+
+type Option func(c *config)
+
+// WithStore 持久化域名健康状态, 为空时仅在内存中统计
+func WithStore(st store.IDomainHealthStore) Option {
+	return func(c *config) {
+		c.st = st
+	}
+}
+
+// WithFailureThreshold 连续失败达到该次数后, 域名在冷却期内不再被优先选择
+func WithFailureThreshold(n int64) Option {
+	return func(c *config) {
+		c.failureThreshold = n
+	}
+}
+
+// WithCooldown 不可用域名的冷却时长, 超过后重新参与选择
+func WithCooldown(d time.Duration) Option {
+	return func(c *config) {
+		c.cooldown = d
+	}
+}
+
+// WithClock 指定获取当前时间的函数, 默认为time.Now
+func WithClock(fn func() time.Time) Option {
+	return func(c *config) {
+		c.now = fn
+	}
+}
+
+func applyOpts(opts ...Option) *config {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.failureThreshold <= 0 {
+		c.failureThreshold = defaultFailureThreshold
+	}
+	if c.cooldown <= 0 {
+		c.cooldown = defaultCooldown
+	}
+	if c.now == nil {
+		c.now = time.Now
+	}
+	return c
+}
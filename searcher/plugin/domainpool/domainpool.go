@@ -0,0 +1,299 @@
+package domainpool
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"yamdc/store"
+
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+const (
+	latencyWeight = 4 //平均延迟的平滑系数, 新的延迟占1/latencyWeight
+)
+
+type Invoker = func(ctx context.Context, req *http.Request) (*http.Response, error)
+
+// RequestGate 每次实际发出请求前调用, host为本次请求的域名(故障转移后为切换后的域名), 返回错误时放弃该请求
+type RequestGate func(ctx context.Context, host string) error
+
+type requestGateKeyType struct{}
+
+var defaultRequestGateKey = requestGateKeyType{}
+
+// WithRequestGate 在ctx中挂载请求前的回调, 用于按实际请求的域名限流及统计请求次数
+func WithRequestGate(ctx context.Context, gate RequestGate) context.Context {
+	return context.WithValue(ctx, defaultRequestGateKey, gate)
+}
+
+// invokeWithGate 执行挂载的回调后再发起请求
+func invokeWithGate(ctx context.Context, next Invoker, req *http.Request) (*http.Response, error) {
+	if gate, ok := ctx.Value(defaultRequestGateKey).(RequestGate); ok && gate != nil {
+		if err := gate(ctx, req.URL.Host); err != nil {
+			return nil, err
+		}
+	}
+	return next(ctx, req)
+}
+
+// Pool 域名池, 记录每个域名的成功次数, 延迟及失败信息, 优先选择健康的镜像,
+// 并在连接失败或者5xx时切换到同组的其他镜像重试
+type Pool struct {
+	c       *config
+	mu      sync.Mutex
+	healths map[string]*store.DomainHealth
+	groups  map[string][]string //域名 => 同组的镜像列表
+}
+
+var defaultPool = New()
+
+func SetDefault(p *Pool) {
+	defaultPool = p
+}
+
+func Default() *Pool {
+	return defaultPool
+}
+
+func New(opts ...Option) *Pool {
+	return &Pool{
+		c:       applyOpts(opts...),
+		healths: make(map[string]*store.DomainHealth),
+		groups:  make(map[string][]string),
+	}
+}
+
+func (p *Pool) now() time.Time {
+	return p.c.now()
+}
+
+// loadHealth 调用方需要持有锁
+func (p *Pool) loadHealth(ctx context.Context, domain string) *store.DomainHealth {
+	if h, ok := p.healths[domain]; ok {
+		return h
+	}
+	h := &store.DomainHealth{Domain: domain}
+	if p.c.st != nil {
+		saved, ok, err := p.c.st.GetDomainHealth(ctx, domain)
+		if err != nil {
+			logutil.GetLogger(ctx).Error("load domain health failed", zap.String("domain", domain), zap.Error(err))
+		}
+		if ok {
+			h = saved
+		}
+	}
+	p.healths[domain] = h
+	return h
+}
+
+func (p *Pool) isAvailable(h *store.DomainHealth) bool {
+	if h.ConsecutiveFailure < p.c.failureThreshold {
+		return true
+	}
+	return p.now().UnixMilli()-h.LastFailure >= p.c.cooldown.Milliseconds()
+}
+
+func (p *Pool) isBetter(a, b *store.DomainHealth) bool {
+	aa, ba := p.isAvailable(a), p.isAvailable(b)
+	if aa != ba {
+		return aa
+	}
+	//都不可用时优先选择最早失败的, 其最先结束冷却
+	if !aa && a.LastFailure != b.LastFailure {
+		return a.LastFailure < b.LastFailure
+	}
+	if a.ConsecutiveFailure != b.ConsecutiveFailure {
+		return a.ConsecutiveFailure < b.ConsecutiveFailure
+	}
+	if !aa {
+		return false
+	}
+	//未请求过的域名延迟为0, 会被优先尝试
+	return a.AvgLatency < b.AvgLatency
+}
+
+// pick 选择最健康的域名, 状态相同时按候选列表的顺序选择, 调用方需要持有锁
+func (p *Pool) pick(ctx context.Context, candidates []string, excluded map[string]struct{}) (string, bool) {
+	var best *store.DomainHealth
+	for _, domain := range candidates {
+		if _, ok := excluded[domain]; ok {
+			continue
+		}
+		h := p.loadHealth(ctx, domain)
+		if best == nil || p.isBetter(h, best) {
+			best = h
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+	return best.Domain, true
+}
+
+// Select 从候选域名中选择当前最健康的一个, 候选列表会被记录为同一组镜像, 用于失败后切换
+func (p *Pool) Select(ctx context.Context, candidates []string) string {
+	if len(candidates) == 0 {
+		panic("unable to select domain")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, domain := range candidates {
+		if _, ok := p.groups[domain]; !ok {
+			p.groups[domain] = candidates
+		}
+	}
+	domain, _ := p.pick(ctx, candidates, nil)
+	return domain
+}
+
+func (p *Pool) isPooled(domain string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.groups[domain]
+	return ok
+}
+
+func (p *Pool) next(ctx context.Context, domain string, tried map[string]struct{}) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pick(ctx, p.groups[domain], tried)
+}
+
+func (p *Pool) save(ctx context.Context, h *store.DomainHealth) {
+	if p.c.st == nil {
+		return
+	}
+	cp := *h
+	if err := p.c.st.PutDomainHealth(ctx, &cp); err != nil {
+		logutil.GetLogger(ctx).Error("save domain health failed", zap.String("domain", h.Domain), zap.Error(err))
+	}
+}
+
+func (p *Pool) ReportSuccess(ctx context.Context, domain string, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h := p.loadHealth(ctx, domain)
+	h.Success++
+	h.ConsecutiveFailure = 0
+	if h.AvgLatency == 0 {
+		h.AvgLatency = latency.Milliseconds()
+	} else {
+		h.AvgLatency = (h.AvgLatency*(latencyWeight-1) + latency.Milliseconds()) / latencyWeight
+	}
+	h.UpdateAt = p.now().UnixMilli()
+	p.save(ctx, h)
+}
+
+func (p *Pool) ReportFailure(ctx context.Context, domain string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h := p.loadHealth(ctx, domain)
+	h.Failure++
+	h.ConsecutiveFailure++
+	h.LastFailure = p.now().UnixMilli()
+	h.LastError = err.Error()
+	h.UpdateAt = h.LastFailure
+	p.save(ctx, h)
+}
+
+// Snapshot 返回本次运行中使用过的域名的健康状态
+func (p *Pool) Snapshot() []*store.DomainHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	rs := make([]*store.DomainHealth, 0, len(p.healths))
+	for _, h := range p.healths {
+		cp := *h
+		rs = append(rs, &cp)
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].Domain < rs[j].Domain
+	})
+	return rs
+}
+
+func (p *Pool) LogState(ctx context.Context) {
+	logger := logutil.GetLogger(ctx)
+	for _, h := range p.Snapshot() {
+		logger.Info("domain pool state", zap.String("domain", h.Domain), zap.Bool("available", p.isAvailable(h)),
+			zap.Int64("success", h.Success), zap.Int64("failure", h.Failure), zap.Int64("consecutive_failure", h.ConsecutiveFailure),
+			zap.Int64("avg_latency_ms", h.AvgLatency), zap.Int64("last_failure", h.LastFailure), zap.String("last_error", h.LastError))
+	}
+}
+
+// failoverReason 返回需要切换镜像的原因, 404等业务上的失败说明站点本身可用, 不会触发切换
+func failoverReason(rsp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	if rsp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("server error, code:%d", rsp.StatusCode)
+	}
+	return nil
+}
+
+func canReplay(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func rewriteHost(ctx context.Context, req *http.Request, domain string) (*http.Request, error) {
+	old := req.URL.Host
+	newReq := req.Clone(ctx)
+	newReq.URL.Host = domain
+	newReq.Host = domain
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("rebuild request body failed, err:%w", err)
+		}
+		newReq.Body = body
+	}
+	if ref := req.Referer(); len(ref) > 0 {
+		newReq.Header.Set("Referer", strings.Replace(ref, "://"+old, "://"+domain, 1))
+	}
+	return newReq, nil
+}
+
+// WrapInvoker 统计请求结果, 并在请求域名池中的域名失败时, 切换到同组的其他镜像重试
+func (p *Pool) WrapInvoker(next Invoker) Invoker {
+	return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		domain := req.URL.Host
+		if !p.isPooled(domain) {
+			return invokeWithGate(ctx, next, req)
+		}
+		tried := make(map[string]struct{}, 2)
+		for {
+			tried[domain] = struct{}{}
+			start := p.now()
+			rsp, err := invokeWithGate(ctx, next, req)
+			reason := failoverReason(rsp, err)
+			if reason == nil {
+				p.ReportSuccess(ctx, domain, p.now().Sub(start))
+				return rsp, nil
+			}
+			if ctx.Err() != nil { //主动取消的请求不计入失败
+				return rsp, err
+			}
+			p.ReportFailure(ctx, domain, reason)
+			nextDomain, ok := p.next(ctx, domain, tried)
+			if !ok || !canReplay(req) {
+				return rsp, err
+			}
+			newReq, rerr := rewriteHost(ctx, req, nextDomain)
+			if rerr != nil {
+				return rsp, err
+			}
+			if rsp != nil {
+				rsp.Body.Close()
+			}
+			logutil.GetLogger(ctx).Warn("request failed, switch to another domain", zap.String("domain", domain),
+				zap.String("next", nextDomain), zap.Error(reason))
+			req, domain = newReq, nextDomain
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package domainpool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"yamdc/store"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func httpInvoker(ctx context.Context, req *http.Request) (*http.Response, error) {
+	return http.DefaultClient.Do(req)
+}
+
+func hostOf(srv *httptest.Server) string {
+	return srv.Listener.Addr().String()
+}
+
+func TestSelectPreferHealthy(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := New(WithFailureThreshold(2), WithCooldown(time.Minute), WithClock(func() time.Time { return now }))
+	ctx := context.Background()
+	cands := []string{"a.com", "b.com"}
+	p.ReportSuccess(ctx, "a.com", 200*time.Millisecond)
+	p.ReportSuccess(ctx, "b.com", 50*time.Millisecond)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "b.com", p.Select(ctx, cands))
+	}
+	p.ReportFailure(ctx, "b.com", errors.New("conn refused"))
+	assert.Equal(t, "a.com", p.Select(ctx, cands))
+	now = now.Add(time.Second)
+	p.ReportFailure(ctx, "a.com", errors.New("conn refused"))
+	p.ReportFailure(ctx, "a.com", errors.New("conn refused"))
+	now = now.Add(time.Second)
+	p.ReportFailure(ctx, "b.com", errors.New("conn refused"))
+	//都不可用时选择最早失败的
+	assert.Equal(t, "a.com", p.Select(ctx, cands))
+	//冷却结束后重新可用
+	now = now.Add(2 * time.Minute)
+	p.ReportFailure(ctx, "a.com", errors.New("conn refused"))
+	assert.Equal(t, "b.com", p.Select(ctx, cands))
+}
+
+func TestSelectTieBreak(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := New(WithFailureThreshold(2), WithCooldown(time.Minute), WithClock(func() time.Time { return now }))
+	ctx := context.Background()
+	cands := []string{"a.com", "b.com", "c.com"}
+	//状态相同时按候选顺序选择
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "a.com", p.Select(ctx, cands))
+	}
+	//同一时刻失败时优先选择连续失败次数少的
+	for _, domain := range cands {
+		p.ReportFailure(ctx, domain, errors.New("conn refused"))
+		p.ReportFailure(ctx, domain, errors.New("conn refused"))
+	}
+	p.ReportFailure(ctx, "a.com", errors.New("conn refused"))
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "b.com", p.Select(ctx, cands))
+	}
+}
+
+func TestFailover(t *testing.T) {
+	var badCount int32
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&badCount, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer bad.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write([]byte(r.Host + ":" + string(body)))
+	}))
+	defer good.Close()
+	st, err := store.NewSqliteStorage(filepath.Join(t.TempDir(), "cache.db"))
+	assert.NoError(t, err)
+	hs := st.(store.IDomainHealthStore)
+	p := New(WithStore(hs))
+	ctx := context.Background()
+	cands := []string{hostOf(bad), hostOf(good)}
+	p.Select(ctx, cands)
+	invoker := p.WrapInvoker(httpInvoker)
+
+	req, err := http.NewRequest(http.MethodPost, "http://"+hostOf(bad)+"/search", strings.NewReader("abc"))
+	assert.NoError(t, err)
+	//每次实际请求前都会以实际的域名调用gate
+	hosts := make([]string, 0, 2)
+	gctx := WithRequestGate(ctx, func(ctx context.Context, host string) error {
+		hosts = append(hosts, host)
+		return nil
+	})
+	rsp, err := invoker(gctx, req)
+	assert.Equal(t, cands, hosts)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	assert.Equal(t, hostOf(good)+":abc", string(data))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&badCount))
+
+	h, ok, err := hs.GetDomainHealth(ctx, hostOf(bad))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), h.ConsecutiveFailure)
+	assert.Contains(t, h.LastError, "502")
+	h, ok, err = hs.GetDomainHealth(ctx, hostOf(good))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), h.Success)
+
+	//新的实例从store中恢复状态
+	p2 := New(WithStore(hs), WithFailureThreshold(1))
+	assert.Equal(t, hostOf(good), p2.Select(ctx, cands))
+	assert.Equal(t, 2, len(p2.Snapshot()))
+}
+
+func TestFailoverAllBad(t *testing.T) {
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+	p := New()
+	ctx := context.Background()
+	p.Select(ctx, []string{hostOf(bad), "127.0.0.1:1"})
+	req, err := http.NewRequest(http.MethodGet, "http://"+hostOf(bad)+"/", nil)
+	assert.NoError(t, err)
+	rsp, err := p.WrapInvoker(httpInvoker)(ctx, req)
+	//全部镜像失败时返回最后一次的结果
+	assert.Error(t, err)
+	assert.Nil(t, rsp)
+	assert.Equal(t, 2, len(p.Snapshot()))
+
+	//不在域名池中的请求直接透传
+	req, err = http.NewRequest(http.MethodGet, bad.URL, nil)
+	assert.NoError(t, err)
+	req.URL.Host = "localhost:" + strings.Split(hostOf(bad), ":")[1]
+	rsp, err = p.WrapInvoker(httpInvoker)(ctx, req)
+	assert.NoError(t, err)
+	rsp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, rsp.StatusCode)
+	assert.Equal(t, 2, len(p.Snapshot()))
+}
@@ -22,9 +22,14 @@ func CreatePlugin(name string, args interface{}) (api.IPlugin, error) {
 	return cr(args)
 }
 
+// PluginToCreator 将插件转换为CreatorFunc, 创建时会将args解析为插件配置并校验
 func PluginToCreator(plg api.IPlugin) CreatorFunc {
 	return func(args interface{}) (api.IPlugin, error) {
-		return plg, nil
+		c, err := api.DecodePluginConfig(args)
+		if err != nil {
+			return nil, err
+		}
+		return api.WithPluginConfig(plg, c)
 	}
 }
 
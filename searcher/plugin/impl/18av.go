@@ -16,12 +16,16 @@ import (
 	"yamdc/searcher/plugin/twostep"
 )
 
+var default18AVDomains = []string{
+	"18av.me",
+}
+
 type av18 struct {
 	api.DefaultPlugin
 }
 
 func (p *av18) OnMakeHTTPRequest(ctx context.Context, number *model.Number) (*http.Request, error) {
-	uri := fmt.Sprintf("https://18av.me/cn/search.php?kw_type=key&kw=%s", number.GetNumberID())
+	uri := fmt.Sprintf("https://%s/cn/search.php?kw_type=key&kw=%s", api.SelectPluginDomain(ctx, default18AVDomains), number.GetNumberID())
 	return http.NewRequest(http.MethodGet, uri, nil)
 }
 
@@ -51,7 +55,7 @@ func (p *av18) OnHandleHTTPRequest(ctx context.Context, invoker api.HTTPInvoker,
 		},
 		ValidStatusCode:       []int{http.StatusOK},
 		CheckResultCountMatch: true,
-		LinkPrefix:            "https://18av.me/cn",
+		LinkPrefix:            fmt.Sprintf("%s://%s/cn", req.URL.Scheme, req.URL.Host),
 	}
 	return twostep.HandleXPathTwoStepSearch(ctx, invoker, req, xctx)
 }
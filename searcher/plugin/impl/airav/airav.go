@@ -17,18 +17,45 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	defaultAiravLanguage = "zh-TW"
+)
+
+var defaultAiravDomains = []string{
+	"www.airav.wiki",
+}
+
+var airavLanguages = map[string]struct{}{
+	"zh-TW": {},
+	"zh-CN": {},
+	"en":    {},
+	"ja":    {},
+}
+
 type airav struct {
 	api.DefaultPlugin
 }
 
 func (p *airav) OnMakeHTTPRequest(ctx context.Context, number *model.Number) (*http.Request, error) {
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://www.airav.wiki/api/video/barcode/%s?lng=zh-TW", number.GetNumberID()), nil)
+	domain := api.SelectPluginDomain(ctx, defaultAiravDomains)
+	lng := api.PluginLanguage(ctx, defaultAiravLanguage)
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://%s/api/video/barcode/%s?lng=%s", domain, number.GetNumberID(), lng), nil)
 	if err != nil {
 		return nil, err
 	}
 	return req, nil
 }
 
+func (p *airav) OnValidateConfig(c *api.PluginConfig) error {
+	if len(c.Language) == 0 {
+		return nil
+	}
+	if _, ok := airavLanguages[c.Language]; !ok {
+		return fmt.Errorf("unsupported language:%s", c.Language)
+	}
+	return nil
+}
+
 func (p *airav) OnDecodeHTTPData(ctx context.Context, data []byte) (*model.AvMeta, bool, error) {
 	vdata := &VideoData{}
 	if err := json.Unmarshal(data, vdata); err != nil {
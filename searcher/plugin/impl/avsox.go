@@ -23,12 +23,16 @@ const (
 	defaultAvsoxSearchExpr = `//*[@id="waterfall"]/div/a/@href`
 )
 
+var defaultAvsoxDomains = []string{
+	"avsox.click",
+}
+
 type avsox struct {
 	api.DefaultPlugin
 }
 
 func (p *avsox) OnMakeHTTPRequest(ctx context.Context, number *model.Number) (*http.Request, error) {
-	return http.NewRequest(http.MethodGet, "https://avsox.click", nil) //返回一个假的request
+	return http.NewRequest(http.MethodGet, "https://"+api.SelectPluginDomain(ctx, defaultAvsoxDomains), nil) //返回一个假的request
 }
 
 func (p *avsox) OnHandleHTTPRequest(ctx context.Context, invoker api.HTTPInvoker, _ *http.Request) (*http.Response, error) {
@@ -52,7 +56,7 @@ func (p *avsox) OnHandleHTTPRequest(ctx context.Context, invoker api.HTTPInvoker
 		break
 	}
 	if len(link) == 0 {
-		return nil, fmt.Errorf("unable to find match number")
+		return nil, api.WrapError(api.ErrNotFound, fmt.Errorf("unable to find match number"))
 	}
 	uri := "https:" + link
 	req, err := http.NewRequest(http.MethodGet, uri, nil)
@@ -75,7 +79,7 @@ func (p *avsox) generateTryList(num string) []string {
 }
 
 func (p *avsox) trySearchByNumber(ctx context.Context, invoker api.HTTPInvoker, number string) (string, bool, error) {
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://avsox.click/cn/search/%s", number), nil)
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://%s/cn/search/%s", api.SelectPluginDomain(ctx, defaultAvsoxDomains), number), nil)
 	if err != nil {
 		return "", false, err
 	}
@@ -23,12 +23,16 @@ import (
 	"golang.org/x/text/transform"
 )
 
+var defaultCaribprDomains = []string{
+	"www.caribbeancompr.com",
+}
+
 type caribpr struct {
 	api.DefaultPlugin
 }
 
 func (p *caribpr) OnMakeHTTPRequest(ctx context.Context, number *model.Number) (*http.Request, error) {
-	uri := fmt.Sprintf("https://www.caribbeancompr.com/moviepages/%s/index.html", number.GetNumberID())
+	uri := fmt.Sprintf("https://%s/moviepages/%s/index.html", api.SelectPluginDomain(ctx, defaultCaribprDomains), number.GetNumberID())
 	req, err := http.NewRequest(http.MethodGet, uri, nil)
 	return req, err
 }
@@ -83,7 +87,7 @@ func (p *caribpr) OnDecodeHTTPData(ctx context.Context, data []byte) (*model.AvM
 		return nil, false, err
 	}
 	metadata.Number = meta.GetNumberId(ctx)
-	metadata.Cover.Name = fmt.Sprintf("https://www.caribbeancompr.com/moviepages/%s/images/l_l.jpg", metadata.Number)
+	metadata.Cover.Name = fmt.Sprintf("https://%s/moviepages/%s/images/l_l.jpg", api.SelectPluginDomain(ctx, defaultCaribprDomains), metadata.Number)
 	putils.EnableDataTranslate(metadata)
 	return metadata, true, nil
 }
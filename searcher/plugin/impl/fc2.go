@@ -23,6 +23,10 @@ import (
 
 var defaultFc2NumberParser = regexp.MustCompile(`^fc2.*?(\d+)$`)
 
+var defaultFc2Domains = []string{
+	"adult.contents.fc2.com",
+}
+
 type fc2 struct {
 	api.DefaultPlugin
 }
@@ -34,7 +38,7 @@ func (p *fc2) OnMakeHTTPRequest(ctx context.Context, n *model.Number) (*http.Req
 		return nil, fmt.Errorf("unabe to decode number")
 	}
 	number = res[1]
-	uri := "https://adult.contents.fc2.com/article/" + number + "/"
+	uri := fmt.Sprintf("https://%s/article/%s/", api.SelectPluginDomain(ctx, defaultFc2Domains), number)
 	return http.NewRequest(http.MethodGet, uri, nil)
 }
 
@@ -28,7 +28,7 @@ func (p *fc2ppvdb) OnMakeHTTPRequest(ctx context.Context, nid *model.Number) (*h
 	if !ok {
 		return nil, fmt.Errorf("unable to decode fc2 vid")
 	}
-	link := fmt.Sprintf("https://%s/articles/%s", api.MustSelectDomain(defaultFc2PPVDBDomains), vid)
+	link := fmt.Sprintf("https://%s/articles/%s", api.SelectPluginDomain(ctx, defaultFc2PPVDBDomains), vid)
 	req, err := http.NewRequest(http.MethodGet, link, nil)
 	if err != nil {
 		return nil, err
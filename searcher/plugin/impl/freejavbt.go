@@ -2,6 +2,7 @@ package impl
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"yamdc/model"
 
@@ -14,12 +15,16 @@ import (
 	putils "yamdc/searcher/utils"
 )
 
+var defaultFreeJavBtDomains = []string{
+	"freejavbt.com",
+}
+
 type freejavbt struct {
 	api.DefaultPlugin
 }
 
 func (p *freejavbt) OnMakeHTTPRequest(ctx context.Context, number *model.Number) (*http.Request, error) {
-	uri := "https://freejavbt.com/zh/" + number.GetNumberID()
+	uri := fmt.Sprintf("https://%s/zh/%s", api.SelectPluginDomain(ctx, defaultFreeJavBtDomains), number.GetNumberID())
 	return http.NewRequest(http.MethodGet, uri, nil)
 }
 
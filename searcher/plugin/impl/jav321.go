@@ -2,6 +2,7 @@ package impl
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -16,6 +17,10 @@ import (
 	putils "yamdc/searcher/utils"
 )
 
+var defaultJav321Domains = []string{
+	"www.jav321.com",
+}
+
 type jav321 struct {
 	api.DefaultPlugin
 }
@@ -24,7 +29,7 @@ func (p *jav321) OnMakeHTTPRequest(ctx context.Context, number *model.Number) (*
 	data := url.Values{}
 	data.Set("sn", number.GetNumberID())
 	body := data.Encode()
-	req, err := http.NewRequest(http.MethodPost, "https://www.jav321.com/search", strings.NewReader(body))
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("https://%s/search", api.SelectPluginDomain(ctx, defaultJav321Domains)), strings.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -23,7 +23,7 @@ type javbus struct {
 }
 
 func (p *javbus) OnMakeHTTPRequest(ctx context.Context, number *model.Number) (*http.Request, error) {
-	url := fmt.Sprintf("https://%s/%s", api.MustSelectDomain(defaultJavBusDomainList), number.GetNumberID())
+	url := fmt.Sprintf("https://%s/%s", api.SelectPluginDomain(ctx, defaultJavBusDomainList), number.GetNumberID())
 	return http.NewRequest(http.MethodGet, url, nil)
 }
 
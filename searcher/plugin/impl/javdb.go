@@ -18,12 +18,16 @@ import (
 	"yamdc/searcher/utils"
 )
 
+var defaultJavDBDomains = []string{
+	"javdb.com",
+}
+
 type javdb struct {
 	api.DefaultPlugin
 }
 
 func (p *javdb) OnMakeHTTPRequest(ctx context.Context, number *model.Number) (*http.Request, error) {
-	link := fmt.Sprintf("https://javdb.com/search?q=%s&f=all", number.GetNumberID())
+	link := fmt.Sprintf("https://%s/search?q=%s&f=all", api.SelectPluginDomain(ctx, defaultJavDBDomains), number.GetNumberID())
 	return http.NewRequest(http.MethodGet, link, nil)
 }
 
@@ -53,7 +57,7 @@ func (p *javdb) OnHandleHTTPRequest(ctx context.Context, invoker api.HTTPInvoker
 		},
 		ValidStatusCode:       []int{http.StatusOK},
 		CheckResultCountMatch: true,
-		LinkPrefix:            "https://javdb.com",
+		LinkPrefix:            fmt.Sprintf("%s://%s", req.URL.Scheme, req.URL.Host),
 	})
 }
 
@@ -14,12 +14,16 @@ import (
 	putils "yamdc/searcher/utils"
 )
 
+var defaultJavhooDomains = []string{
+	"www.javhoo.com",
+}
+
 type javhoo struct {
 	api.DefaultPlugin
 }
 
 func (p *javhoo) OnMakeHTTPRequest(ctx context.Context, number *model.Number) (*http.Request, error) {
-	uri := fmt.Sprintf("https://www.javhoo.com/av/%s", number.GetNumberID())
+	uri := fmt.Sprintf("https://%s/av/%s", api.SelectPluginDomain(ctx, defaultJavhooDomains), number.GetNumberID())
 	return http.NewRequest(http.MethodGet, uri, nil)
 }
 
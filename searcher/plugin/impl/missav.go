@@ -26,7 +26,7 @@ type missav struct {
 }
 
 func (p *missav) OnMakeHTTPRequest(ctx context.Context, number *model.Number) (*http.Request, error) {
-	link := fmt.Sprintf("https://%s/cn/search/%s", api.MustSelectDomain(defaultMissavDomains), number.GetNumberID())
+	link := fmt.Sprintf("https://%s/cn/search/%s", api.SelectPluginDomain(ctx, defaultMissavDomains), number.GetNumberID())
 	return http.NewRequest(http.MethodGet, link, nil)
 }
 
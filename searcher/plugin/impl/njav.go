@@ -17,6 +17,10 @@ import (
 	"yamdc/searcher/plugin/twostep"
 )
 
+var defaultNJavDomains = []string{
+	"njavtv.com",
+}
+
 type njav struct {
 	api.DefaultPlugin
 }
@@ -24,7 +28,7 @@ type njav struct {
 func (p *njav) OnMakeHTTPRequest(ctx context.Context, number *model.Number) (*http.Request, error) {
 	nid := number.GetNumberID()
 	nid = strings.ReplaceAll(nid, "_", "-") //将下划线替换为中划线
-	uri := fmt.Sprintf("https://njavtv.com/cn/search/%s", nid)
+	uri := fmt.Sprintf("https://%s/cn/search/%s", api.SelectPluginDomain(ctx, defaultNJavDomains), nid)
 	return http.NewRequest(http.MethodGet, uri, nil)
 }
 
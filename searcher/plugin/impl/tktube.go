@@ -16,13 +16,17 @@ import (
 	"yamdc/searcher/plugin/twostep"
 )
 
+var defaultTKTubeDomains = []string{
+	"tktube.com",
+}
+
 type tktube struct {
 	api.DefaultPlugin
 }
 
 func (p *tktube) OnMakeHTTPRequest(ctx context.Context, n *model.Number) (*http.Request, error) {
 	nid := strings.ReplaceAll(n.GetNumberID(), "-", "--")
-	uri := fmt.Sprintf("https://tktube.com/zh/search/%s/", nid)
+	uri := fmt.Sprintf("https://%s/zh/search/%s/", api.SelectPluginDomain(ctx, defaultTKTubeDomains), nid)
 	return http.NewRequest(http.MethodGet, uri, nil)
 }
 
@@ -40,7 +40,8 @@ func HandleXPathTwoStepSearch(ctx context.Context, invoker api.HTTPInvoker, req
 		return nil, fmt.Errorf("step search failed, err:%w", err)
 	}
 	if !isCodeInValidStatusCodeList(xctx.ValidStatusCode, rsp.StatusCode) {
-		return nil, fmt.Errorf("status code:%d not in valid list", rsp.StatusCode)
+		rsp.Body.Close()
+		return nil, fmt.Errorf("status code:%d not in valid list, err:%w", rsp.StatusCode, api.NewStatusError(rsp))
 	}
 	node, err := utils.ReadDataAsHTMLTree(rsp)
 	if err != nil {
@@ -52,11 +53,11 @@ func HandleXPathTwoStepSearch(ctx context.Context, invoker api.HTTPInvoker, req
 	if xctx.CheckResultCountMatch {
 		for i := 1; i < len(xctx.Ps); i++ {
 			if len(xctx.Ps[i].Result) != len(xctx.Ps[0].Result) {
-				return nil, fmt.Errorf("result count not match, idx:%d, count:%d not match to idx:0, count:%d", i, len(xctx.Ps[i].Result), len(xctx.Ps[0].Result))
+				return nil, api.WrapError(api.ErrDecode, fmt.Errorf("result count not match, idx:%d, count:%d not match to idx:0, count:%d", i, len(xctx.Ps[i].Result), len(xctx.Ps[0].Result)))
 			}
 		}
 		if len(xctx.Ps[0].Result) == 0 {
-			return nil, fmt.Errorf("no result found")
+			return nil, api.WrapError(api.ErrNotFound, fmt.Errorf("no result found"))
 		}
 	}
 	link, ok, err := xctx.LinkSelector(xctx.Ps)
@@ -64,7 +65,7 @@ func HandleXPathTwoStepSearch(ctx context.Context, invoker api.HTTPInvoker, req
 		return nil, fmt.Errorf("select link from result failed, err:%w", err)
 	}
 	if !ok {
-		return nil, fmt.Errorf("no link select result found")
+		return nil, api.WrapError(api.ErrNotFound, fmt.Errorf("no link select result found"))
 	}
 	link = xctx.LinkPrefix + link
 	req, err = http.NewRequest(http.MethodGet, link, nil)
@@ -0,0 +1,122 @@
+package searcher
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimitRule 令牌桶的限流规则
+type RateLimitRule struct {
+	Rate  float64 //每秒生成的令牌数, 即平均每秒允许的请求数
+	Burst int     //桶的容量, 即允许的突发请求数, 小于1时视为1
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rule RateLimitRule, now time.Time) *tokenBucket {
+	burst := float64(rule.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rule.Rate, burst: burst, tokens: burst, last: now}
+}
+
+// reserve 预定一个令牌, 返回需要等待的时长, 令牌不足时允许透支, 后来者需要等待更久
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel 取消等待时归还令牌
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	d := b.reserve(time.Now())
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+}
+
+// RateLimiter 按插件名及请求域名限流, 同一个请求需要同时满足插件及域名的规则
+type RateLimiter struct {
+	plugins map[string]*tokenBucket
+	hosts   map[string]*tokenBucket
+}
+
+// NewRateLimiter 创建限流器, rate小于等于0的规则会被忽略
+func NewRateLimiter(plugins map[string]RateLimitRule, hosts map[string]RateLimitRule) *RateLimiter {
+	now := time.Now()
+	build := func(rules map[string]RateLimitRule) map[string]*tokenBucket {
+		rs := make(map[string]*tokenBucket, len(rules))
+		for k, rule := range rules {
+			if rule.Rate <= 0 {
+				continue
+			}
+			rs[k] = newTokenBucket(rule, now)
+		}
+		return rs
+	}
+	return &RateLimiter{plugins: build(plugins), hosts: build(hosts)}
+}
+
+// Wait 等待直到插件及域名都允许发起请求, ctx被取消时返回错误
+func (l *RateLimiter) Wait(ctx context.Context, plugin string, host string) error {
+	if err := l.WaitPlugin(ctx, plugin); err != nil {
+		return err
+	}
+	return l.WaitHost(ctx, host)
+}
+
+// WaitPlugin 等待直到插件允许发起请求
+func (l *RateLimiter) WaitPlugin(ctx context.Context, plugin string) error {
+	if l == nil {
+		return nil
+	}
+	if b, ok := l.plugins[plugin]; ok {
+		return b.wait(ctx)
+	}
+	return nil
+}
+
+// WaitHost 等待直到域名允许发起请求
+func (l *RateLimiter) WaitHost(ctx context.Context, host string) error {
+	if l == nil {
+		return nil
+	}
+	if b, ok := l.hosts[host]; ok {
+		return b.wait(ctx)
+	}
+	return nil
+}
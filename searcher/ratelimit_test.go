@@ -0,0 +1,50 @@
+package searcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucketReserve(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(RateLimitRule{Rate: 2, Burst: 2}, now)
+	assert.Equal(t, time.Duration(0), b.reserve(now))
+	assert.Equal(t, time.Duration(0), b.reserve(now))
+	assert.Equal(t, 500*time.Millisecond, b.reserve(now))
+	assert.Equal(t, time.Second, b.reserve(now))
+	//2秒后补充4个令牌, 偿还透支后剩余2个
+	assert.Equal(t, time.Duration(0), b.reserve(now.Add(2*time.Second)))
+	assert.Equal(t, time.Duration(0), b.reserve(now.Add(2*time.Second)))
+	assert.Equal(t, 500*time.Millisecond, b.reserve(now.Add(2*time.Second)))
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l := NewRateLimiter(
+		map[string]RateLimitRule{"javdb": {Rate: 20}},
+		map[string]RateLimitRule{"javbus.com": {Rate: 20, Burst: 1}, "ignore.com": {Rate: 0}},
+	)
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, l.Wait(ctx, "javdb", "javdb.com"))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	//未配置规则的插件及域名不限流
+	start = time.Now()
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, l.Wait(ctx, "airav", "ignore.com"))
+	}
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+	//等待过程中取消
+	assert.NoError(t, l.Wait(ctx, "airav", "javbus.com"))
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	l2 := NewRateLimiter(nil, map[string]RateLimitRule{"slow.com": {Rate: 0.1}})
+	assert.NoError(t, l2.Wait(cctx, "x", "slow.com"))
+	assert.Error(t, l2.Wait(cctx, "x", "slow.com"))
+	var nilLimiter *RateLimiter
+	assert.NoError(t, nilLimiter.Wait(ctx, "x", "y"))
+}
@@ -0,0 +1,69 @@
+package searcher
+
+import (
+	"context"
+	"strings"
+
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+type pluginChainKeyType struct{}
+type detailURLKeyType struct{}
+
+var (
+	defaultPluginChainKey = pluginChainKeyType{}
+	defaultDetailURLKey   = detailURLKeyType{}
+)
+
+// WithPluginChain 指定本次搜索使用的插件及顺序, 会覆盖配置中的插件链
+func WithPluginChain(ctx context.Context, plugins []string) context.Context {
+	if len(plugins) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, defaultPluginChainKey, plugins)
+}
+
+// WithDetailURLs 为指定插件固定详情页地址, key为插件名, 插件将直接解析该页面而不再执行搜索
+func WithDetailURLs(ctx context.Context, m map[string]string) context.Context {
+	if len(m) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, defaultDetailURLKey, m)
+}
+
+func pinnedDetailURL(ctx context.Context, plugin string) (string, bool) {
+	m, ok := ctx.Value(defaultDetailURLKey).(map[string]string)
+	if !ok {
+		return "", false
+	}
+	link, ok := m[plugin]
+	if !ok || len(link) == 0 {
+		return "", false
+	}
+	return link, true
+}
+
+// selectPluginChain 如果ctx中指定了插件链, 则从候选的searcher中按名字挑选, 否则返回def
+func selectPluginChain(ctx context.Context, def []ISearcher, candidates ...[]ISearcher) []ISearcher {
+	names, ok := ctx.Value(defaultPluginChainKey).([]string)
+	if !ok {
+		return def
+	}
+	all := make(map[string]ISearcher)
+	for _, ss := range append([][]ISearcher{def}, candidates...) {
+		for _, s := range ss {
+			all[strings.ToLower(s.Name())] = s
+		}
+	}
+	rs := make([]ISearcher, 0, len(names))
+	for _, name := range names {
+		s, ok := all[strings.ToLower(name)]
+		if !ok {
+			logutil.GetLogger(ctx).Warn("plugin in override chain not found, skip", zap.String("plugin", name))
+			continue
+		}
+		rs = append(rs, s)
+	}
+	return rs
+}
@@ -0,0 +1,59 @@
+package searcher
+
+import (
+	"context"
+	"testing"
+	"yamdc/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type namedSearcher struct {
+	name string
+}
+
+func (s *namedSearcher) Name() string {
+	return s.name
+}
+
+func (s *namedSearcher) Search(ctx context.Context, number *model.Number) (*model.AvMeta, bool, error) {
+	return &model.AvMeta{Number: number.GetNumberID(), Title: s.name}, true, nil
+}
+
+func names(ss []ISearcher) []string {
+	rs := make([]string, 0, len(ss))
+	for _, s := range ss {
+		rs = append(rs, s.Name())
+	}
+	return rs
+}
+
+func TestSelectPluginChain(t *testing.T) {
+	def := []ISearcher{&namedSearcher{name: "javbus"}, &namedSearcher{name: "javdb"}}
+	cat := []ISearcher{&namedSearcher{name: "fc2"}}
+	ctx := context.Background()
+	assert.Equal(t, []string{"javbus", "javdb"}, names(selectPluginChain(ctx, def, cat)))
+	ctx = WithPluginChain(ctx, []string{"FC2", "unknown", "javdb"})
+	assert.Equal(t, []string{"fc2", "javdb"}, names(selectPluginChain(ctx, def, cat)))
+}
+
+func TestCategorySearcherWithPluginChain(t *testing.T) {
+	s := NewCategorySearcher(
+		[]ISearcher{&namedSearcher{name: "javbus"}},
+		map[model.Category][]ISearcher{model.CatFC2: {&namedSearcher{name: "fc2"}}},
+	)
+	n := &model.Number{NumberId: "ABC-123", Cat: model.CatDefault}
+	meta, ok, err := s.Search(WithPluginChain(context.Background(), []string{"fc2"}), n)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "fc2", meta.Title)
+}
+
+func TestPinnedDetailURL(t *testing.T) {
+	ctx := WithDetailURLs(context.Background(), map[string]string{"javbus": "https://example.com/ABC-123"})
+	link, ok := pinnedDetailURL(ctx, "javbus")
+	assert.True(t, ok)
+	assert.Equal(t, "https://example.com/ABC-123", link)
+	_, ok = pinnedDetailURL(ctx, "javdb")
+	assert.False(t, ok)
+}
@@ -0,0 +1,58 @@
+package searcher
+
+import (
+	"context"
+	"sync"
+	"yamdc/searcher/plugin/api"
+)
+
+type searchTraceKeyType struct{}
+
+var defaultSearchTraceKey = searchTraceKeyType{}
+
+// SearchAttempt 单个插件的搜索结果
+type SearchAttempt struct {
+	Plugin string `json:"plugin"`
+	Found  bool   `json:"found"`
+	Error  string `json:"error,omitempty"`
+	Kind   string `json:"kind,omitempty"` //错误类型, 见api.ErrKindXXX
+}
+
+// SearchTrace 记录一次搜索过程中尝试过的插件
+type SearchTrace struct {
+	mu       sync.Mutex
+	attempts []*SearchAttempt
+}
+
+// WithSearchTrace 在ctx中挂载搜索记录, 搜索完成后可以通过返回的SearchTrace获取尝试过的插件
+func WithSearchTrace(ctx context.Context) (context.Context, *SearchTrace) {
+	st := &SearchTrace{}
+	return context.WithValue(ctx, defaultSearchTraceKey, st), st
+}
+
+func (t *SearchTrace) add(item *SearchAttempt) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.attempts = append(t.attempts, item)
+}
+
+func (t *SearchTrace) Attempts() []*SearchAttempt {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rs := make([]*SearchAttempt, len(t.attempts))
+	copy(rs, t.attempts)
+	return rs
+}
+
+func recordSearchAttempt(ctx context.Context, plugin string, found bool, err error) {
+	st, ok := ctx.Value(defaultSearchTraceKey).(*SearchTrace)
+	if !ok {
+		return
+	}
+	item := &SearchAttempt{Plugin: plugin, Found: found}
+	if err != nil {
+		item.Error = err.Error()
+		item.Kind = api.ErrorKind(err)
+	}
+	st.add(item)
+}
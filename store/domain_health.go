@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// DomainHealth 站点域名的健康状态, 用于在多个镜像间选择可用的域名
+type DomainHealth struct {
+	Domain             string
+	Success            int64
+	Failure            int64
+	ConsecutiveFailure int64
+	AvgLatency         int64 //毫秒, 仅统计成功的请求
+	LastFailure        int64 //毫秒
+	LastError          string
+	UpdateAt           int64 //毫秒
+}
+
+type IDomainHealthStore interface {
+	GetDomainHealth(ctx context.Context, domain string) (*DomainHealth, bool, error)
+	PutDomainHealth(ctx context.Context, h *DomainHealth) error
+	ListDomainHealth(ctx context.Context) ([]*DomainHealth, error)
+}
+
+func (s *sqliteStore) GetDomainHealth(ctx context.Context, domain string) (*DomainHealth, bool, error) {
+	h := &DomainHealth{}
+	err := s.db.QueryRowContext(ctx, "SELECT domain, success, failure, consecutive_failure, avg_latency, last_failure, last_error, update_at FROM domain_health_tab WHERE domain = ?", domain).
+		Scan(&h.Domain, &h.Success, &h.Failure, &h.ConsecutiveFailure, &h.AvgLatency, &h.LastFailure, &h.LastError, &h.UpdateAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return h, true, nil
+}
+
+func (s *sqliteStore) PutDomainHealth(ctx context.Context, h *DomainHealth) error {
+	_, err := s.db.ExecContext(ctx, "INSERT OR REPLACE INTO domain_health_tab (domain, success, failure, consecutive_failure, avg_latency, last_failure, last_error, update_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		h.Domain, h.Success, h.Failure, h.ConsecutiveFailure, h.AvgLatency, h.LastFailure, h.LastError, h.UpdateAt)
+	return err
+}
+
+func (s *sqliteStore) ListDomainHealth(ctx context.Context) ([]*DomainHealth, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT domain, success, failure, consecutive_failure, avg_latency, last_failure, last_error, update_at FROM domain_health_tab ORDER BY domain")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rs := make([]*DomainHealth, 0, 16)
+	for rows.Next() {
+		h := &DomainHealth{}
+		if err := rows.Scan(&h.Domain, &h.Success, &h.Failure, &h.ConsecutiveFailure, &h.AvgLatency, &h.LastFailure, &h.LastError, &h.UpdateAt); err != nil {
+			return nil, err
+		}
+		rs = append(rs, h)
+	}
+	return rs, rows.Err()
+}
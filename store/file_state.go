@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+const (
+	FileStatusSuccess  = "success"
+	FileStatusNotFound = "not_found"
+	FileStatusRetry    = "retry"  //网络错误, 被站点拦截或者限流等临时错误, 到达重试时间后重新处理
+	FileStatusFailed   = "failed" //元数据不完整等非临时错误, 文件未变化时不再处理
+)
+
+// FileState 扫描目录中单个文件的处理状态, 文件大小或者修改时间变化后视为新文件
+type FileState struct {
+	Path        string
+	Size        int64
+	ModTime     int64 //纳秒
+	Status      string
+	Number      string
+	Attempts    int
+	LastError   string
+	NextRetryAt int64 //毫秒, 0表示下次运行时直接重试
+	UpdateAt    int64 //毫秒
+}
+
+type IFileStateStore interface {
+	GetFileState(ctx context.Context, path string) (*FileState, bool, error)
+	PutFileState(ctx context.Context, st *FileState) error
+	DeleteFileState(ctx context.Context, path string) error
+	ListFileStates(ctx context.Context, status string, limit int) ([]*FileState, error)
+}
+
+func (s *sqliteStore) GetFileState(ctx context.Context, path string) (*FileState, bool, error) {
+	st := &FileState{}
+	err := s.db.QueryRowContext(ctx, "SELECT path, size, mod_time, status, number, attempts, last_error, next_retry_at, update_at FROM file_state_tab WHERE path = ?", path).
+		Scan(&st.Path, &st.Size, &st.ModTime, &st.Status, &st.Number, &st.Attempts, &st.LastError, &st.NextRetryAt, &st.UpdateAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return st, true, nil
+}
+
+func (s *sqliteStore) PutFileState(ctx context.Context, st *FileState) error {
+	_, err := s.db.ExecContext(ctx, "INSERT OR REPLACE INTO file_state_tab (path, size, mod_time, status, number, attempts, last_error, next_retry_at, update_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		st.Path, st.Size, st.ModTime, st.Status, st.Number, st.Attempts, st.LastError, st.NextRetryAt, st.UpdateAt)
+	return err
+}
+
+func (s *sqliteStore) DeleteFileState(ctx context.Context, path string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM file_state_tab WHERE path = ?", path)
+	return err
+}
+
+// ListFileStates 按更新时间倒序列出文件状态, status为空时返回全部状态
+func (s *sqliteStore) ListFileStates(ctx context.Context, status string, limit int) ([]*FileState, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT path, size, mod_time, status, number, attempts, last_error, next_retry_at, update_at FROM file_state_tab WHERE (? = '' or status = ?) ORDER BY update_at DESC LIMIT ?", status, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rs := make([]*FileState, 0, 16)
+	for rows.Next() {
+		st := &FileState{}
+		if err := rows.Scan(&st.Path, &st.Size, &st.ModTime, &st.Status, &st.Number, &st.Attempts, &st.LastError, &st.NextRetryAt, &st.UpdateAt); err != nil {
+			return nil, err
+		}
+		rs = append(rs, st)
+	}
+	return rs, rows.Err()
+}
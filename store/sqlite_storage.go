@@ -16,14 +16,38 @@ type sqliteStore struct {
 }
 
 func (s *sqliteStore) init() error {
-	createTable := `CREATE TABLE IF NOT EXISTS cache_tab (
+	sqls := []string{
+		`CREATE TABLE IF NOT EXISTS cache_tab (
         key TEXT PRIMARY KEY,
         value BLOB,
         expire_at INTEGER
-    );`
-	_, err := s.db.Exec(createTable)
-	if err != nil {
-		return err
+    );`,
+		`CREATE TABLE IF NOT EXISTS file_state_tab (
+        path TEXT PRIMARY KEY,
+        size INTEGER,
+        mod_time INTEGER,
+        status TEXT,
+        number TEXT,
+        attempts INTEGER,
+        last_error TEXT,
+        next_retry_at INTEGER,
+        update_at INTEGER
+    );`,
+		`CREATE TABLE IF NOT EXISTS domain_health_tab (
+        domain TEXT PRIMARY KEY,
+        success INTEGER,
+        failure INTEGER,
+        consecutive_failure INTEGER,
+        avg_latency INTEGER,
+        last_failure INTEGER,
+        last_error TEXT,
+        update_at INTEGER
+    );`,
+	}
+	for _, item := range sqls {
+		if _, err := s.db.Exec(item); err != nil {
+			return err
+		}
 	}
 	return nil
 }
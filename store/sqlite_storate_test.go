@@ -52,3 +52,57 @@ func TestStore(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "aaa", string(val))
 }
+
+func TestFileState(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cache.db")
+	s, err := NewSqliteStorage(file)
+	assert.NoError(t, err)
+	fs, ok := s.(IFileStateStore)
+	assert.True(t, ok)
+	ctx := context.Background()
+	_, exist, err := fs.GetFileState(ctx, "/scan/a.mp4")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+	assert.NoError(t, fs.PutFileState(ctx, &FileState{Path: "/scan/a.mp4", Size: 10, ModTime: 20, Status: FileStatusNotFound, Number: "ABC-123", Attempts: 1, LastError: "not found", NextRetryAt: 30, UpdateAt: 1}))
+	assert.NoError(t, fs.PutFileState(ctx, &FileState{Path: "/scan/b.mp4", Status: FileStatusSuccess, UpdateAt: 2}))
+	st, exist, err := fs.GetFileState(ctx, "/scan/a.mp4")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, "ABC-123", st.Number)
+	assert.Equal(t, 1, st.Attempts)
+	assert.Equal(t, int64(30), st.NextRetryAt)
+	lst, err := fs.ListFileStates(ctx, "", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(lst))
+	assert.Equal(t, "/scan/b.mp4", lst[0].Path)
+	lst, err = fs.ListFileStates(ctx, FileStatusNotFound, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(lst))
+	assert.NoError(t, fs.DeleteFileState(ctx, "/scan/a.mp4"))
+	_, exist, err = fs.GetFileState(ctx, "/scan/a.mp4")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+}
+
+func TestDomainHealth(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cache.db")
+	s, err := NewSqliteStorage(file)
+	assert.NoError(t, err)
+	hs, ok := s.(IDomainHealthStore)
+	assert.True(t, ok)
+	ctx := context.Background()
+	_, exist, err := hs.GetDomainHealth(ctx, "a.com")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+	assert.NoError(t, hs.PutDomainHealth(ctx, &DomainHealth{Domain: "b.com", Success: 3, AvgLatency: 100, UpdateAt: 1}))
+	assert.NoError(t, hs.PutDomainHealth(ctx, &DomainHealth{Domain: "a.com", Failure: 2, ConsecutiveFailure: 2, LastFailure: 5, LastError: "timeout", UpdateAt: 5}))
+	h, exist, err := hs.GetDomainHealth(ctx, "a.com")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, int64(2), h.ConsecutiveFailure)
+	assert.Equal(t, "timeout", h.LastError)
+	lst, err := hs.ListDomainHealth(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(lst))
+	assert.Equal(t, "a.com", lst[0].Domain)
+}
@@ -0,0 +1,204 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"yamdc/utils"
+)
+
+type moveTransfer struct{}
+
+func (t *moveTransfer) Name() string {
+	return string(ModeMove)
+}
+
+func (t *moveTransfer) Transfer(ctx context.Context, src string, dst string) (Mode, error) {
+	if err := utils.NewFileManager().Move(src, dst); err != nil {
+		return ModeMove, fmt.Errorf("move file failed, src:%s, dst:%s, err:%w", src, dst, err)
+	}
+	return ModeMove, nil
+}
+
+type copyTransfer struct{}
+
+func (t *copyTransfer) Name() string {
+	return string(ModeCopy)
+}
+
+func fileChecksum(f string) (string, error) {
+	file, err := os.Open(f)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (t *copyTransfer) copyFile(src string, tmp string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", fmt.Errorf("open src failed, err:%w", err)
+	}
+	defer in.Close()
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", fmt.Errorf("create dst failed, err:%w", err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(out, io.TeeReader(in, h)); err != nil {
+		_ = out.Close()
+		return "", fmt.Errorf("copy data failed, err:%w", err)
+	}
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return "", fmt.Errorf("sync dst failed, err:%w", err)
+	}
+	if err := out.Close(); err != nil {
+		return "", fmt.Errorf("close dst failed, err:%w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (t *copyTransfer) Transfer(ctx context.Context, src string, dst string) (Mode, error) {
+	if _, err := os.Stat(dst); err == nil {
+		return ModeCopy, fmt.Errorf("copy file failed, dst:%s already exists", dst)
+	}
+	fi, err := os.Stat(src)
+	if err != nil {
+		return ModeCopy, fmt.Errorf("stat src failed, src:%s, err:%w", src, err)
+	}
+	//先写入临时文件, 校验通过后再重命名, 避免留下不完整的文件
+	tmp := dst + ".yamdc-tmp"
+	srcSum, err := t.copyFile(src, tmp)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return ModeCopy, fmt.Errorf("copy file failed, src:%s, dst:%s, err:%w", src, dst, err)
+	}
+	dstSum, err := fileChecksum(tmp)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return ModeCopy, fmt.Errorf("read copied file checksum failed, dst:%s, err:%w", dst, err)
+	}
+	if srcSum != dstSum {
+		_ = os.Remove(tmp)
+		return ModeCopy, fmt.Errorf("checksum mismatch after copy, src:%s, src_sum:%s, dst_sum:%s", src, srcSum, dstSum)
+	}
+	_ = os.Chtimes(tmp, fi.ModTime(), fi.ModTime())
+	if err := os.Rename(tmp, dst); err != nil {
+		_ = os.Remove(tmp)
+		return ModeCopy, fmt.Errorf("rename copied file failed, dst:%s, err:%w", dst, err)
+	}
+	return ModeCopy, nil
+}
+
+type hardlinkTransfer struct{}
+
+func (t *hardlinkTransfer) Name() string {
+	return string(ModeHardlink)
+}
+
+func (t *hardlinkTransfer) Transfer(ctx context.Context, src string, dst string) (Mode, error) {
+	err := os.Link(src, dst)
+	if err == nil {
+		return ModeHardlink, nil
+	}
+	if errors.Is(err, os.ErrExist) && isSameFile(src, dst) {
+		return ModeHardlink, nil
+	}
+	return ModeHardlink, fmt.Errorf("create hardlink failed, src:%s, dst:%s, err:%w", src, dst, err)
+}
+
+func isSameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ai, bi)
+}
+
+type symlinkTransfer struct{}
+
+func (t *symlinkTransfer) Name() string {
+	return string(ModeSymlink)
+}
+
+func (t *symlinkTransfer) Transfer(ctx context.Context, src string, dst string) (Mode, error) {
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return ModeSymlink, fmt.Errorf("resolve abs path failed, src:%s, err:%w", src, err)
+	}
+	if err := createSymlink(abs, dst); err != nil {
+		return ModeSymlink, fmt.Errorf("create symlink failed, src:%s, dst:%s, err:%w", src, dst, err)
+	}
+	return ModeSymlink, nil
+}
+
+type relSymlinkTransfer struct{}
+
+func (t *relSymlinkTransfer) Name() string {
+	return string(ModeRelSymlink)
+}
+
+func (t *relSymlinkTransfer) Transfer(ctx context.Context, src string, dst string) (Mode, error) {
+	absSrc, err := filepath.Abs(src)
+	if err != nil {
+		return ModeRelSymlink, fmt.Errorf("resolve abs path failed, src:%s, err:%w", src, err)
+	}
+	absDst, err := filepath.Abs(dst)
+	if err != nil {
+		return ModeRelSymlink, fmt.Errorf("resolve abs path failed, dst:%s, err:%w", dst, err)
+	}
+	rel, err := filepath.Rel(filepath.Dir(absDst), absSrc)
+	if err != nil {
+		return ModeRelSymlink, fmt.Errorf("build relative path failed, src:%s, dst:%s, err:%w", src, dst, err)
+	}
+	if err := createSymlink(rel, dst); err != nil {
+		return ModeRelSymlink, fmt.Errorf("create relative symlink failed, src:%s, dst:%s, err:%w", src, dst, err)
+	}
+	return ModeRelSymlink, nil
+}
+
+func createSymlink(target string, dst string) error {
+	err := os.Symlink(target, dst)
+	if err == nil {
+		return nil
+	}
+	//已经存在指向相同位置的链接, 视为成功
+	if errors.Is(err, os.ErrExist) {
+		if link, lerr := os.Readlink(dst); lerr == nil && link == target {
+			return nil
+		}
+	}
+	return err
+}
+
+type reflinkTransfer struct{}
+
+func (t *reflinkTransfer) Name() string {
+	return string(ModeReflink)
+}
+
+func (t *reflinkTransfer) Transfer(ctx context.Context, src string, dst string) (Mode, error) {
+	if _, err := os.Stat(dst); err == nil {
+		return ModeReflink, fmt.Errorf("reflink file failed, dst:%s already exists", dst)
+	}
+	if err := reflink(src, dst); err != nil {
+		_ = os.Remove(dst)
+		return ModeReflink, fmt.Errorf("reflink file failed, src:%s, dst:%s, err:%w", src, dst, err)
+	}
+	return ModeReflink, nil
+}
@@ -0,0 +1,27 @@
+//go:build linux
+// +build linux
+
+package transfer
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func reflink(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlFileClone(int(out.Fd()), int(in.Fd())); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package transfer
+
+func reflink(src string, dst string) error {
+	return ErrReflinkNotSupported
+}
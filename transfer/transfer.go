@@ -0,0 +1,107 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+type Mode string
+
+const (
+	ModeMove       Mode = "move"       //移动文件, 跨设备时自动降级为复制后删除
+	ModeCopy       Mode = "copy"       //复制文件并校验checksum, 源文件保持不变
+	ModeHardlink   Mode = "hardlink"   //硬链接, 适用于需要继续做种的场景
+	ModeSymlink    Mode = "symlink"    //绝对路径软链接
+	ModeRelSymlink Mode = "relsymlink" //相对路径软链接, 适用于容器中挂载路径与宿主机不一致的场景
+	ModeReflink    Mode = "reflink"    //写时复制, 需要文件系统支持(btrfs, xfs等)
+)
+
+var ErrReflinkNotSupported = errors.New("reflink not supported")
+
+type ITransfer interface {
+	Name() string
+	// Transfer 将src转移到dst, 返回实际使用的转移方式(发生降级时与Name不一致)
+	Transfer(ctx context.Context, src string, dst string) (Mode, error)
+}
+
+var mp = map[Mode]ITransfer{
+	ModeMove:       &moveTransfer{},
+	ModeCopy:       &copyTransfer{},
+	ModeHardlink:   &hardlinkTransfer{},
+	ModeSymlink:    &symlinkTransfer{},
+	ModeRelSymlink: &relSymlinkTransfer{},
+	ModeReflink:    &reflinkTransfer{},
+}
+
+// Create 创建转移器, fallback不为空时, 硬链接/reflink因跨设备或者文件系统不支持而失败后, 使用fallback重试
+func Create(mode string, fallback string) (ITransfer, error) {
+	t, ok := mp[Mode(strings.ToLower(mode))]
+	if !ok {
+		return nil, fmt.Errorf("transfer mode:%s not found, supported:%v", mode, Modes())
+	}
+	//仅硬链接及reflink存在跨设备/文件系统不支持的问题, 其他方式无需降级
+	if len(fallback) == 0 || (t.Name() != string(ModeHardlink) && t.Name() != string(ModeReflink)) {
+		return t, nil
+	}
+	fb, ok := mp[Mode(strings.ToLower(fallback))]
+	if !ok {
+		return nil, fmt.Errorf("fallback transfer mode:%s not found, supported:%v", fallback, Modes())
+	}
+	if fb.Name() == t.Name() {
+		return t, nil
+	}
+	return &fallbackTransfer{primary: t, fallback: fb}, nil
+}
+
+func MustCreate(mode string, fallback string) ITransfer {
+	t, err := Create(mode, fallback)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func Modes() []string {
+	rs := make([]string, 0, len(mp))
+	for k := range mp {
+		rs = append(rs, string(k))
+	}
+	sort.Strings(rs)
+	return rs
+}
+
+type fallbackTransfer struct {
+	primary  ITransfer
+	fallback ITransfer
+}
+
+func (t *fallbackTransfer) Name() string {
+	return t.primary.Name()
+}
+
+func isFallbackError(err error) bool {
+	return errors.Is(err, syscall.EXDEV) ||
+		errors.Is(err, ErrReflinkNotSupported) ||
+		errors.Is(err, errors.ErrUnsupported) ||
+		errors.Is(err, syscall.EOPNOTSUPP) ||
+		errors.Is(err, syscall.EINVAL)
+}
+
+func (t *fallbackTransfer) Transfer(ctx context.Context, src string, dst string) (Mode, error) {
+	mode, err := t.primary.Transfer(ctx, src, dst)
+	if err == nil {
+		return mode, nil
+	}
+	if !isFallbackError(err) {
+		return mode, err
+	}
+	mode, ferr := t.fallback.Transfer(ctx, src, dst)
+	if ferr != nil {
+		return mode, fmt.Errorf("fallback to %s failed, err:%w, primary err:%v", t.fallback.Name(), ferr, err)
+	}
+	return mode, nil
+}
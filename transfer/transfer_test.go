@@ -0,0 +1,94 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func prepareTransferTest(t *testing.T) (string, string) {
+	root := t.TempDir()
+	src := filepath.Join(root, "scan", "ABC-123.mp4")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(src), 0755))
+	assert.NoError(t, os.WriteFile(src, []byte("movie data"), 0644))
+	dst := filepath.Join(root, "save", "ABC-123", "ABC-123.mp4")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(dst), 0755))
+	return src, dst
+}
+
+func TestTransferModes(t *testing.T) {
+	ctx := context.Background()
+	for _, mode := range []Mode{ModeMove, ModeCopy, ModeHardlink, ModeSymlink, ModeRelSymlink} {
+		src, dst := prepareTransferTest(t)
+		tf, err := Create(string(mode), "")
+		assert.NoError(t, err)
+		used, err := tf.Transfer(ctx, src, dst)
+		assert.NoError(t, err, "mode:%s", mode)
+		assert.Equal(t, mode, used)
+		raw, err := os.ReadFile(dst)
+		assert.NoError(t, err)
+		assert.Equal(t, "movie data", string(raw))
+		_, err = os.Stat(src)
+		assert.Equal(t, mode == ModeMove, os.IsNotExist(err), "mode:%s", mode)
+	}
+}
+
+func TestRelSymlink(t *testing.T) {
+	src, dst := prepareTransferTest(t)
+	_, err := (&relSymlinkTransfer{}).Transfer(context.Background(), src, dst)
+	assert.NoError(t, err)
+	link, err := os.Readlink(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("..", "..", "scan", "ABC-123.mp4"), link)
+	//重复创建相同的链接视为成功
+	_, err = (&relSymlinkTransfer{}).Transfer(context.Background(), src, dst)
+	assert.NoError(t, err)
+}
+
+func TestCopyDstExist(t *testing.T) {
+	src, dst := prepareTransferTest(t)
+	assert.NoError(t, os.WriteFile(dst, []byte("exist"), 0644))
+	_, err := (&copyTransfer{}).Transfer(context.Background(), src, dst)
+	assert.Error(t, err)
+}
+
+type crossDeviceTransfer struct{}
+
+func (t *crossDeviceTransfer) Name() string {
+	return string(ModeHardlink)
+}
+
+func (t *crossDeviceTransfer) Transfer(ctx context.Context, src string, dst string) (Mode, error) {
+	return ModeHardlink, fmt.Errorf("create hardlink failed, err:%w", &os.LinkError{Op: "link", Old: src, New: dst, Err: syscall.EXDEV})
+}
+
+func TestFallback(t *testing.T) {
+	src, dst := prepareTransferTest(t)
+	tf := &fallbackTransfer{primary: &crossDeviceTransfer{}, fallback: &copyTransfer{}}
+	used, err := tf.Transfer(context.Background(), src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, ModeCopy, used)
+	assert.Equal(t, string(ModeHardlink), tf.Name())
+	_, err = os.Stat(dst)
+	assert.NoError(t, err)
+}
+
+func TestCreate(t *testing.T) {
+	_, err := Create("unknown", "")
+	assert.Error(t, err)
+	_, err = Create("hardlink", "unknown")
+	assert.Error(t, err)
+	tf, err := Create("HARDLINK", "copy")
+	assert.NoError(t, err)
+	_, ok := tf.(*fallbackTransfer)
+	assert.True(t, ok)
+	tf, err = Create("move", "copy")
+	assert.NoError(t, err)
+	_, ok = tf.(*fallbackTransfer)
+	assert.False(t, ok)
+}
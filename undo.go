@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+	"yamdc/config"
+	"yamdc/journal"
+)
+
+func journalPath(c *config.Config) string {
+	return filepath.Join(c.DataDir, "journal", "journal.db")
+}
+
+func runSubCommand(c *config.Config, args []string) error {
+	switch args[0] {
+	case "undo":
+		return runUndo(c, args[1:])
+	default:
+		return fmt.Errorf("unknown sub command:%s", args[0])
+	}
+}
+
+func runUndo(c *config.Config, args []string) error {
+	fs := flag.NewFlagSet("undo", flag.ContinueOnError)
+	runID := fs.String("run", "", "id of the run to undo")
+	list := fs.Bool("list", false, "list recent runs")
+	force := fs.Bool("force", false, "undo even if files were modified after the run")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	j, err := journal.NewSqliteJournal(journalPath(c))
+	if err != nil {
+		return fmt.Errorf("open journal failed, err:%w", err)
+	}
+	ctx := context.Background()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+	if *list {
+		runs, err := j.ListRuns(ctx, 20)
+		if err != nil {
+			return fmt.Errorf("list runs failed, err:%w", err)
+		}
+		fmt.Fprintln(tw, "RUN_ID\tSTATUS\tSTART_AT\tACTIONS")
+		for _, r := range runs {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", r.ID, r.Status, time.UnixMilli(r.StartAt).Format(time.DateTime), r.ActionCount)
+		}
+		return nil
+	}
+	if len(*runID) == 0 {
+		return fmt.Errorf("no run id specified, use --run <id>, or --list to show recent runs")
+	}
+	res, err := journal.Undo(ctx, j, *runID, *force)
+	if err != nil {
+		return fmt.Errorf("undo run failed, err:%w", err)
+	}
+	fmt.Fprintln(tw, "ACTION\tSTATUS\tDST\tREASON")
+	for _, item := range res.Items {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", item.Action.Type, item.Status, item.Action.Dst, item.Reason)
+	}
+	if res.Skipped > 0 {
+		return fmt.Errorf("%d action(s) can not be undone, see details above", res.Skipped)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CompileGlob 将glob规则转换为正则表达式, 路径分隔符统一使用`/`
+// 支持的语法:
+// - `*` 匹配除`/`外的任意字符
+// - `?` 匹配除`/`外的单个字符
+// - `**` 匹配任意层级的目录(包括0层), 例如 `**/sample/**`
+// - `[...]` 字符集合
+func CompileGlob(pattern string) (*regexp.Regexp, error) {
+	sb := strings.Builder{}
+	sb.WriteString("^")
+	rs := []rune(pattern)
+	for i := 0; i < len(rs); i++ {
+		c := rs[i]
+		switch c {
+		case '*':
+			if i+1 < len(rs) && rs[i+1] == '*' {
+				i++
+				//`**/` 可以匹配0层或者多层目录
+				if i+1 < len(rs) && rs[i+1] == '/' {
+					i++
+					sb.WriteString("(?:.*/)?")
+					continue
+				}
+				sb.WriteString(".*")
+				continue
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			//按rune查找, 避免字符集合中包含多字节字符时下标错位
+			end := -1
+			for j := i + 1; j < len(rs); j++ {
+				if rs[j] == ']' {
+					end = j
+					break
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed char class in glob:%s", pattern)
+			}
+			class := string(rs[i+1 : end])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i = end
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileGlob(t *testing.T) {
+	tsts := []struct {
+		pattern string
+		in      string
+		match   bool
+	}{
+		{"**/sample/**", "sample/a.mp4", true},
+		{"**/sample/**", "a/b/sample/a.mp4", true},
+		{"**/sample/**", "a/samples/a.mp4", false},
+		{"@eaDir", "@eaDir", true},
+		{"*.part", "abc.part", true},
+		{"*.part", "a/abc.part", false},
+		{"abc-???.mp4", "abc-123.mp4", true},
+		{"abc-[0-9]*.mp4", "abc-1x.mp4", true},
+		{"abc-[!0-9]*.mp4", "abc-1x.mp4", false},
+		{"a.b", "axb", false},
+		{"[中文]*.mp4", "中abc.mp4", true},
+		{"[中文]*.mp4", "英abc.mp4", false},
+		{"[!中文]-[ab]字.mp4", "日-b字.mp4", true},
+		{"[!中文]-[ab]字.mp4", "中-b字.mp4", false},
+	}
+	for _, tst := range tsts {
+		re, err := CompileGlob(tst.pattern)
+		assert.NoError(t, err)
+		assert.Equal(t, tst.match, re.MatchString(tst.in), "pattern:%s, in:%s", tst.pattern, tst.in)
+	}
+	_, err := CompileGlob("abc[")
+	assert.Error(t, err)
+}
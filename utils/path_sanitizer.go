@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// 在windows/smb/ntfs下不允许出现在文件名中的字符, 使用全角字符替换, 尽量保持可读性
+var forbiddenPathChars = map[rune]string{
+	'/':  "／",
+	'\\': "＼",
+	':':  "：",
+	'*':  "＊",
+	'?':  "？",
+	'"':  "＂",
+	'<':  "＜",
+	'>':  "＞",
+	'|':  "｜",
+}
+
+// windows保留的设备名, 不区分大小写, 带扩展名同样不可用, 例如: CON.txt
+var reservedPathNames = map[string]struct{}{
+	"CON": {}, "PRN": {}, "AUX": {}, "NUL": {},
+	"COM1": {}, "COM2": {}, "COM3": {}, "COM4": {}, "COM5": {}, "COM6": {}, "COM7": {}, "COM8": {}, "COM9": {},
+	"LPT1": {}, "LPT2": {}, "LPT3": {}, "LPT4": {}, "LPT5": {}, "LPT6": {}, "LPT7": {}, "LPT8": {}, "LPT9": {},
+}
+
+// ReplaceForbiddenPathChars 替换路径分隔符等不允许出现在文件名中的字符, 并移除控制字符
+func ReplaceForbiddenPathChars(s string) string {
+	sb := strings.Builder{}
+	sb.Grow(len(s))
+	for _, r := range s {
+		if r == utf8.RuneError || r < 0x20 || r == 0x7f {
+			continue
+		}
+		if rep, ok := forbiddenPathChars[r]; ok {
+			sb.WriteString(rep)
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// TruncateUTF8 按字节数截断字符串, 保证不会截断在多字节字符的中间
+func TruncateUTF8(s string, maxBytes int) string {
+	if maxBytes <= 0 || len(s) <= maxBytes {
+		return s
+	}
+	end := 0
+	for idx, r := range s {
+		size := utf8.RuneLen(r)
+		if size < 0 {
+			size = 1
+		}
+		if idx+size > maxBytes {
+			break
+		}
+		end = idx + size
+	}
+	return s[:end]
+}
+
+func trimPathComponent(s string) string {
+	//ntfs不允许以`.`或者空格结尾, 开头的空格则容易引起误操作
+	return strings.TrimLeft(strings.TrimRight(s, ". "), " ")
+}
+
+// SanitizePathComponent 清理单个路径组件, maxBytes为最大字节数, 0为不限制, 清理后为空时返回空字符串
+func SanitizePathComponent(s string, maxBytes int) string {
+	s = trimPathComponent(ReplaceForbiddenPathChars(s))
+	if len(s) == 0 {
+		return ""
+	}
+	base := s
+	if idx := strings.IndexByte(base, '.'); idx >= 0 {
+		base = base[:idx]
+	}
+	if _, ok := reservedPathNames[strings.ToUpper(strings.TrimSpace(base))]; ok {
+		s = "_" + s
+	}
+	return trimPathComponent(TruncateUTF8(s, maxBytes))
+}
+
+// SanitizeRelPath 清理以`/`分隔的相对路径, 每个组件单独清理, 空组件会被移除, 返回的路径不会包含`..`
+func SanitizeRelPath(p string, maxComponentBytes int) string {
+	items := strings.FieldsFunc(p, func(r rune) bool {
+		return r == '/' || r == '\\'
+	})
+	rs := make([]string, 0, len(items))
+	for _, item := range items {
+		item = SanitizePathComponent(item, maxComponentBytes)
+		if len(item) == 0 {
+			continue
+		}
+		rs = append(rs, item)
+	}
+	return filepath.Join(rs...)
+}
+
+// IsSubPath 判断target是否位于base之下(不包含base本身)
+func IsSubPath(base string, target string) bool {
+	rel, err := filepath.Rel(filepath.Clean(base), filepath.Clean(target))
+	if err != nil {
+		return false
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return false
+	}
+	return true
+}
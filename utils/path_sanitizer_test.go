@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizePathComponent(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"abc", "abc"},
+		{"a/b", "a／b"},
+		{"a:b?c*", "a：b？c＊"},
+		{`<a>|"b"\`, `＜a＞｜＂b＂＼`},
+		{"abc...", "abc"},
+		{"  abc . ", "abc"},
+		{"..", ""},
+		{".", ""},
+		{"a\x00b\tc", "abc"},
+		{"CON", "_CON"},
+		{"con.txt", "_con.txt"},
+		{"LPT1", "_LPT1"},
+		{"CONSOLE", "CONSOLE"},
+		{".hidden", ".hidden"},
+	}
+	for _, tst := range tests {
+		assert.Equal(t, tst.out, SanitizePathComponent(tst.in, 0), tst.in)
+	}
+}
+
+func TestSanitizePathComponentLength(t *testing.T) {
+	s := strings.Repeat("演员", 100) //600字节
+	out := SanitizePathComponent(s, 255)
+	assert.True(t, len(out) <= 255)
+	assert.True(t, utf8.ValidString(out))
+	assert.Equal(t, 255/3*3, len(out))
+	//截断后结尾为`.`或者空格时需要继续清理
+	out = SanitizePathComponent("abcd. efg", 6)
+	assert.Equal(t, "abcd", out)
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	assert.Equal(t, "ab", TruncateUTF8("ab", 0))
+	assert.Equal(t, "a", TruncateUTF8("a中", 3))
+	assert.Equal(t, "a中", TruncateUTF8("a中", 4))
+	assert.Equal(t, "", TruncateUTF8("中", 2))
+}
+
+func TestSanitizeRelPath(t *testing.T) {
+	assert.Equal(t, "a/b/c", SanitizeRelPath("a/b/c", 0))
+	assert.Equal(t, "a/b", SanitizeRelPath("/a//b/", 0))
+	assert.Equal(t, "a/b", SanitizeRelPath("../a/../b/..", 0))
+	assert.Equal(t, "a/b", SanitizeRelPath(`a\b`, 0))
+	assert.Equal(t, "2024/_NUL/x", SanitizeRelPath("2024/NUL/x.", 0))
+	assert.Equal(t, "", SanitizeRelPath("../..", 0))
+}
+
+func TestIsSubPath(t *testing.T) {
+	assert.True(t, IsSubPath("/save", "/save/a"))
+	assert.True(t, IsSubPath("/save/", "/save/a/b/../c"))
+	assert.True(t, IsSubPath("/save", "/save/..a"))
+	assert.False(t, IsSubPath("/save", "/save"))
+	assert.False(t, IsSubPath("/save", "/save/a/../.."))
+	assert.False(t, IsSubPath("/save", "/save2/a"))
+	assert.False(t, IsSubPath("/save", "/other"))
+}
@@ -0,0 +1,32 @@
+package watcher
+
+import "time"
+
+type config struct {
+	StableDuration time.Duration
+	CheckInterval  time.Duration
+	ExcludeDirs    []string
+}
+
+type Option func(c *config)
+
+// WithStableDuration 文件大小及修改时间保持不变超过该时长后, 才认为文件已经写入完成
+func WithStableDuration(d time.Duration) Option {
+	return func(c *config) {
+		c.StableDuration = d
+	}
+}
+
+// WithCheckInterval 检查文件是否写入完成的间隔
+func WithCheckInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.CheckInterval = d
+	}
+}
+
+// WithExcludeDirs 不需要监听的目录, 例如位于扫描目录下的保存目录
+func WithExcludeDirs(dirs ...string) Option {
+	return func(c *config) {
+		c.ExcludeDirs = append(c.ExcludeDirs, dirs...)
+	}
+}
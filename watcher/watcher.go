@@ -0,0 +1,206 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/xxxsen/common/logutil"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultStableDuration = 10 * time.Second
+	defaultCheckInterval  = time.Second
+)
+
+type fileState struct {
+	size       int64
+	modTime    time.Time
+	lastChange time.Time
+}
+
+// Watcher 递归监听目录下的文件变化, 文件停止写入一段时间后通过Events输出
+type Watcher struct {
+	root    string
+	c       *config
+	fw      *fsnotify.Watcher
+	exclude map[string]struct{}
+	pending map[string]*fileState
+	ready   map[string]struct{}
+	ch      chan []string
+}
+
+func New(root string, opts ...Option) (*Watcher, error) {
+	c := &config{
+		StableDuration: defaultStableDuration,
+		CheckInterval:  defaultCheckInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("create fs watcher failed, err:%w", err)
+	}
+	w := &Watcher{
+		root:    root,
+		c:       c,
+		fw:      fw,
+		exclude: make(map[string]struct{}),
+		pending: make(map[string]*fileState),
+		ready:   make(map[string]struct{}),
+		ch:      make(chan []string),
+	}
+	for _, dir := range c.ExcludeDirs {
+		if len(dir) == 0 {
+			continue
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			w.exclude[abs] = struct{}{}
+		}
+	}
+	if err := w.addDir(context.Background(), root, false); err != nil {
+		_ = fw.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Events 返回已经写入完成的文件列表
+func (w *Watcher) Events() <-chan []string {
+	return w.ch
+}
+
+func (w *Watcher) isExcluded(dir string) bool {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	_, ok := w.exclude[abs]
+	return ok
+}
+
+// addDir 递归添加目录监听, trackFiles为true时, 目录下已有的文件也会被加入待检查列表(目录整体移入的场景)
+func (w *Watcher) addDir(ctx context.Context, dir string, trackFiles bool) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			logutil.GetLogger(ctx).Warn("walk dir failed, skip", zap.String("path", path), zap.Error(err))
+			return nil
+		}
+		if !d.IsDir() {
+			if trackFiles {
+				w.track(path)
+			}
+			return nil
+		}
+		if w.isExcluded(path) {
+			return filepath.SkipDir
+		}
+		if err := w.fw.Add(path); err != nil {
+			return fmt.Errorf("watch dir failed, dir:%s, err:%w", path, err)
+		}
+		return nil
+	})
+}
+
+func (w *Watcher) track(path string) {
+	fi, err := os.Stat(path)
+	if err != nil || fi.IsDir() {
+		return
+	}
+	delete(w.ready, path)
+	w.pending[path] = &fileState{size: fi.Size(), modTime: fi.ModTime(), lastChange: time.Now()}
+}
+
+func (w *Watcher) onEvent(ctx context.Context, ev fsnotify.Event) {
+	if ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) {
+		delete(w.pending, ev.Name)
+		delete(w.ready, ev.Name)
+		return
+	}
+	if !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Write) {
+		return
+	}
+	fi, err := os.Stat(ev.Name)
+	if err != nil {
+		return
+	}
+	if fi.IsDir() {
+		if err := w.addDir(ctx, ev.Name, true); err != nil {
+			logutil.GetLogger(ctx).Error("add new dir to watcher failed", zap.String("dir", ev.Name), zap.Error(err))
+		}
+		return
+	}
+	w.track(ev.Name)
+}
+
+func (w *Watcher) check(now time.Time) {
+	for path, st := range w.pending {
+		fi, err := os.Stat(path)
+		if err != nil {
+			delete(w.pending, path)
+			continue
+		}
+		if fi.Size() != st.size || !fi.ModTime().Equal(st.modTime) {
+			st.size = fi.Size()
+			st.modTime = fi.ModTime()
+			st.lastChange = now
+			continue
+		}
+		if now.Sub(st.lastChange) < w.c.StableDuration {
+			continue
+		}
+		delete(w.pending, path)
+		w.ready[path] = struct{}{}
+	}
+}
+
+func (w *Watcher) flush() {
+	if len(w.ready) == 0 {
+		return
+	}
+	files := make([]string, 0, len(w.ready))
+	for f := range w.ready {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	select {
+	case w.ch <- files:
+		w.ready = make(map[string]struct{})
+	default: //使用方正忙, 下次检查时再尝试
+	}
+}
+
+// Run 持续监听文件变化, 直到ctx被取消
+func (w *Watcher) Run(ctx context.Context) error {
+	defer w.fw.Close()
+	ticker := time.NewTicker(w.c.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-w.fw.Events:
+			if !ok {
+				return fmt.Errorf("fs watcher closed")
+			}
+			w.onEvent(ctx, ev)
+		case err, ok := <-w.fw.Errors:
+			if !ok {
+				return fmt.Errorf("fs watcher closed")
+			}
+			logutil.GetLogger(ctx).Error("fs watcher error", zap.Error(err))
+		case now := <-ticker.C:
+			w.check(now)
+			w.flush()
+		}
+	}
+}
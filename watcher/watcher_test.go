@@ -0,0 +1,65 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func waitEvent(t *testing.T, w *Watcher, timeout time.Duration) []string {
+	select {
+	case files := <-w.Events():
+		return files
+	case <-time.After(timeout):
+		t.Fatal("wait watcher event timeout")
+	}
+	return nil
+}
+
+func TestWatcher(t *testing.T) {
+	root := t.TempDir()
+	save := filepath.Join(root, "save")
+	assert.NoError(t, os.MkdirAll(save, 0755))
+	w, err := New(root, WithStableDuration(300*time.Millisecond), WithCheckInterval(50*time.Millisecond), WithExcludeDirs(save))
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = w.Run(ctx)
+	}()
+
+	f := filepath.Join(root, "a.mp4")
+	assert.NoError(t, os.WriteFile(f, []byte("1"), 0644))
+	start := time.Now()
+	//模拟下载过程中文件持续增长
+	for i := 0; i < 5; i++ {
+		time.Sleep(100 * time.Millisecond)
+		fd, err := os.OpenFile(f, os.O_APPEND|os.O_WRONLY, 0644)
+		assert.NoError(t, err)
+		_, _ = fd.Write([]byte("1"))
+		_ = fd.Close()
+	}
+	files := waitEvent(t, w, 3*time.Second)
+	assert.Equal(t, []string{f}, files)
+	assert.True(t, time.Since(start) >= 800*time.Millisecond)
+
+	//整体移入的目录及其中的文件需要被识别
+	tmp := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(tmp, "sub"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmp, "sub", "b.mp4"), []byte("1"), 0644))
+	assert.NoError(t, os.Rename(filepath.Join(tmp, "sub"), filepath.Join(root, "sub")))
+	files = waitEvent(t, w, 3*time.Second)
+	assert.Equal(t, []string{filepath.Join(root, "sub", "b.mp4")}, files)
+
+	//保存目录下的变化需要被忽略
+	assert.NoError(t, os.WriteFile(filepath.Join(save, "c.mp4"), []byte("1"), 0644))
+	select {
+	case files := <-w.Events():
+		t.Fatalf("unexpected event:%v", files)
+	case <-time.After(time.Second):
+	}
+}